@@ -3,12 +3,38 @@ package main
 import (
 	"GO_APP/config"
 	api "GO_APP/internal/delivery"
+	"GO_APP/internal/migrations"
+	"errors"
+	"flag"
+	"log"
+	"os"
 )
 
 func main() {
-	config := config.GetConfig()
+	args := os.Args[1:]
+	command := []string{}
+	if len(args) > 0 && args[0] == "migrate" {
+		command, args = migrations.SplitCommand(args)
+	}
+
+	config, err := config.Load(os.Args[0], args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	app := &api.App{}
+	if len(command) > 0 {
+		if err := app.Migrate(config, command[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := config.ValidateServer(); err != nil {
+		log.Fatal(err)
+	}
 	app.Init(config)
-	app.Run(":8004")
+	app.Run(config.Server.Addr)
 }
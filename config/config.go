@@ -1,27 +1,134 @@
 package config
 
+import "time"
+
 type Config struct {
-	DB *DBConfig
+	Server *ServerConfig `yaml:"server" toml:"server"`
+	Cron   *CronConfig   `yaml:"cron" toml:"cron"`
+	DB     *DBConfig     `yaml:"db" toml:"db"`
+	Auth   *AuthConfig   `yaml:"auth" toml:"auth"`
+}
+
+type ServerConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+	// AllowPrivateIPs accepts servers on private and reserved IP ranges,
+	// which are rejected by default.
+	AllowPrivateIPs bool `yaml:"allow_private_ips" toml:"allow_private_ips"`
+	// DefaultThreshold is the threshold of the hostname report when the
+	// request doesn't give one, MaxThreshold the largest one accepted.
+	DefaultThreshold int `yaml:"default_threshold" toml:"default_threshold"`
+	MaxThreshold     int `yaml:"max_threshold" toml:"max_threshold"`
+}
+
+type CronConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+	// PurgeDeletedAfterDays is how long soft-deleted servers are kept before
+	// the cron purges them for good, 0 keeps them forever.
+	PurgeDeletedAfterDays int `yaml:"purge_deleted_after_days" toml:"purge_deleted_after_days"`
+	// UtilisationIntervalMinutes is how often the cron records the active IP
+	// counts of the hostnames, 0 records nothing. The samples are kept
+	// UtilisationRetentionDays, 0 keeps them forever.
+	UtilisationIntervalMinutes int `yaml:"utilisation_interval_minutes" toml:"utilisation_interval_minutes"`
+	UtilisationRetentionDays   int `yaml:"utilisation_retention_days" toml:"utilisation_retention_days"`
+	// JobRunsKept is how many runs of every job are kept, 0 keeps them all,
+	// and JobRunRetentionDays how long, 0 keeps them forever.
+	JobRunsKept         int `yaml:"job_runs_kept" toml:"job_runs_kept"`
+	JobRunRetentionDays int `yaml:"job_run_retention_days" toml:"job_run_retention_days"`
+	// Replica names this cron in the locks it takes on the jobs, so that
+	// replicas sharing the database run every job once. Empty names it after
+	// its host and process. LockTTLMinutes is how long a replica dying while
+	// running a job keeps the others from running it.
+	Replica        string `yaml:"replica" toml:"replica"`
+	LockTTLMinutes int    `yaml:"lock_ttl_minutes" toml:"lock_ttl_minutes"`
+	// Jobs are the jobs the cron starts with, they can only be given in the
+	// config file.
+	Jobs []JobConfig `yaml:"jobs" toml:"jobs"`
+
+	// The settings above as durations, filled in by Load.
+	PurgeDeletedAfter    time.Duration `yaml:"-" toml:"-"`
+	UtilisationInterval  time.Duration `yaml:"-" toml:"-"`
+	UtilisationRetention time.Duration `yaml:"-" toml:"-"`
+	JobRunRetention      time.Duration `yaml:"-" toml:"-"`
+	LockTTL              time.Duration `yaml:"-" toml:"-"`
+}
+
+// parseDurations fills in the durations of the settings counted in days and
+// minutes.
+func (c *CronConfig) parseDurations() {
+	const day = 24 * time.Hour
+	c.PurgeDeletedAfter = time.Duration(c.PurgeDeletedAfterDays) * day
+	c.UtilisationInterval = time.Duration(c.UtilisationIntervalMinutes) * time.Minute
+	c.UtilisationRetention = time.Duration(c.UtilisationRetentionDays) * day
+	c.JobRunRetention = time.Duration(c.JobRunRetentionDays) * day
+	c.LockTTL = time.Duration(c.LockTTLMinutes) * time.Minute
+}
+
+// JobConfig defines a job of the cron, run on the Cron expression or every
+// Interval, a duration such as 30s or 1h. Type is the kind of job and Params
+// its settings.
+type JobConfig struct {
+	Name     string            `yaml:"name" toml:"name"`
+	Type     string            `yaml:"type" toml:"type"`
+	Cron     string            `yaml:"cron" toml:"cron"`
+	Interval string            `yaml:"interval" toml:"interval"`
+	Params   map[string]string `yaml:"params" toml:"params"`
+	Paused   bool              `yaml:"paused" toml:"paused"`
 }
 
 type DBConfig struct {
-	Dialect  string
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBname   string
+	Dialect  string `yaml:"dialect" toml:"dialect"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	DBname   string `yaml:"dbname" toml:"dbname"`
+	// Path is the database file used by the sqlite dialect, ":memory:" keeps
+	// the database in memory for the lifetime of the process.
+	Path string `yaml:"path" toml:"path"`
+	// ServerUniqueKey is what no two live servers may share: "ip", or
+	// "ip_hostname" to let servers of different hostnames share an IP. It is
+	// built into the schema when the migrations are applied, `migrate
+	// reindex` rebuilds it after a change.
+	ServerUniqueKey string `yaml:"server_unique_key" toml:"server_unique_key"`
+}
+
+type AuthConfig struct {
+	JWTKey string `yaml:"jwt_key" toml:"jwt_key"`
 }
 
-func GetConfig() *Config {
-	return &Config{
+// Default returns the configuration used when nothing else is provided.
+// Credentials are intentionally left empty so they have to come from a file,
+// the environment or the command line.
+func Default() *Config {
+	cfg := &Config{
+		Server: &ServerConfig{
+			Addr:             ":8004",
+			DefaultThreshold: 1,
+			MaxThreshold:     10000,
+		},
+		Cron: &CronConfig{
+			Addr:                  ":8005",
+			PurgeDeletedAfterDays: 30,
+
+			UtilisationIntervalMinutes: 15,
+			UtilisationRetentionDays:   90,
+
+			JobRunsKept:         100,
+			JobRunRetentionDays: 30,
+
+			LockTTLMinutes: 10,
+		},
 		DB: &DBConfig{
-			Dialect:  "postgres",
-			Host:     "localhost",
-			Port:     5432,
-			User:     "kriti",
-			Password: "nkx01",
-			DBname:   "go_dummy",
+			Dialect: "postgres",
+			Host:    "localhost",
+			Port:    5432,
+			User:    "postgres",
+			DBname:  "go_dummy",
+
+			ServerUniqueKey: "ip",
 		},
+		Auth: &AuthConfig{},
 	}
+	cfg.Cron.parseDurations()
+	return cfg
 }
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EnvPrefix is prepended to every environment variable read by Load,
+	// e.g. db.host is read from MTA_DB_HOST.
+	EnvPrefix = "MTA_"
+	// EnvConfigFile names the environment variable holding the config file path.
+	EnvConfigFile = EnvPrefix + "CONFIG"
+)
+
+// KeyError reports a configuration value that could not be used, naming the
+// offending key so the operator knows what to fix.
+type KeyError struct {
+	Key    string
+	Source string
+	Err    error
+}
+
+func (e *KeyError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("config: %s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("config: %s (from %s): %v", e.Key, e.Source, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// setting binds a dotted configuration key to the field holding its value.
+type setting struct {
+	key   string
+	usage string
+	value interface{}
+}
+
+// settings lists every key that can be overridden from the environment or the
+// command line.
+func (c *Config) settings() []setting {
+	return []setting{
+		{"server.addr", "listen address of the server api", &c.Server.Addr},
+		{"server.allow_private_ips", "accept servers on private and reserved IP ranges", &c.Server.AllowPrivateIPs},
+		{"server.default_threshold", "threshold of the hostname report when the request gives none", &c.Server.DefaultThreshold},
+		{"server.max_threshold", "largest threshold the hostname report accepts", &c.Server.MaxThreshold},
+		{"cron.addr", "listen address of the scheduler api", &c.Cron.Addr},
+		{"cron.purge_deleted_after_days", "days soft-deleted servers are kept before being purged, 0 keeps them", &c.Cron.PurgeDeletedAfterDays},
+		{"cron.utilisation_interval_minutes", "minutes between two snapshots of the hostname utilisation, 0 takes none", &c.Cron.UtilisationIntervalMinutes},
+		{"cron.utilisation_retention_days", "days utilisation snapshots are kept, 0 keeps them", &c.Cron.UtilisationRetentionDays},
+		{"cron.job_runs_kept", "runs of every job kept in the history, 0 keeps them all", &c.Cron.JobRunsKept},
+		{"cron.job_run_retention_days", "days the runs of the jobs are kept, 0 keeps them", &c.Cron.JobRunRetentionDays},
+		{"cron.replica", "name of this cron in the locks of the jobs, its host and process when empty", &c.Cron.Replica},
+		{"cron.lock_ttl_minutes", "minutes a replica keeps the lock of a job it runs at most", &c.Cron.LockTTLMinutes},
+		{"db.dialect", "database dialect", &c.DB.Dialect},
+		{"db.host", "database host", &c.DB.Host},
+		{"db.port", "database port", &c.DB.Port},
+		{"db.user", "database user", &c.DB.User},
+		{"db.password", "database password", &c.DB.Password},
+		{"db.dbname", "database name", &c.DB.DBname},
+		{"db.path", "database file of the sqlite dialect", &c.DB.Path},
+		{"db.server_unique_key", "what no two servers may share: ip or ip_hostname", &c.DB.ServerUniqueKey},
+		{"auth.jwt_key", "key used to sign JWT tokens", &c.Auth.JWTKey},
+	}
+}
+
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(s.key, ".", "_"))
+}
+
+func (s setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (s setting) set(raw string) error {
+	switch v := s.value.(type) {
+	case *string:
+		*v = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		*v = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration", raw)
+		}
+		*v = d
+	default:
+		return fmt.Errorf("unsupported setting type %T", s.value)
+	}
+	return nil
+}
+
+// flagValue records a command-line value so it can be applied after the file
+// and the environment, whatever order flag.Parse sees them in.
+type flagValue struct {
+	key    string
+	isBool bool
+	parsed *[]parsedFlag
+}
+
+type parsedFlag struct {
+	key string
+	raw string
+}
+
+func (f *flagValue) String() string {
+	return ""
+}
+
+func (f *flagValue) Set(raw string) error {
+	*f.parsed = append(*f.parsed, parsedFlag{key: f.key, raw: raw})
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+// Load builds the configuration for the program called name. Values are
+// layered, each one overriding the previous: defaults, the config file given
+// by -config or MTA_CONFIG (YAML or TOML), MTA_* environment variables and
+// finally command-line flags. The merged result is validated, and the cron
+// durations parsed, before it is returned. -h prints the flags and returns flag.ErrHelp.
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(EnvConfigFile), "path to a YAML or TOML config file")
+	parsed := []parsedFlag{}
+	for _, s := range settings {
+		_, isBool := s.value.(*bool)
+		fs.Var(&flagValue{key: s.key, isBool: isBool, parsed: &parsed}, s.flagName(), s.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := loadFile(cfg, *path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		raw, ok := os.LookupEnv(s.envName())
+		if !ok {
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			return nil, &KeyError{Key: s.key, Source: s.envName(), Err: err}
+		}
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+	for _, p := range parsed {
+		s := byKey[p.key]
+		if err := s.set(p.raw); err != nil {
+			return nil, &KeyError{Key: s.key, Source: "-" + s.flagName(), Err: err}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg.Cron.parseDurations()
+	return cfg, nil
+}
+
+// loadFile merges the file at path into cfg. Keys that are not known are
+// rejected so a typo doesn't silently fall back to the default.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".toml":
+		dec := toml.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return fmt.Errorf("config: %s: unsupported file extension %q", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks the merged configuration and reports the first bad key.
+func (c *Config) Validate() error {
+	if c.Server.Addr == "" {
+		return &KeyError{Key: "server.addr", Err: fmt.Errorf("must not be empty")}
+	}
+	if c.Server.MaxThreshold < 0 {
+		return &KeyError{Key: "server.max_threshold", Err: fmt.Errorf("%d is negative", c.Server.MaxThreshold)}
+	}
+	if c.Server.DefaultThreshold < 0 || c.Server.DefaultThreshold > c.Server.MaxThreshold {
+		return &KeyError{Key: "server.default_threshold", Err: fmt.Errorf("%d is not between 0 and server.max_threshold %d", c.Server.DefaultThreshold, c.Server.MaxThreshold)}
+	}
+	if c.Cron.Addr == "" {
+		return &KeyError{Key: "cron.addr", Err: fmt.Errorf("must not be empty")}
+	}
+	if c.Cron.PurgeDeletedAfterDays < 0 {
+		return &KeyError{Key: "cron.purge_deleted_after_days", Err: fmt.Errorf("%d is negative", c.Cron.PurgeDeletedAfterDays)}
+	}
+	if c.Cron.UtilisationIntervalMinutes < 0 {
+		return &KeyError{Key: "cron.utilisation_interval_minutes", Err: fmt.Errorf("%d is negative", c.Cron.UtilisationIntervalMinutes)}
+	}
+	if c.Cron.UtilisationRetentionDays < 0 {
+		return &KeyError{Key: "cron.utilisation_retention_days", Err: fmt.Errorf("%d is negative", c.Cron.UtilisationRetentionDays)}
+	}
+	if c.Cron.JobRunsKept < 0 {
+		return &KeyError{Key: "cron.job_runs_kept", Err: fmt.Errorf("%d is negative", c.Cron.JobRunsKept)}
+	}
+	if c.Cron.JobRunRetentionDays < 0 {
+		return &KeyError{Key: "cron.job_run_retention_days", Err: fmt.Errorf("%d is negative", c.Cron.JobRunRetentionDays)}
+	}
+	if c.Cron.LockTTLMinutes <= 0 {
+		return &KeyError{Key: "cron.lock_ttl_minutes", Err: fmt.Errorf("%d is not positive", c.Cron.LockTTLMinutes)}
+	}
+	if err := c.Cron.validateJobs(); err != nil {
+		return err
+	}
+	if c.DB.ServerUniqueKey != "ip" && c.DB.ServerUniqueKey != "ip_hostname" {
+		return &KeyError{Key: "db.server_unique_key", Err: fmt.Errorf("%q is neither ip nor ip_hostname", c.DB.ServerUniqueKey)}
+	}
+	switch c.DB.Dialect {
+	case "postgres":
+		return c.DB.validatePostgres()
+	case "sqlite":
+		if c.DB.Path == "" {
+			return &KeyError{Key: "db.path", Err: fmt.Errorf("must be set for the sqlite dialect")}
+		}
+	default:
+		return &KeyError{Key: "db.dialect", Err: fmt.Errorf("unsupported dialect %q", c.DB.Dialect)}
+	}
+	return nil
+}
+
+// ValidateServer checks the keys only the server api needs on top of
+// Validate, the cron running without them.
+func (c *Config) ValidateServer() error {
+	if c.Auth.JWTKey == "" {
+		return &KeyError{Key: "auth.jwt_key", Err: fmt.Errorf("must be set")}
+	}
+	return nil
+}
+
+// validateJobs checks what the scheduler can't: the jobs are named once and
+// have one schedule. Their type and params are checked when they are started.
+func (c *CronConfig) validateJobs() error {
+	names := map[string]bool{}
+	for i, job := range c.Jobs {
+		key := fmt.Sprintf("cron.jobs[%d]", i)
+		if job.Name == "" {
+			return &KeyError{Key: key + ".name", Err: fmt.Errorf("must not be empty")}
+		}
+		if names[job.Name] {
+			return &KeyError{Key: key + ".name", Err: fmt.Errorf("%q is used by another job", job.Name)}
+		}
+		names[job.Name] = true
+		if job.Type == "" {
+			return &KeyError{Key: key + ".type", Err: fmt.Errorf("must not be empty")}
+		}
+		if (job.Cron == "") == (job.Interval == "") {
+			return &KeyError{Key: key, Err: fmt.Errorf("exactly one of cron and interval must be set")}
+		}
+		if job.Interval != "" {
+			if d, err := time.ParseDuration(job.Interval); err != nil || d <= 0 {
+				return &KeyError{Key: key + ".interval", Err: fmt.Errorf("%q is not a positive duration", job.Interval)}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DBConfig) validatePostgres() error {
+	if c.Host == "" {
+		return &KeyError{Key: "db.host", Err: fmt.Errorf("must not be empty")}
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		return &KeyError{Key: "db.port", Err: fmt.Errorf("%d is not a valid port", c.Port)}
+	}
+	if c.User == "" {
+		return &KeyError{Key: "db.user", Err: fmt.Errorf("must not be empty")}
+	}
+	if c.DBname == "" {
+		return &KeyError{Key: "db.dbname", Err: fmt.Errorf("must not be empty")}
+	}
+	return nil
+}
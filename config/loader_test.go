@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Error writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("MTA_AUTH_JWT_KEY", "secret")
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	assert.Equal(t, ":8004", cfg.Server.Addr)
+	assert.Equal(t, ":8005", cfg.Cron.Addr)
+	assert.Equal(t, 30, cfg.Cron.PurgeDeletedAfterDays)
+	assert.Equal(t, 15, cfg.Cron.UtilisationIntervalMinutes)
+	assert.Equal(t, 90, cfg.Cron.UtilisationRetentionDays)
+	assert.Equal(t, 100, cfg.Cron.JobRunsKept)
+	assert.Equal(t, 30, cfg.Cron.JobRunRetentionDays)
+	assert.Empty(t, cfg.Cron.Replica)
+	assert.Equal(t, 10, cfg.Cron.LockTTLMinutes)
+	assert.Equal(t, 30*24*time.Hour, cfg.Cron.PurgeDeletedAfter)
+	assert.Equal(t, 15*time.Minute, cfg.Cron.UtilisationInterval)
+	assert.Equal(t, 90*24*time.Hour, cfg.Cron.UtilisationRetention)
+	assert.Equal(t, 30*24*time.Hour, cfg.Cron.JobRunRetention)
+	assert.Equal(t, 10*time.Minute, cfg.Cron.LockTTL)
+	assert.False(t, cfg.Server.AllowPrivateIPs)
+	assert.Equal(t, 1, cfg.Server.DefaultThreshold)
+	assert.Equal(t, 10000, cfg.Server.MaxThreshold)
+	assert.Equal(t, "postgres", cfg.DB.Dialect)
+	assert.Equal(t, 5432, cfg.DB.Port)
+	assert.Equal(t, "secret", cfg.Auth.JWTKey)
+}
+
+func TestLoadLayering(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", `
+db:
+  host: db.from.file
+  port: 6000
+  user: file-user
+auth:
+  jwt_key: file-key
+`)
+	tomlFile := writeConfigFile(t, "config.toml", `
+[db]
+host = "db.from.file"
+port = 6000
+user = "file-user"
+
+[auth]
+jwt_key = "file-key"
+`)
+
+	for _, path := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			t.Setenv("MTA_DB_PORT", "6001")
+			t.Setenv("MTA_DB_USER", "env-user")
+
+			cfg, err := Load("test", []string{"-config", path, "-db-user", "flag-user", "-server-addr", ":9000", "-server-allow-private-ips", "-cron-lock-ttl-minutes", "3"})
+			if err != nil {
+				t.Fatalf("Error loading config: %v", err)
+			}
+			// file beats defaults, env beats file, flags beat env
+			assert.Equal(t, "db.from.file", cfg.DB.Host)
+			assert.Equal(t, 6001, cfg.DB.Port)
+			assert.Equal(t, "flag-user", cfg.DB.User)
+			assert.Equal(t, ":9000", cfg.Server.Addr)
+			assert.True(t, cfg.Server.AllowPrivateIPs)
+			assert.Equal(t, "file-key", cfg.Auth.JWTKey)
+			// durations follow the settings they are parsed from
+			assert.Equal(t, 3*time.Minute, cfg.Cron.LockTTL)
+			// untouched keys keep their default
+			assert.Equal(t, ":8005", cfg.Cron.Addr)
+			assert.Equal(t, "go_dummy", cfg.DB.DBname)
+		})
+	}
+}
+
+func TestLoadErrorsNameTheKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantKey string
+	}{
+		{
+			name:    "bad port from env",
+			env:     map[string]string{"MTA_DB_PORT": "abc", "MTA_AUTH_JWT_KEY": "k"},
+			wantKey: "db.port",
+		},
+		{
+			name:    "port out of range from flag",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k"},
+			args:    []string{"-db-port", "70000"},
+			wantKey: "db.port",
+		},
+		{
+			name:    "sqlite without a path",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k"},
+			args:    []string{"-db-dialect", "sqlite"},
+			wantKey: "db.path",
+		},
+		{
+			name:    "unknown server unique key",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k", "MTA_DB_SERVER_UNIQUE_KEY": "hostname"},
+			wantKey: "db.server_unique_key",
+		},
+		{
+			name:    "default threshold above the maximum",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k", "MTA_SERVER_MAX_THRESHOLD": "5"},
+			args:    []string{"-server-default-threshold", "6"},
+			wantKey: "server.default_threshold",
+		},
+		{
+			name:    "negative purge period",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k"},
+			args:    []string{"-cron-purge-deleted-after-days", "-1"},
+			wantKey: "cron.purge_deleted_after_days",
+		},
+		{
+			name:    "negative utilisation interval",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k", "MTA_CRON_UTILISATION_INTERVAL_MINUTES": "-5"},
+			wantKey: "cron.utilisation_interval_minutes",
+		},
+		{
+			name:    "negative job runs kept",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k"},
+			args:    []string{"-cron-job-runs-kept", "-1"},
+			wantKey: "cron.job_runs_kept",
+		},
+		{
+			name:    "zero lock ttl",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k", "MTA_CRON_LOCK_TTL_MINUTES": "0"},
+			wantKey: "cron.lock_ttl_minutes",
+		},
+		{
+			name:    "unsupported dialect",
+			env:     map[string]string{"MTA_AUTH_JWT_KEY": "k"},
+			args:    []string{"-db-dialect", "mysql"},
+			wantKey: "db.dialect",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load("test", tt.args)
+			var keyErr *KeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("Expected a KeyError but got %v", err)
+			}
+			assert.Equal(t, tt.wantKey, keyErr.Key)
+		})
+	}
+}
+
+func TestValidateServerNeedsJWTKey(t *testing.T) {
+	// the cron loads without a key, the server api refuses to start
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	err = cfg.ValidateServer()
+	var keyErr *KeyError
+	if !errors.As(err, &keyErr) {
+		t.Fatalf("Expected a KeyError but got %v", err)
+	}
+	assert.Equal(t, "auth.jwt_key", keyErr.Key)
+
+	cfg.Auth.JWTKey = "k"
+	assert.NoError(t, cfg.ValidateServer())
+}
+
+func TestLoadHelp(t *testing.T) {
+	_, err := Load("test", []string{"-h"})
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestLoadRejectsUnknownFileKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "db:\n  hots: typo\n")
+
+	_, err := Load("test", []string{"-config", path})
+	if err == nil {
+		t.Fatal("Expected an error for an unknown key")
+	}
+	assert.Contains(t, err.Error(), "hots")
+}
+
+func TestLoadJobs(t *testing.T) {
+	t.Setenv("MTA_AUTH_JWT_KEY", "k")
+	yamlFile := writeConfigFile(t, "config.yaml", `
+cron:
+  jobs:
+    - name: eu-ips
+      type: active_ips
+      interval: 30s
+      params:
+        selector: region=eu
+    - name: nightly-purge
+      type: purge_deleted
+      cron: "0 3 * * *"
+      params:
+        after_days: "7"
+      paused: true
+`)
+	tomlFile := writeConfigFile(t, "config.toml", `
+[[cron.jobs]]
+name = "eu-ips"
+type = "active_ips"
+interval = "30s"
+params = { selector = "region=eu" }
+
+[[cron.jobs]]
+name = "nightly-purge"
+type = "purge_deleted"
+cron = "0 3 * * *"
+params = { after_days = "7" }
+paused = true
+`)
+
+	for _, path := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			cfg, err := Load("test", []string{"-config", path})
+			if err != nil {
+				t.Fatalf("Error loading config: %v", err)
+			}
+			assert.Equal(t, []JobConfig{
+				{Name: "eu-ips", Type: "active_ips", Interval: "30s", Params: map[string]string{"selector": "region=eu"}},
+				{Name: "nightly-purge", Type: "purge_deleted", Cron: "0 3 * * *", Params: map[string]string{"after_days": "7"}, Paused: true},
+			}, cfg.Cron.Jobs)
+		})
+	}
+
+	tests := []struct {
+		name    string
+		jobs    string
+		wantKey string
+	}{
+		{"missing name", "  - type: active_ips\n    interval: 1m\n", "cron.jobs[0].name"},
+		{"duplicate name", "  - {name: a, type: active_ips, interval: 1m}\n  - {name: a, type: active_ips, interval: 2m}\n", "cron.jobs[1].name"},
+		{"missing type", "  - {name: a, interval: 1m}\n", "cron.jobs[0].type"},
+		{"no schedule", "  - {name: a, type: active_ips}\n", "cron.jobs[0]"},
+		{"two schedules", "  - {name: a, type: active_ips, interval: 1m, cron: '* * * * *'}\n", "cron.jobs[0]"},
+		{"bad interval", "  - {name: a, type: active_ips, interval: -1m}\n", "cron.jobs[0].interval"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", "cron:\n  jobs:\n"+tt.jobs)
+			_, err := Load("test", []string{"-config", path})
+			var keyErr *KeyError
+			if !errors.As(err, &keyErr) {
+				t.Fatalf("Expected a KeyError but got %v", err)
+			}
+			assert.Equal(t, tt.wantKey, keyErr.Key)
+		})
+	}
+}
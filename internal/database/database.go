@@ -0,0 +1,85 @@
+package database
+
+import (
+	"GO_APP/config"
+	"database/sql"
+	"fmt"
+	"net"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	// MemoryPath is the sqlite path keeping the database in memory.
+	MemoryPath = ":memory:"
+	// sqliteDriver is the sqlite driver with the functions sqlite lacks and
+	// postgres has built in.
+	sqliteDriver = "sqlite3_mta"
+)
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("ip_in_cidr", ipInCIDR, true)
+		},
+	})
+}
+
+// ipInCIDR backs the sqlite ip_in_cidr(ip, cidr) function, the equivalent
+// of postgres' ip::inet <<= cidr::cidr.
+func ipInCIDR(ip, cidr string) bool {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	return parsed != nil && network.Contains(parsed)
+}
+
+// Open connects to the database described by cfg, picking the driver from
+// cfg.Dialect.
+func Open(cfg *config.DBConfig, opts ...gorm.Option) (*gorm.DB, error) {
+	switch cfg.Dialect {
+	case "postgres":
+		return gorm.Open(postgres.Open(PostgresDSN(cfg)), opts...)
+	case "sqlite":
+		return openSqlite(cfg.Path, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported database dialect %q", cfg.Dialect)
+	}
+}
+
+// PostgresDSN builds the connection string for the postgres dialect.
+func PostgresDSN(cfg *config.DBConfig) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		cfg.Host,
+		cfg.Port,
+		cfg.User,
+		cfg.Password,
+		cfg.DBname,
+	)
+}
+
+func openSqlite(path string, opts ...gorm.Option) (*gorm.DB, error) {
+	dsn := path + "?_foreign_keys=on&_busy_timeout=5000"
+	if path == MemoryPath {
+		dsn = "file::memory:?_foreign_keys=on"
+	}
+
+	db, err := gorm.Open(&sqlite.Dialector{DriverName: sqliteDriver, DSN: dsn}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: gets a database of its own and sqlite
+	// only allows a single writer anyway, so stick to one connection.
+	sqlDB.SetMaxOpenConns(1)
+	return db, nil
+}
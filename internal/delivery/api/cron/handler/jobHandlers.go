@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jobStatus returns the status code for an error returned by the job methods
+func jobStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrInvalidJob), errors.Is(err, repository.ErrInvalidCursor):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrJobExists), errors.Is(err, repository.ErrStaleJob):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// respondJobError makes the json error response of a job request
+func respondJobError(c *gin.Context, err error) {
+	c.JSON(jobStatus(err), gin.H{"error": err.Error()})
+}
+
+// decodeJob reads a job spec from the request body
+func decodeJob(c *gin.Context, spec *JobSpec) error {
+	r := c.Request
+	defer r.Body.Close()
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(spec); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	return nil
+}
+
+// GetJobs lists every job, sorted by name
+func (sch *Scheduler) GetJobs(c *gin.Context) {
+	jobs, err := sch.Jobs()
+	if err != nil {
+		log.Printf("[cron][GetJobs][Jobs] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// CreateJob adds the job of the request body, 409 when its name is taken
+func (sch *Scheduler) CreateJob(c *gin.Context) {
+	spec := JobSpec{}
+	if err := decodeJob(c, &spec); err != nil {
+		log.Printf("[cron][CreateJob][decodeJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+
+	status, err := sch.AddJob(spec)
+	if err != nil {
+		log.Printf("[cron][CreateJob][AddJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, status)
+}
+
+func (sch *Scheduler) GetJob(c *gin.Context) {
+	status, err := sch.Job(c.Param("name"))
+	if err != nil {
+		log.Printf("[cron][GetJob][Job] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// UpdateJob replaces the job of the path by the one of the request body,
+// rescheduling it. The name can't be changed.
+func (sch *Scheduler) UpdateJob(c *gin.Context) {
+	spec := JobSpec{}
+	if err := decodeJob(c, &spec); err != nil {
+		log.Printf("[cron][UpdateJob][decodeJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	name := c.Param("name")
+	if spec.Name != "" && spec.Name != name {
+		err := fmt.Errorf("%w: name: %s can't be renamed %s, create a new job", ErrInvalidJob, name, spec.Name)
+		log.Printf("[cron][UpdateJob][decodeJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+
+	status, err := sch.ReplaceJob(name, spec)
+	if err != nil {
+		log.Printf("[cron][UpdateJob][ReplaceJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// PauseJob stops running a job until it is resumed, a run in progress finishes
+func (sch *Scheduler) PauseJob(c *gin.Context) {
+	status, err := sch.SetJobPaused(c.Param("name"), true)
+	if err != nil {
+		log.Printf("[cron][PauseJob][SetJobPaused] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ResumeJob schedules a paused job again
+func (sch *Scheduler) ResumeJob(c *gin.Context) {
+	status, err := sch.SetJobPaused(c.Param("name"), false)
+	if err != nil {
+		log.Printf("[cron][ResumeJob][SetJobPaused] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (sch *Scheduler) DeleteJob(c *gin.Context) {
+	if err := sch.RemoveJob(c.Param("name")); err != nil {
+		log.Printf("[cron][DeleteJob][RemoveJob] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// runsPage is the response of GET /scheduler/jobs/:name/runs
+type runsPage struct {
+	Runs       []model.JobRun `json:"runs"`
+	Pagination struct {
+		Limit int `json:"limit"`
+		// NextCursor is passed as the cursor parameter to get the next page,
+		// it is left out on the last page
+		NextCursor string `json:"next_cursor,omitempty"`
+	} `json:"pagination"`
+}
+
+// runFilter reads the query parameters of the runs of a job: status, limit
+// and cursor
+func runFilter(c *gin.Context) (repository.JobRunFilter, error) {
+	filter := repository.JobRunFilter{
+		Job:    c.Param("name"),
+		Status: c.Query("status"),
+		Cursor: c.Query("cursor"),
+		Limit:  repository.DefaultLimit,
+	}
+	if filter.Status != "" && filter.Status != model.JobRunSucceeded && filter.Status != model.JobRunFailed {
+		return filter, fmt.Errorf("status: %q is neither %s nor %s", filter.Status, model.JobRunSucceeded, model.JobRunFailed)
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > repository.MaxLimit {
+			return filter, fmt.Errorf("limit: must be an integer between 1 and %d", repository.MaxLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// GetJobRuns lists the runs of a job, newest first, filtered by the status
+// query parameter and paginated by limit and cursor
+func (sch *Scheduler) GetJobRuns(c *gin.Context) {
+	filter, err := runFilter(c)
+	if err != nil {
+		log.Printf("[cron][GetJobRuns][runFilter] error:%+v\n", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := sch.Runs(filter)
+	if err != nil {
+		log.Printf("[cron][GetJobRuns][Runs] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	response := runsPage{Runs: page.Runs}
+	response.Pagination.Limit, response.Pagination.NextCursor = filter.Limit, page.NextCursor
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLocks lists the locks the replicas of the cron took on the jobs, with
+// the replica answering, to tell which one runs each job
+func (sch *Scheduler) GetLocks(c *gin.Context) {
+	locks, replica, err := sch.Locks()
+	if err != nil {
+		log.Printf("[cron][GetLocks][Locks] error:%+v\n", err)
+		respondJobError(c, err)
+		return
+	}
+	response := struct {
+		Replica string          `json:"replica"`
+		Locks   []model.JobLock `json:"locks"`
+	}{Replica: replica, Locks: locks}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,715 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron"
+)
+
+// Types of job the cron knows how to run
+const (
+	// JobActiveIPs logs the active IPs, of the servers matching the selector
+	// param when it is given
+	JobActiveIPs = "active_ips"
+	// JobPurgeDeleted purges the servers soft-deleted more than the after_days
+	// param ago
+	JobPurgeDeleted = "purge_deleted"
+	// JobUtilisation records the utilisation of the hostnames, keeping the
+	// samples retention_days, forever when it is 0 or not given
+	JobUtilisation = "utilisation"
+)
+
+var (
+	ErrInvalidJob  = errors.New("invalid job")
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobExists   = errors.New("job already exists")
+	// errLeaseLost fails a run whose replica lost the lock of its job meanwhile
+	errLeaseLost = errors.New("lease lost")
+)
+
+// minJobInterval is the shortest interval a job may run at
+const minJobInterval = time.Second
+
+var jobNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,62}$`)
+
+// JobSpec defines a job, run on the Cron expression or every Interval, a Go
+// duration such as 30s or 1h
+type JobSpec struct {
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Cron     string            `json:"cron,omitempty"`
+	Interval string            `json:"interval,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	// Paused jobs are kept but not run
+	Paused bool `json:"paused"`
+}
+
+// JobStatus is a job as reported by the scheduler api
+type JobStatus struct {
+	JobSpec
+	NextRun *time.Time `json:"next_run,omitempty"`
+	LastRun *time.Time `json:"last_run,omitempty"`
+	// Runs counts the runs kept in the history of the job, whichever replica
+	// made them
+	Runs int `json:"runs"`
+}
+
+// jobTask is what a job runs, it returns what the run found or did, stored as
+// JSON with the run. ctx is cancelled when the replica loses the lock of the
+// job, another one may be running it then.
+type jobTask func(ctx context.Context) (interface{}, error)
+
+// jobType lists the params a type of job takes and builds its task from them
+type jobType struct {
+	params []string
+	task   func(repo repository.ServerRepository, params map[string]string) (jobTask, error)
+}
+
+var jobTypes = map[string]jobType{
+	JobActiveIPs: {
+		params: []string{"selector"},
+		task: func(repo repository.ServerRepository, params map[string]string) (jobTask, error) {
+			selector, err := repository.ParseSelector(params["selector"])
+			if err != nil {
+				return nil, err
+			}
+			return func(context.Context) (interface{}, error) { return get_hostname(repo, selector) }, nil
+		},
+	},
+	JobPurgeDeleted: {
+		params: []string{"after_days"},
+		task: func(repo repository.ServerRepository, params map[string]string) (jobTask, error) {
+			days, err := dayParam(params, "after_days")
+			if err != nil {
+				return nil, err
+			}
+			if days == 0 {
+				return nil, fmt.Errorf("after_days is required and must be positive")
+			}
+			return func(context.Context) (interface{}, error) { return purgeDeleted(repo, days) }, nil
+		},
+	},
+	JobUtilisation: {
+		params: []string{"retention_days"},
+		task: func(repo repository.ServerRepository, params map[string]string) (jobTask, error) {
+			days, err := dayParam(params, "retention_days")
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context) (interface{}, error) { return snapshotUtilisation(ctx, repo, days) }, nil
+		},
+	},
+}
+
+// dayParam reads a number of days from params, 0 when it isn't given
+func dayParam(params map[string]string, name string) (time.Duration, error) {
+	raw, ok := params[name]
+	if !ok {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(raw)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("%s: %q is not a number of days", name, raw)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// interval parses the Interval of spec
+func (spec JobSpec) interval() (time.Duration, error) {
+	d, err := time.ParseDuration(spec.Interval)
+	if err != nil {
+		return 0, fmt.Errorf("interval: %q is not a duration", spec.Interval)
+	}
+	if d < minJobInterval {
+		return 0, fmt.Errorf("interval: %s is shorter than %s", d, minJobInterval)
+	}
+	return d, nil
+}
+
+// task validates spec and builds the function its job runs against repo
+func (spec JobSpec) task(repo repository.ServerRepository) (jobTask, error) {
+	if !jobNamePattern.MatchString(spec.Name) {
+		return nil, fmt.Errorf("%w: name: %q must be 1 to 63 letters, digits, '.', '_' and '-'", ErrInvalidJob, spec.Name)
+	}
+	switch {
+	case spec.Cron == "" && spec.Interval == "":
+		return nil, fmt.Errorf("%w: one of cron and interval is required", ErrInvalidJob)
+	case spec.Cron != "" && spec.Interval != "":
+		return nil, fmt.Errorf("%w: cron and interval are exclusive", ErrInvalidJob)
+	case spec.Interval != "":
+		if _, err := spec.interval(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+	default:
+		// the expression is parsed by a scratch scheduler, the way it will be
+		if _, err := gocron.NewScheduler(time.Local).Cron(spec.Cron).Do(func() {}); err != nil {
+			return nil, fmt.Errorf("%w: cron: %q: %v", ErrInvalidJob, spec.Cron, err)
+		}
+	}
+
+	typ, ok := jobTypes[spec.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: type: %q is not one of %s, %s, %s", ErrInvalidJob, spec.Type, JobActiveIPs, JobPurgeDeleted, JobUtilisation)
+	}
+	for name := range spec.Params {
+		known := false
+		for _, param := range typ.params {
+			known = known || param == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: params: %s doesn't take %q", ErrInvalidJob, spec.Type, name)
+		}
+	}
+	task, err := typ.task(repo, spec.Params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: params: %v", ErrInvalidJob, err)
+	}
+	return task, nil
+}
+
+// scheduledJob is a job known to the scheduler, job is nil while it is paused
+type scheduledJob struct {
+	spec JobSpec
+	task jobTask
+	job  *gocron.Job
+	// lastRun is when the last run started, kept across restarts
+	lastRun *time.Time
+	// updatedAt is that of the stored job j follows, j is only stored over it
+	// while it is unchanged
+	updatedAt time.Time
+}
+
+func (j *scheduledJob) nextRun() *time.Time {
+	if j.job == nil {
+		return nil
+	}
+	next := j.job.NextRun()
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// status returns the status of j, runs holding the number of kept runs by job
+func (j *scheduledJob) status(runs map[string]int) JobStatus {
+	return JobStatus{JobSpec: j.spec, NextRun: j.nextRun(), LastRun: j.lastRun, Runs: runs[j.spec.Name]}
+}
+
+// model returns j as it is stored
+func (j *scheduledJob) model() *model.SchedulerJob {
+	return &model.SchedulerJob{
+		Name:      j.spec.Name,
+		Type:      j.spec.Type,
+		Cron:      j.spec.Cron,
+		Interval:  j.spec.Interval,
+		Params:    j.spec.Params,
+		Paused:    j.spec.Paused,
+		LastRunAt: j.lastRun,
+		NextRunAt: j.nextRun(),
+		UpdatedAt: j.updatedAt,
+	}
+}
+
+// specOf returns the spec of a stored job
+func specOf(job model.SchedulerJob) JobSpec {
+	return JobSpec{Name: job.Name, Type: job.Type, Cron: job.Cron, Interval: job.Interval, Params: job.Params, Paused: job.Paused}
+}
+
+// sameDefinition tells whether a and b run the same task on the same schedule
+func sameDefinition(a, b JobSpec) bool {
+	if a.Type != b.Type || a.Cron != b.Cron || a.Interval != b.Interval || a.Paused != b.Paused || len(a.Params) != len(b.Params) {
+		return false
+	}
+	for name, value := range a.Params {
+		if other, ok := b.Params[name]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// schedule registers the task of j with the scheduler, a run never starts
+// while the previous one of the same job is still going, here or on another
+// replica. A job run every interval starts at next, the stored next run, while
+// it is ahead, so a restart or another replica keeps its schedule. Otherwise it
+// starts at the next multiple of the interval since the zero time, the slots
+// every replica shares, running right away as well when it never ran. The
+// caller holds mu.
+func (sch *Scheduler) schedule(j *scheduledJob, next *time.Time) error {
+	s := sch.scheduler
+	if j.spec.Cron != "" {
+		s = s.Cron(j.spec.Cron)
+	} else {
+		interval, err := j.spec.interval()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if next != nil && next.After(now) {
+			s = s.Every(interval).StartAt(*next)
+		} else {
+			s = s.Every(interval).StartAt(now.Truncate(interval).Add(interval))
+			if j.lastRun == nil {
+				s = s.StartImmediately()
+			}
+		}
+	}
+	job, err := s.SingletonMode().Do(func() {
+		sch.tick(j)
+	})
+	if err != nil {
+		return err
+	}
+	j.job = job
+	return nil
+}
+
+// tick runs the task of j if this replica gets the lock of its job, the
+// replicas sharing the database ticking on the same schedule, give or take
+// their clocks. The job is read again once locked, the run being skipped and j
+// dropped when another replica removed, paused or changed it meanwhile. The
+// lock is renewed while the task runs, then kept until halfway to the next
+// run, so a replica ticking a little late skips the run instead of making it
+// twice. When another replica takes the lock mid-run the task is cancelled and
+// the run fails, its times left to the replica holding the lock.
+func (sch *Scheduler) tick(j *scheduledJob) {
+	sch.mu.Lock()
+	name, replica, ttl := j.spec.Name, sch.replica, sch.lockTTL
+	sch.mu.Unlock()
+
+	acquired, err := sch.store.JobLocks().Acquire(name, replica, ttl)
+	if err != nil {
+		log.Printf("[cron][tick][JobLocks.Acquire] error:%+v\n", err)
+		return
+	}
+	if !acquired {
+		return
+	}
+
+	sch.mu.Lock()
+	stored, err := sch.stored(name)
+	current := err == nil && sch.follow(name, stored) == j
+	next := j.nextRun()
+	sch.mu.Unlock()
+	if err != nil {
+		log.Printf("[cron][tick][Jobs.Get] error:%+v\n", err)
+	}
+	if !current {
+		if err := sch.store.JobLocks().Release(name, replica, 0); err != nil {
+			log.Printf("[cron][tick][JobLocks.Release] error:%+v\n", err)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		sch.renew(name, replica, ttl, stop, cancel)
+		close(stopped)
+	}()
+	run := runTask(ctx, name, j.task)
+	close(stop)
+	<-stopped
+	if ctx.Err() != nil {
+		run.Status, run.Error = model.JobRunFailed, errLeaseLost.Error()
+		sch.ran(j, run, false)
+		return
+	}
+	sch.ran(j, run, true)
+
+	var keep time.Duration
+	if next != nil {
+		keep = time.Until(run.StartedAt.Add(next.Sub(run.StartedAt) / 2))
+	}
+	if keep < 0 {
+		keep = 0
+	}
+	if err := sch.store.JobLocks().Release(name, replica, keep); err != nil {
+		log.Printf("[cron][tick][JobLocks.Release] error:%+v\n", err)
+	}
+}
+
+// renew extends the lock of the job called name by ttl every third of it,
+// until stop is closed, so a run lasting longer than ttl keeps it. When another
+// replica took the lock meanwhile it calls lost and stops.
+func (sch *Scheduler) renew(name, replica string, ttl time.Duration, stop <-chan struct{}, lost func()) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			held, err := sch.store.JobLocks().Renew(name, replica, ttl)
+			if err != nil {
+				log.Printf("[cron][renew][JobLocks.Renew] error:%+v\n", err)
+			} else if !held {
+				log.Printf("[cron][renew][%s] error:the lock was taken by another replica\n", name)
+				lost()
+				return
+			}
+		}
+	}
+}
+
+// runTask runs the task of the job called name with ctx, a panic fails the run
+func runTask(ctx context.Context, name string, task jobTask) model.JobRun {
+	run := model.JobRun{JobName: name, StartedAt: time.Now().UTC()}
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return task(ctx)
+	}()
+	run.FinishedAt = time.Now().UTC()
+	run.DurationMS = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+
+	run.Status = model.JobRunSucceeded
+	if err != nil {
+		run.Status, run.Error = model.JobRunFailed, err.Error()
+	}
+	if result != nil {
+		if run.Result, err = json.Marshal(result); err != nil {
+			log.Printf("[cron][runTask][json.Marshal] error:%+v\n", err)
+		}
+	}
+	return run
+}
+
+// ran records run, then prunes the runs of its job past the retention. When
+// held, the lock of the job was kept all along, and when the job last ran and
+// is due next are stored too, nothing else of it. Nothing is recorded when the
+// job was removed meanwhile, along with its runs.
+func (sch *Scheduler) ran(j *scheduledJob, run model.JobRun, held bool) {
+	if run.Status == model.JobRunFailed {
+		log.Printf("[cron][ran][%s] error:%+v\n", run.JobName, run.Error)
+	}
+
+	if held {
+		sch.mu.Lock()
+		j.lastRun = &run.StartedAt
+		next := j.nextRun()
+		sch.mu.Unlock()
+		err := sch.store.Jobs().SetRunTimes(run.JobName, run.StartedAt, next)
+		if errors.Is(err, repository.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			log.Printf("[cron][ran][Jobs.SetRunTimes] error:%+v\n", err)
+		}
+	} else {
+		stored, err := sch.stored(run.JobName)
+		if err != nil {
+			log.Printf("[cron][ran][Jobs.Get] error:%+v\n", err)
+		}
+		if err == nil && stored == nil {
+			return
+		}
+	}
+	if err := sch.store.JobRuns().Record(&run); err != nil {
+		log.Printf("[cron][ran][JobRuns.Record] error:%+v\n", err)
+		return
+	}
+	var cutoff time.Time
+	if sch.config.JobRunRetention > 0 {
+		cutoff = time.Now().Add(-sch.config.JobRunRetention)
+	}
+	if _, err := sch.store.JobRuns().Prune(run.JobName, sch.config.JobRunsKept, cutoff); err != nil {
+		log.Printf("[cron][ran][JobRuns.Prune] error:%+v\n", err)
+	}
+}
+
+// unschedule stops running j, a run in progress finishes
+func (sch *Scheduler) unschedule(j *scheduledJob) {
+	if j.job != nil {
+		sch.scheduler.RemoveByReference(j.job)
+		j.job = nil
+	}
+}
+
+// stored returns the stored job called name, nil when there is none
+func (sch *Scheduler) stored(name string) (*model.SchedulerJob, error) {
+	job, err := sch.store.Jobs().Get(name)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// follow brings the job called name in line with stored, the stored job,
+// which another replica may have changed or removed (nil) meanwhile. It
+// returns the job, nil when there is none or this build can't run it. The
+// caller holds mu.
+func (sch *Scheduler) follow(name string, stored *model.SchedulerJob) *scheduledJob {
+	old, ok := sch.jobs[name]
+	if ok && stored != nil && sameDefinition(old.spec, specOf(*stored)) {
+		old.lastRun, old.updatedAt = stored.LastRunAt, stored.UpdatedAt
+		return old
+	}
+	if ok {
+		sch.unschedule(old)
+		delete(sch.jobs, name)
+	}
+	if stored == nil {
+		return nil
+	}
+
+	spec := specOf(*stored)
+	task, err := spec.task(sch.repo)
+	if err != nil {
+		// logged once per change of the stored job
+		if !sch.skipped[name].Equal(stored.UpdatedAt) {
+			log.Printf("[cron][follow][task] skipping job %s, error:%+v\n", name, err)
+			sch.skipped[name] = stored.UpdatedAt
+		}
+		return nil
+	}
+	j := &scheduledJob{spec: spec, task: task, lastRun: stored.LastRunAt, updatedAt: stored.UpdatedAt}
+	if !spec.Paused {
+		if err := sch.schedule(j, stored.NextRunAt); err != nil {
+			log.Printf("[cron][follow][schedule] skipping job %s, error:%+v\n", name, err)
+			return nil
+		}
+	}
+	sch.jobs[name] = j
+	return j
+}
+
+// sync follows every stored job, those the other replicas added included.
+// The caller holds mu.
+func (sch *Scheduler) sync() error {
+	stored, err := sch.store.Jobs().List()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for i := range stored {
+		names[stored[i].Name] = true
+		sch.follow(stored[i].Name, &stored[i])
+	}
+	for name := range sch.jobs {
+		if !names[name] {
+			sch.follow(name, nil)
+		}
+	}
+	return nil
+}
+
+// put schedules j unless it is paused and stores it over read, the stored job
+// called like it or nil when there is none, then makes it the local one. j
+// keeps the next run of read when it runs the same. The store refuses j when
+// the job changed since read. The caller holds mu.
+func (sch *Scheduler) put(j *scheduledJob, read *model.SchedulerJob) error {
+	name := j.spec.Name
+	var next *time.Time
+	if read != nil {
+		j.lastRun, j.updatedAt = read.LastRunAt, read.UpdatedAt
+		if sameDefinition(j.spec, specOf(*read)) {
+			next = read.NextRunAt
+		}
+	}
+	if !j.spec.Paused {
+		if err := sch.schedule(j, next); err != nil {
+			return err
+		}
+	}
+	stored := j.model()
+	if err := sch.store.Jobs().Save(stored); err != nil {
+		sch.unschedule(j)
+		switch {
+		case errors.Is(err, repository.ErrJobExists):
+			return fmt.Errorf("%w: %s", ErrJobExists, name)
+		case errors.Is(err, repository.ErrNotFound):
+			sch.follow(name, nil)
+			return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+		}
+		return err
+	}
+	j.updatedAt = stored.UpdatedAt
+	if old, ok := sch.jobs[name]; ok {
+		sch.unschedule(old)
+	}
+	sch.jobs[name] = j
+	return nil
+}
+
+// AddJob validates spec, schedules it unless it is paused and stores it
+func (sch *Scheduler) AddJob(spec JobSpec) (JobStatus, error) {
+	task, err := spec.task(sch.repo)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	j := &scheduledJob{spec: spec, task: task}
+	if err := sch.put(j, nil); err != nil {
+		return JobStatus{}, err
+	}
+	return sch.status(j)
+}
+
+// ReplaceJob replaces the job called name by spec, which keeps the name
+func (sch *Scheduler) ReplaceJob(name string, spec JobSpec) (JobStatus, error) {
+	spec.Name = name
+	task, err := spec.task(sch.repo)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	read, err := sch.stored(name)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if read == nil {
+		sch.follow(name, nil)
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	j := &scheduledJob{spec: spec, task: task}
+	if err := sch.put(j, read); err != nil {
+		return JobStatus{}, err
+	}
+	return sch.status(j)
+}
+
+// EnsureJob adds the job of spec, or replaces the job called like it by spec
+// while keeping whether it is paused. The jobs of the configuration follow it
+// this way, and a pause made through the api survives restarts.
+func (sch *Scheduler) EnsureJob(spec JobSpec) (JobStatus, error) {
+	task, err := spec.task(sch.repo)
+	if err != nil {
+		return JobStatus{}, err
+	}
+
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	read, err := sch.stored(spec.Name)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	if read != nil {
+		spec.Paused = read.Paused
+	}
+	j := &scheduledJob{spec: spec, task: task}
+	if err := sch.put(j, read); err != nil {
+		return JobStatus{}, err
+	}
+	return sch.status(j)
+}
+
+// SetJobPaused pauses or resumes the job called name
+func (sch *Scheduler) SetJobPaused(name string, paused bool) (JobStatus, error) {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	read, err := sch.stored(name)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	old := sch.follow(name, read)
+	if old == nil {
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if old.spec.Paused == paused {
+		return sch.status(old)
+	}
+	j := &scheduledJob{spec: old.spec, task: old.task}
+	j.spec.Paused = paused
+	if err := sch.put(j, read); err != nil {
+		return JobStatus{}, err
+	}
+	return sch.status(j)
+}
+
+// RemoveJob stops and forgets the job called name
+func (sch *Scheduler) RemoveJob(name string) error {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	err := sch.store.Jobs().Delete(name)
+	if errors.Is(err, repository.ErrNotFound) {
+		err = fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if err == nil || errors.Is(err, ErrJobNotFound) {
+		sch.follow(name, nil)
+	}
+	return err
+}
+
+// Job returns the status of the job called name
+func (sch *Scheduler) Job(name string) (JobStatus, error) {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	read, err := sch.stored(name)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	j := sch.follow(name, read)
+	if j == nil {
+		return JobStatus{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	return sch.status(j)
+}
+
+// Jobs returns the status of every job, sorted by name
+func (sch *Scheduler) Jobs() ([]JobStatus, error) {
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	if err := sch.sync(); err != nil {
+		return nil, err
+	}
+	runs, err := sch.store.JobRuns().Counts()
+	if err != nil {
+		return nil, err
+	}
+	jobs := []JobStatus{}
+	for _, j := range sch.jobs {
+		jobs = append(jobs, j.status(runs))
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Name < jobs[k].Name })
+	return jobs, nil
+}
+
+// status returns the status of j. The caller holds mu.
+func (sch *Scheduler) status(j *scheduledJob) (JobStatus, error) {
+	runs, err := sch.store.JobRuns().Counts()
+	if err != nil {
+		return JobStatus{}, err
+	}
+	return j.status(runs), nil
+}
+
+// Locks returns the lock of every job that ran, held or not, and the name of
+// this replica
+func (sch *Scheduler) Locks() ([]model.JobLock, string, error) {
+	sch.mu.Lock()
+	replica := sch.replica
+	sch.mu.Unlock()
+	locks, err := sch.store.JobLocks().List()
+	return locks, replica, err
+}
+
+// Runs returns a page of the runs of the job filter.Job, newest first
+func (sch *Scheduler) Runs(filter repository.JobRunFilter) (*repository.JobRunPage, error) {
+	read, err := sch.stored(filter.Job)
+	if err != nil {
+		return nil, err
+	}
+	if read == nil {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, filter.Job)
+	}
+	return sch.store.JobRuns().List(filter)
+}
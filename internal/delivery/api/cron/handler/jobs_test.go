@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"GO_APP/config"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunTask(t *testing.T) {
+	run := runTask(context.Background(), "ips", func(context.Context) (interface{}, error) {
+		return activeIPsResult{IPs: []string{"11.0.0.1"}}, nil
+	})
+	assert.Equal(t, "ips", run.JobName)
+	assert.Equal(t, model.JobRunSucceeded, run.Status)
+	assert.JSONEq(t, `{"ips":["11.0.0.1"]}`, string(run.Result))
+	assert.False(t, run.FinishedAt.Before(run.StartedAt))
+
+	// a failed run keeps what it returned
+	run = runTask(context.Background(), "snapshot", func(context.Context) (interface{}, error) {
+		return snapshotResult{Recorded: 3}, errors.New("database is locked")
+	})
+	assert.Equal(t, model.JobRunFailed, run.Status)
+	assert.Equal(t, "database is locked", run.Error)
+	assert.JSONEq(t, `{"recorded":3,"purged":0}`, string(run.Result))
+
+	run = runTask(context.Background(), "broken", func(context.Context) (interface{}, error) {
+		var ips []string
+		return ips[1], nil
+	})
+	assert.Equal(t, model.JobRunFailed, run.Status)
+	assert.Contains(t, run.Error, "panic: runtime error: index out of range")
+	assert.Nil(t, run.Result)
+}
+
+func TestRenewKeepsTheLock(t *testing.T) {
+	store := repository.NewMemorySchedulerRepository()
+	sch := &Scheduler{store: store}
+	acquired, err := store.JobLocks().Acquire("slow", "cron-a", 30*time.Millisecond)
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		sch.renew("slow", "cron-a", 30*time.Millisecond, stop, func() { t.Error("the lock was lost") })
+		close(stopped)
+	}()
+	// a run lasting several times the ttl keeps the lock
+	time.Sleep(100 * time.Millisecond)
+	locks, err := store.JobLocks().List()
+	assert.NoError(t, err)
+	if assert.Len(t, locks, 1) {
+		assert.True(t, locks[0].Held)
+	}
+
+	close(stop)
+	<-stopped
+	time.Sleep(50 * time.Millisecond)
+	locks, err = store.JobLocks().List()
+	assert.NoError(t, err)
+	if assert.Len(t, locks, 1) {
+		assert.False(t, locks[0].Held)
+	}
+}
+
+func TestTickLosesTheLock(t *testing.T) {
+	store := repository.NewMemorySchedulerRepository()
+	cfg := config.Default().Cron
+	cfg.Replica, cfg.LockTTL = "cron-a", 30*time.Millisecond
+	sch, err := InitializeScheduler(repository.NewMemoryServerRepository(repository.UniqueIP), store, cfg)
+	assert.NoError(t, err)
+	stored := &model.SchedulerJob{Name: "slow", Type: JobActiveIPs, Interval: "1h"}
+	assert.NoError(t, store.Jobs().Save(stored))
+
+	// cron-b takes the lock mid-run, cron-a gives up the run
+	j := &scheduledJob{spec: specOf(*stored), updatedAt: stored.UpdatedAt, task: func(ctx context.Context) (interface{}, error) {
+		assert.NoError(t, store.JobLocks().Release("slow", "cron-a", 0))
+		acquired, err := store.JobLocks().Acquire("slow", "cron-b", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+			return activeIPsResult{IPs: []string{}}, nil
+		}
+	}}
+	sch.jobs["slow"] = j
+	sch.tick(j)
+
+	// the run failed, its times are left to cron-b and so is the lock
+	page, err := store.JobRuns().List(repository.JobRunFilter{Job: "slow"})
+	assert.NoError(t, err)
+	if assert.Len(t, page.Runs, 1) {
+		assert.Equal(t, model.JobRunFailed, page.Runs[0].Status)
+		assert.Equal(t, "lease lost", page.Runs[0].Error)
+	}
+	job, err := store.Jobs().Get("slow")
+	assert.NoError(t, err)
+	assert.Nil(t, job.LastRunAt)
+	assert.Nil(t, j.lastRun)
+	locks, err := store.JobLocks().List()
+	assert.NoError(t, err)
+	if assert.Len(t, locks, 1) {
+		assert.Equal(t, "cron-b", locks[0].Holder)
+		assert.True(t, locks[0].Held)
+	}
+}
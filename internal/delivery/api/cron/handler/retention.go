@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"GO_APP/internal/repository"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// purgeResult is the result of a run of a purge_deleted job
+type purgeResult struct {
+	Purged int64     `json:"purged"`
+	Cutoff time.Time `json:"cutoff"`
+}
+
+// purgeDeleted removes for good the servers soft-deleted more than after ago
+func purgeDeleted(repo repository.ServerRepository, after time.Duration) (interface{}, error) {
+	cutoff := time.Now().Add(-after)
+	purged, err := repo.PurgeDeleted(cutoff)
+	if err != nil {
+		log.Printf("[cron][purgeDeleted][PurgeDeleted] error:%+v\n", err)
+		return nil, err
+	}
+
+	log.Printf("Purged %d servers deleted before %s\n", purged, cutoff.Format(time.RFC3339))
+	return purgeResult{Purged: purged, Cutoff: cutoff}, nil
+}
+
+// startRetentionJob purges every hour the servers soft-deleted more than
+// after ago, right away the first time, as the purge_deleted job. When after
+// is zero the job is removed.
+func (sch *Scheduler) startRetentionJob(after time.Duration) error {
+	if after <= 0 {
+		log.Println("Retention job disabled, deleted servers are kept")
+		// the job may have been stored by a previous run
+		if err := sch.RemoveJob(JobPurgeDeleted); err != nil && !errors.Is(err, ErrJobNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	days := strconv.Itoa(int(after / (24 * time.Hour)))
+	_, err := sch.EnsureJob(JobSpec{Name: JobPurgeDeleted, Type: JobPurgeDeleted, Interval: "1h", Params: map[string]string{"after_days": days}})
+	return err
+}
@@ -1,54 +1,165 @@
 package handler
 
 import (
+	"GO_APP/config"
+	"GO_APP/internal/repository"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
-	"gorm.io/gorm"
 )
 
+// legacyJobName is the job started by POST /scheduler/start
+const legacyJobName = "get_hostname"
+
+// defaultLockTTL is how long a replica keeps the lock of a job it is running
+// when the config doesn't say
+const defaultLockTTL = 10 * time.Minute
+
+// syncInterval is how often the jobs are brought in line with the stored ones,
+// which the other replicas may have changed
+const syncInterval = time.Minute
+
 type Scheduler struct {
 	scheduler *gocron.Scheduler
-	job       *gocron.Job
+	// repo is what the jobs work on
+	repo repository.ServerRepository
+	// store keeps the jobs, their runs and the locks the replicas take on them
+	store repository.SchedulerRepository
+	// config holds the built-in jobs, those of the config file and the
+	// retention of their runs
+	config *config.CronConfig
+	// mu guards jobs, the jobs by name, which follow the stored ones, and
+	// skipped, when the stored jobs this build can't run were updated
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	skipped map[string]time.Time
+	// replica names this cron in the locks of the jobs, held lockTTL at most
+	// while a job runs
+	replica string
+	lockTTL time.Duration
 }
 
-func (sch *Scheduler) StartSchedulerJob(c *gin.Context, db *gorm.DB) {
+// StartSchedulerJob starts logging the active IPs every 2 seconds, only those
+// of the servers matching the selector query parameter when it is given. It
+// is the get_hostname job of the scheduler api.
+func (sch *Scheduler) StartSchedulerJob(c *gin.Context) {
 	if sch == nil {
 		log.Println("Scheduler not initialized")
 		return
 	}
 
-	// If scheduler is not null and scheduler is running then create a new job
-	if sch.job == nil || !sch.scheduler.IsRunning() {
-		sch.job, _ = sch.scheduler.Every(2).Second().Do(func() {
-			get_hostname(db)
-		})
-		sch.scheduler.StartAsync()
-
-		c.String(http.StatusOK, "Cron job started")
-	} else {
+	spec := JobSpec{Name: legacyJobName, Type: JobActiveIPs, Interval: "2s"}
+	if selector := c.Query("selector"); selector != "" {
+		spec.Params = map[string]string{"selector": selector}
+	}
+	_, err := sch.AddJob(spec)
+	switch {
+	case errors.Is(err, ErrJobExists):
 		c.String(http.StatusOK, "Cron job is already running")
+	case errors.Is(err, ErrInvalidJob):
+		log.Printf("[cron][StartSchedulerJob][AddJob] error:%+v\n", err)
+		c.String(http.StatusBadRequest, "%v", err)
+	case err != nil:
+		log.Printf("[cron][StartSchedulerJob][AddJob] error:%+v\n", err)
+		c.String(http.StatusInternalServerError, "%v", err)
+	default:
+		c.String(http.StatusOK, "Cron job started")
 	}
-
 }
 
 func (sch *Scheduler) StopSchedulerJob(c *gin.Context) {
-	if sch.scheduler != nil && sch.scheduler.IsRunning() {
-		sch.scheduler.RemoveByReference(sch.job)
-		sch.job = nil
-		c.String(http.StatusOK, "Cron job stopped")
-	} else {
+	if err := sch.RemoveJob(legacyJobName); err != nil {
 		c.String(http.StatusOK, "No active cron job to stop")
+		return
+	}
+	c.String(http.StatusOK, "Cron job stopped")
+}
+
+// InitializeScheduler returns a scheduler configured by cfg holding the jobs
+// stored in store, as they were when the cron stopped, which work on repo.
+// They don't run before Start. A stored job this build can't run is left
+// aside, and kept in store.
+func InitializeScheduler(repo repository.ServerRepository, store repository.SchedulerRepository, cfg *config.CronConfig) (*Scheduler, error) {
+	sch := &Scheduler{
+		scheduler: gocron.NewScheduler(time.Local),
+		repo:      repo,
+		store:     store,
+		config:    cfg,
+		jobs:      map[string]*scheduledJob{},
+		skipped:   map[string]time.Time{},
+		replica:   cfg.Replica,
+		lockTTL:   cfg.LockTTL,
 	}
+	if sch.replica == "" {
+		sch.replica = defaultReplica()
+	}
+	if sch.lockTTL <= 0 {
+		sch.lockTTL = defaultLockTTL
+	}
+
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	if err := sch.sync(); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// defaultReplica names the cron after its host and process, so two replicas
+// never share a name
+func defaultReplica() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "cron"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
 }
 
-func InitializeScheduler() *Scheduler {
-	sch := gocron.NewScheduler(time.Local)
-	return &Scheduler{
-		scheduler: sch,
+// Start adds the built-in jobs and those of the config file to the stored
+// ones, then runs them all, storing when they are due next. The jobs follow
+// the stored ones from then on, every syncInterval.
+func (sch *Scheduler) Start() error {
+	if err := sch.startRetentionJob(sch.config.PurgeDeletedAfter); err != nil {
+		return fmt.Errorf("retention job: %w", err)
+	}
+	if err := sch.startUtilisationJob(sch.config.UtilisationInterval, sch.config.UtilisationRetention); err != nil {
+		return fmt.Errorf("utilisation job: %w", err)
+	}
+	for _, job := range sch.config.Jobs {
+		spec := JobSpec{Name: job.Name, Type: job.Type, Cron: job.Cron, Interval: job.Interval, Params: job.Params, Paused: job.Paused}
+		if _, err := sch.EnsureJob(spec); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+	}
+	if _, err := sch.scheduler.Every(syncInterval).WaitForSchedule().Do(func() {
+		sch.mu.Lock()
+		defer sch.mu.Unlock()
+		if err := sch.sync(); err != nil {
+			log.Printf("[cron][Start][sync] error:%+v\n", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("sync job: %w", err)
+	}
+	sch.scheduler.StartAsync()
+
+	sch.mu.Lock()
+	defer sch.mu.Unlock()
+	for _, j := range sch.jobs {
+		stored := j.model()
+		// a job changed by another replica meanwhile is left as it is stored
+		if err := sch.store.Jobs().Save(stored); err != nil {
+			log.Printf("[cron][Start][Jobs.Save] error:%+v\n", err)
+			continue
+		}
+		j.updatedAt = stored.UpdatedAt
 	}
+	return nil
 }
@@ -1,17 +1,23 @@
 package handler
 
 import (
+	"GO_APP/internal/repository"
 	"log"
-
-	"gorm.io/gorm"
 )
 
-func get_hostname(db *gorm.DB) {
-	ips := []string{}
-	db.Table("servers").
-		Select("ip as IP").
-		Where("active = true").
-		Scan(&ips)
+// activeIPsResult is the result of a run of an active_ips job
+type activeIPsResult struct {
+	IPs []string `json:"ips"`
+}
+
+// get_hostname logs the IP of every active server whose labels match selector
+func get_hostname(repo repository.ServerRepository, selector repository.Selector) (interface{}, error) {
+	ips, err := repo.ActiveIPs(selector)
+	if err != nil {
+		log.Printf("[cron][get_hostname][ActiveIPs] error:%+v\n", err)
+		return nil, err
+	}
 
 	log.Printf("Active IPs: %+v\n", ips)
+	return activeIPsResult{IPs: ips}, nil
 }
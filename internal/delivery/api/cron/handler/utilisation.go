@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"GO_APP/internal/repository"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+)
+
+// snapshotResult is the result of a run of a utilisation job
+type snapshotResult struct {
+	Recorded int   `json:"recorded"`
+	Purged   int64 `json:"purged"`
+}
+
+// snapshotUtilisation records the active and inactive IP counts of every
+// hostname, then drops the samples older than retention unless it is zero or
+// ctx was cancelled meanwhile
+func snapshotUtilisation(ctx context.Context, repo repository.ServerRepository, retention time.Duration) (interface{}, error) {
+	now := time.Now()
+	recorded, err := repository.RecordUtilisation(repo, now)
+	if err != nil {
+		log.Printf("[cron][snapshotUtilisation][RecordUtilisation] error:%+v\n", err)
+		return nil, err
+	}
+	log.Printf("Recorded the utilisation of %d hostnames\n", recorded)
+	result := snapshotResult{Recorded: recorded}
+
+	if retention <= 0 {
+		return result, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	result.Purged, err = repo.Utilisation().PurgeBefore(now.Add(-retention))
+	if err != nil {
+		log.Printf("[cron][snapshotUtilisation][PurgeBefore] error:%+v\n", err)
+		return result, err
+	}
+	if result.Purged > 0 {
+		log.Printf("Purged %d utilisation samples older than %s\n", result.Purged, retention)
+	}
+	return result, nil
+}
+
+// startUtilisationJob snapshots the utilisation of the hostnames every
+// interval, right away the first time, keeping the samples for retention,
+// forever when it is zero, as the utilisation job. When interval is zero the
+// job is removed.
+func (sch *Scheduler) startUtilisationJob(interval, retention time.Duration) error {
+	if interval <= 0 {
+		log.Println("Utilisation job disabled, no history is recorded")
+		// the job may have been stored by a previous run
+		if err := sch.RemoveJob(JobUtilisation); err != nil && !errors.Is(err, ErrJobNotFound) {
+			return err
+		}
+		return nil
+	}
+
+	days := strconv.Itoa(int(retention / (24 * time.Hour)))
+	_, err := sch.EnsureJob(JobSpec{Name: JobUtilisation, Type: JobUtilisation, Interval: interval.String(), Params: map[string]string{"retention_days": days}})
+	return err
+}
@@ -24,11 +24,20 @@ func (a *SchedulerRoute) SetSchedulerRouter() {
 	// Routing for handling the projects
 	router.POST("/scheduler/start", a.StartScheduler)
 	router.POST("/scheduler/stop", a.StopScheduler)
+	router.GET("/scheduler/jobs", a.GetJobs)
+	router.POST("/scheduler/jobs", a.CreateJob)
+	router.GET("/scheduler/jobs/:name", a.GetJob)
+	router.PUT("/scheduler/jobs/:name", a.UpdateJob)
+	router.DELETE("/scheduler/jobs/:name", a.DeleteJob)
+	router.POST("/scheduler/jobs/:name/pause", a.PauseJob)
+	router.POST("/scheduler/jobs/:name/resume", a.ResumeJob)
+	router.GET("/scheduler/jobs/:name/runs", a.GetJobRuns)
+	router.GET("/scheduler/locks", a.GetLocks)
 }
 
 // Handlers to start the scheduler
 func (a *SchedulerRoute) StartScheduler(c *gin.Context) {
-	a.SchedulerJob.StartSchedulerJob(c, a.DB)
+	a.SchedulerJob.StartSchedulerJob(c)
 }
 
 // Handlers to stop the scheduler
@@ -36,6 +45,42 @@ func (a *SchedulerRoute) StopScheduler(c *gin.Context) {
 	a.SchedulerJob.StopSchedulerJob(c)
 }
 
+func (a *SchedulerRoute) GetJobs(c *gin.Context) {
+	a.SchedulerJob.GetJobs(c)
+}
+
+func (a *SchedulerRoute) CreateJob(c *gin.Context) {
+	a.SchedulerJob.CreateJob(c)
+}
+
+func (a *SchedulerRoute) GetJob(c *gin.Context) {
+	a.SchedulerJob.GetJob(c)
+}
+
+func (a *SchedulerRoute) UpdateJob(c *gin.Context) {
+	a.SchedulerJob.UpdateJob(c)
+}
+
+func (a *SchedulerRoute) DeleteJob(c *gin.Context) {
+	a.SchedulerJob.DeleteJob(c)
+}
+
+func (a *SchedulerRoute) PauseJob(c *gin.Context) {
+	a.SchedulerJob.PauseJob(c)
+}
+
+func (a *SchedulerRoute) ResumeJob(c *gin.Context) {
+	a.SchedulerJob.ResumeJob(c)
+}
+
+func (a *SchedulerRoute) GetJobRuns(c *gin.Context) {
+	a.SchedulerJob.GetJobRuns(c)
+}
+
+func (a *SchedulerRoute) GetLocks(c *gin.Context) {
+	a.SchedulerJob.GetLocks(c)
+}
+
 // Run the SchedulerRoute on it's router
 func (a *SchedulerRoute) Run(host string) {
 	log.Fatal(http.ListenAndServe(host, a.Router))
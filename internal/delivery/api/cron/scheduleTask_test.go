@@ -0,0 +1,495 @@
+package cron
+
+import (
+	"GO_APP/config"
+	"GO_APP/internal/database"
+	"GO_APP/internal/delivery/api/cron/handler"
+	"GO_APP/internal/migrations"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns an in-memory sqlite database with the schema applied
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := database.Open(&config.DBConfig{Dialect: "sqlite", Path: database.MemoryPath}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	if _, err := migrations.Up(db, migrations.Placeholders{ServerUniqueColumns: repository.UniqueIP.Columns()}); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	return db
+}
+
+// newTestRoute returns a started SchedulerRoute loading its jobs from db, as
+// the cron does when it starts.
+func newTestRoute(t *testing.T, db *gorm.DB) *SchedulerRoute {
+	return newReplica(t, db, "")
+}
+
+// newReplica is newTestRoute for the replica called name. The built-in jobs
+// are left out so only those of the test run.
+func newReplica(t *testing.T, db *gorm.DB, name string) *SchedulerRoute {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default().Cron
+	cfg.PurgeDeletedAfter, cfg.UtilisationInterval = 0, 0
+	cfg.Replica, cfg.LockTTL = name, time.Minute
+	sch, err := handler.InitializeScheduler(repository.NewGormServerRepository(db, repository.UniqueIP), repository.NewGormSchedulerRepository(db), cfg)
+	if err != nil {
+		t.Fatalf("Error initializing the scheduler: %v", err)
+	}
+	if err := sch.Start(); err != nil {
+		t.Fatalf("Error starting the scheduler: %v", err)
+	}
+	route := &SchedulerRoute{
+		Router:       gin.New(),
+		DB:           db,
+		SchedulerJob: sch,
+	}
+	route.SetSchedulerRouter()
+	return route
+}
+
+func (a *SchedulerRoute) serve(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	reader := bytes.NewReader(nil)
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error marshaling body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSchedulerJobs(t *testing.T) {
+	route := newTestRoute(t, newTestDB(t))
+	nightly := handler.JobSpec{Name: "eu-ips", Type: handler.JobActiveIPs, Cron: "0 3 * * *", Params: map[string]string{"selector": "region=eu"}}
+
+	rr := route.serve(t, "POST", "/scheduler/jobs", nightly)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	status := handler.JobStatus{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, nightly, status.JobSpec)
+	assert.NotNil(t, status.NextRun)
+
+	rr = route.serve(t, "POST", "/scheduler/jobs", nightly)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	paused := handler.JobSpec{Name: "purge", Type: handler.JobPurgeDeleted, Interval: "1h", Params: map[string]string{"after_days": "7"}, Paused: true}
+	rr = route.serve(t, "POST", "/scheduler/jobs", paused)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	rr = route.serve(t, "GET", "/scheduler/jobs", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	jobs := []handler.JobStatus{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &jobs))
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "eu-ips", jobs[0].Name)
+		assert.Equal(t, "purge", jobs[1].Name)
+		assert.True(t, jobs[1].Paused)
+		assert.Nil(t, jobs[1].NextRun)
+	}
+
+	// the schedule changes, the name stays
+	nightly.Cron = "30 4 * * 1"
+	rr = route.serve(t, "PUT", "/scheduler/jobs/eu-ips", nightly)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.Equal(t, "30 4 * * 1", status.Cron)
+	rr = route.serve(t, "PUT", "/scheduler/jobs/eu-ips", handler.JobSpec{Name: "us-ips", Type: handler.JobActiveIPs, Cron: "0 3 * * *"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = route.serve(t, "PUT", "/scheduler/jobs/missing", handler.JobSpec{Type: handler.JobActiveIPs, Cron: "0 3 * * *"})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = route.serve(t, "POST", "/scheduler/jobs/eu-ips/pause", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	status = handler.JobStatus{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.True(t, status.Paused)
+	assert.Nil(t, status.NextRun)
+	rr = route.serve(t, "POST", "/scheduler/jobs/eu-ips/resume", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &status))
+	assert.False(t, status.Paused)
+	assert.NotNil(t, status.NextRun)
+
+	rr = route.serve(t, "DELETE", "/scheduler/jobs/eu-ips", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = route.serve(t, "GET", "/scheduler/jobs/eu-ips", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = route.serve(t, "DELETE", "/scheduler/jobs/eu-ips", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSchedulerJobsValidation(t *testing.T) {
+	route := newTestRoute(t, newTestDB(t))
+	tests := []struct {
+		name string
+		body interface{}
+	}{
+		{"no schedule", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs}},
+		{"both schedules", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Cron: "* * * * *", Interval: "1m"}},
+		{"bad cron", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Cron: "every day"}},
+		{"bad interval", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Interval: "often"}},
+		{"interval too short", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Interval: "10ms"}},
+		{"bad name", handler.JobSpec{Name: "a/b", Type: handler.JobActiveIPs, Interval: "1m"}},
+		{"unknown type", handler.JobSpec{Name: "a", Type: "reboot", Interval: "1m"}},
+		{"unknown param", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Interval: "1m", Params: map[string]string{"after_days": "1"}}},
+		{"bad selector", handler.JobSpec{Name: "a", Type: handler.JobActiveIPs, Interval: "1m", Params: map[string]string{"selector": "region in eu"}}},
+		{"missing after_days", handler.JobSpec{Name: "a", Type: handler.JobPurgeDeleted, Interval: "1h"}},
+		{"unknown field", map[string]string{"name": "a", "type": handler.JobActiveIPs, "interval": "1m", "every": "1m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := route.serve(t, "POST", "/scheduler/jobs", tt.body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, rr.Body.String())
+		})
+	}
+
+	rr := route.serve(t, "GET", "/scheduler/jobs", nil)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+}
+
+func TestSchedulerStartsConfiguredJobs(t *testing.T) {
+	cfg := config.Default().Cron
+	cfg.UtilisationInterval = 0
+	cfg.Jobs = []config.JobConfig{{Name: "nightly", Type: handler.JobActiveIPs, Cron: "0 3 * * *", Paused: true}}
+	db := newTestDB(t)
+	sch, err := handler.InitializeScheduler(repository.NewGormServerRepository(db, repository.UniqueIP), repository.NewGormSchedulerRepository(db), cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, sch.Start())
+
+	names := []string{}
+	jobs, err := sch.Jobs()
+	assert.NoError(t, err)
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	assert.Equal(t, []string{"nightly", handler.JobPurgeDeleted}, names)
+	purge, err := sch.Job(handler.JobPurgeDeleted)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"after_days": "30"}, purge.Params)
+}
+
+func TestSchedulerJobsSurviveRestarts(t *testing.T) {
+	db := newTestDB(t)
+	route := newTestRoute(t, db)
+
+	// runs right away, then on every hour
+	rr := route.serve(t, "POST", "/scheduler/jobs", handler.JobSpec{Name: "hourly", Type: handler.JobActiveIPs, Interval: "1h"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	rr = route.serve(t, "POST", "/scheduler/jobs", handler.JobSpec{Name: "nightly", Type: handler.JobPurgeDeleted, Cron: "0 3 * * *", Params: map[string]string{"after_days": "7"}})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	rr = route.serve(t, "POST", "/scheduler/jobs/nightly/pause", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	var hourly handler.JobStatus
+	assert.Eventually(t, func() bool {
+		hourly, _ = route.SchedulerJob.Job("hourly")
+		return hourly.LastRun != nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the run times are stored with the definitions
+	stored, err := repository.NewGormSchedulerRepository(db).Jobs().List()
+	assert.NoError(t, err)
+	if assert.Len(t, stored, 2) {
+		assert.Equal(t, "hourly", stored[0].Name)
+		assert.NotNil(t, stored[0].LastRunAt)
+		assert.NotNil(t, stored[0].NextRunAt)
+		assert.True(t, stored[1].Paused)
+		assert.Nil(t, stored[1].NextRunAt)
+	}
+
+	// a new cron on the same database picks up where the last one stopped,
+	// the hourly job isn't due so it doesn't run
+	restarted := newTestRoute(t, db)
+	time.Sleep(200 * time.Millisecond)
+	rr = restarted.serve(t, "GET", "/scheduler/jobs", nil)
+	jobs := []handler.JobStatus{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &jobs))
+	if assert.Len(t, jobs, 2) {
+		assert.Equal(t, "hourly", jobs[0].Name)
+		assert.Equal(t, 1, jobs[0].Runs)
+		if assert.NotNil(t, jobs[0].NextRun) && assert.NotNil(t, stored[0].NextRunAt) {
+			assert.WithinDuration(t, *stored[0].NextRunAt, *jobs[0].NextRun, time.Millisecond)
+		}
+		if assert.NotNil(t, jobs[0].LastRun) {
+			assert.True(t, hourly.LastRun.Equal(*jobs[0].LastRun))
+		}
+		assert.Equal(t, "nightly", jobs[1].Name)
+		assert.True(t, jobs[1].Paused)
+		assert.Equal(t, map[string]string{"after_days": "7"}, jobs[1].Params)
+	}
+
+	// the configuration replaces a stored job but not its pause
+	status, err := restarted.SchedulerJob.EnsureJob(handler.JobSpec{Name: "nightly", Type: handler.JobPurgeDeleted, Cron: "0 4 * * *", Params: map[string]string{"after_days": "30"}})
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+	assert.Equal(t, "0 4 * * *", status.Cron)
+
+	rr = restarted.serve(t, "DELETE", "/scheduler/jobs/hourly", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = newTestRoute(t, db).serve(t, "GET", "/scheduler/jobs/hourly", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSchedulerReplicasFollowStoredJobs(t *testing.T) {
+	db := newTestDB(t)
+	a, b := newReplica(t, db, "cron-a"), newReplica(t, db, "cron-b")
+	nightly := handler.JobSpec{Name: "nightly", Type: handler.JobActiveIPs, Cron: "0 3 * * *"}
+
+	// a job added on one replica runs on the other
+	rr := a.serve(t, "POST", "/scheduler/jobs", nightly)
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	rr = b.serve(t, "POST", "/scheduler/jobs", nightly)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	status, err := b.SchedulerJob.Job("nightly")
+	assert.NoError(t, err)
+	assert.NotNil(t, status.NextRun)
+
+	// so does a pause, and a resume the other way
+	rr = a.serve(t, "POST", "/scheduler/jobs/nightly/pause", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	status, err = b.SchedulerJob.Job("nightly")
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+	assert.Nil(t, status.NextRun)
+	rr = b.serve(t, "POST", "/scheduler/jobs/nightly/resume", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	status, err = a.SchedulerJob.Job("nightly")
+	assert.NoError(t, err)
+	assert.False(t, status.Paused)
+
+	// a job run every interval keeps the schedule it has on the replica that
+	// added it
+	rr = a.serve(t, "POST", "/scheduler/jobs", handler.JobSpec{Name: "hourly", Type: handler.JobActiveIPs, Interval: "1h"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+	hourlyA, err := a.SchedulerJob.Job("hourly")
+	assert.NoError(t, err)
+	hourlyB, err := b.SchedulerJob.Job("hourly")
+	assert.NoError(t, err)
+	if assert.NotNil(t, hourlyA.NextRun) && assert.NotNil(t, hourlyB.NextRun) {
+		assert.WithinDuration(t, *hourlyA.NextRun, *hourlyB.NextRun, time.Millisecond)
+	}
+	rr = a.serve(t, "DELETE", "/scheduler/jobs/hourly", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	// the configuration of a replica keeps the pause made on the other
+	rr = a.serve(t, "POST", "/scheduler/jobs/nightly/pause", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	nightly.Cron = "0 4 * * *"
+	status, err = b.SchedulerJob.EnsureJob(nightly)
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+
+	// and a removed job is gone from both
+	rr = b.serve(t, "DELETE", "/scheduler/jobs/nightly", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = a.serve(t, "GET", "/scheduler/jobs", nil)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+	rr = a.serve(t, "PUT", "/scheduler/jobs/nightly", nightly)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	stored, err := repository.NewGormSchedulerRepository(db).Jobs().List()
+	assert.NoError(t, err)
+	assert.Empty(t, stored)
+}
+
+func TestSchedulerTicksFollowStoredJobs(t *testing.T) {
+	db := newTestDB(t)
+	store := repository.NewGormSchedulerRepository(db)
+	a, b := newReplica(t, db, "cron-a"), newReplica(t, db, "cron-b")
+
+	// both run every second on a, b only learns about them when asked
+	for _, name := range []string{"paused", "removed"} {
+		rr := a.serve(t, "POST", "/scheduler/jobs", handler.JobSpec{Name: name, Type: handler.JobActiveIPs, Interval: "1s"})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	}
+	assert.Eventually(t, func() bool {
+		counts, err := store.JobRuns().Counts()
+		return err == nil && counts["paused"] > 0 && counts["removed"] > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	rr := b.serve(t, "POST", "/scheduler/jobs/paused/pause", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = b.serve(t, "DELETE", "/scheduler/jobs/removed", nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	before, err := store.JobRuns().Counts()
+	assert.NoError(t, err)
+
+	// a reads them again on its next ticks, skips them and leaves them as b did
+	time.Sleep(2500 * time.Millisecond)
+	after, err := store.JobRuns().Counts()
+	assert.NoError(t, err)
+	assert.Equal(t, before["paused"], after["paused"])
+	assert.Zero(t, after["removed"])
+	stored, err := store.Jobs().List()
+	assert.NoError(t, err)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, "paused", stored[0].Name)
+		assert.True(t, stored[0].Paused)
+	}
+}
+
+func TestSchedulerJobRuns(t *testing.T) {
+	db := newTestDB(t)
+	repo := repository.NewGormServerRepository(db, repository.UniqueIP)
+	assert.NoError(t, repo.Create(&model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true}))
+	assert.NoError(t, repo.Create(&model.Server{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: false}))
+	route := newTestRoute(t, db)
+
+	// runs right away, then on every hour
+	rr := route.serve(t, "POST", "/scheduler/jobs", handler.JobSpec{Name: "hourly", Type: handler.JobActiveIPs, Interval: "1h"})
+	assert.Equal(t, http.StatusCreated, rr.Code)
+
+	page := struct {
+		Runs       []model.JobRun
+		Pagination struct {
+			Limit      int    `json:"limit"`
+			NextCursor string `json:"next_cursor"`
+		}
+	}{}
+	assert.Eventually(t, func() bool {
+		rr = route.serve(t, "GET", "/scheduler/jobs/hourly/runs", nil)
+		return rr.Code == http.StatusOK && json.Unmarshal(rr.Body.Bytes(), &page) == nil && len(page.Runs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	if assert.Len(t, page.Runs, 1) {
+		run := page.Runs[0]
+		assert.Equal(t, "hourly", run.JobName)
+		assert.Equal(t, model.JobRunSucceeded, run.Status)
+		assert.Empty(t, run.Error)
+		assert.False(t, run.FinishedAt.Before(run.StartedAt))
+		assert.JSONEq(t, `{"ips":["11.0.0.1"]}`, string(run.Result))
+	}
+	assert.Equal(t, 100, page.Pagination.Limit)
+	assert.Empty(t, page.Pagination.NextCursor)
+
+	rr = route.serve(t, "GET", "/scheduler/jobs/hourly/runs?status=failed", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"runs":[]`)
+
+	for _, query := range []string{"status=crashed", "limit=0", "limit=5000", "cursor=!"} {
+		rr = route.serve(t, "GET", "/scheduler/jobs/hourly/runs?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+	rr = route.serve(t, "GET", "/scheduler/jobs/missing/runs", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestSchedulerLocks(t *testing.T) {
+	db := newTestDB(t)
+	a, b := newReplica(t, db, "cron-a"), newReplica(t, db, "cron-b")
+
+	// both replicas tick right away, then on every hour
+	spec := handler.JobSpec{Name: "hourly", Type: handler.JobActiveIPs, Interval: "1h"}
+	for _, route := range []*SchedulerRoute{a, b} {
+		_, err := route.SchedulerJob.EnsureJob(spec)
+		assert.NoError(t, err)
+	}
+	// both count the runs of either
+	assert.Eventually(t, func() bool {
+		statusA, _ := a.SchedulerJob.Job("hourly")
+		statusB, _ := b.SchedulerJob.Job("hourly")
+		return statusA.Runs == 1 && statusB.Runs == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// only one of them made the run, the other one skipped it
+	var page *repository.JobRunPage
+	assert.Eventually(t, func() bool {
+		var err error
+		page, err = repository.NewGormSchedulerRepository(db).JobRuns().List(repository.JobRunFilter{Job: "hourly"})
+		return err == nil && len(page.Runs) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	page, err := repository.NewGormSchedulerRepository(db).JobRuns().List(repository.JobRunFilter{Job: "hourly"})
+	assert.NoError(t, err)
+	assert.Len(t, page.Runs, 1)
+
+	locks := struct {
+		Replica string `json:"replica"`
+		Locks   []struct {
+			model.JobLock
+			Held bool `json:"held"`
+		} `json:"locks"`
+	}{}
+	rr := b.serve(t, "GET", "/scheduler/locks", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &locks))
+	assert.Equal(t, "cron-b", locks.Replica)
+	if assert.Len(t, locks.Locks, 1) {
+		lock := locks.Locks[0]
+		assert.Equal(t, "hourly", lock.JobName)
+		assert.Contains(t, []string{"cron-a", "cron-b"}, lock.Holder)
+		assert.True(t, lock.Held)
+		// kept until halfway to the next run, on the next hour
+		next := lock.AcquiredAt.Truncate(time.Hour).Add(time.Hour)
+		assert.WithinDuration(t, lock.AcquiredAt.Add(next.Sub(lock.AcquiredAt)/2), lock.ExpiresAt, time.Second)
+	}
+}
+
+func TestSchedulerReplicasShareTheSlots(t *testing.T) {
+	db := newTestDB(t)
+	store := repository.NewGormSchedulerRepository(db)
+	assert.NoError(t, store.Jobs().Save(&model.SchedulerJob{Name: "often", Type: handler.JobActiveIPs, Interval: "1s"}))
+
+	// both replicas load the job before either ran it, then start apart
+	cfg := config.Default().Cron
+	cfg.PurgeDeletedAfter, cfg.UtilisationInterval, cfg.LockTTL = 0, 0, time.Minute
+	replicas := []*handler.Scheduler{}
+	for _, name := range []string{"cron-a", "cron-b"} {
+		cfg := *cfg
+		cfg.Replica = name
+		sch, err := handler.InitializeScheduler(repository.NewGormServerRepository(db, repository.UniqueIP), store, &cfg)
+		assert.NoError(t, err)
+		replicas = append(replicas, sch)
+	}
+	assert.NoError(t, replicas[0].Start())
+	time.Sleep(600 * time.Millisecond)
+	assert.NoError(t, replicas[1].Start())
+	started := time.Now()
+	time.Sleep(3200 * time.Millisecond)
+
+	// each runs it right away, then on the same seconds as the other, so the
+	// runs that follow are a second apart
+	page, err := store.JobRuns().List(repository.JobRunFilter{Job: "often"})
+	assert.NoError(t, err)
+	runs := []time.Time{}
+	firstSlot := started.Add(100 * time.Millisecond).Truncate(time.Second).Add(time.Second)
+	for _, run := range page.Runs {
+		if !run.StartedAt.Before(firstSlot) {
+			runs = append(runs, run.StartedAt)
+		}
+	}
+	assert.GreaterOrEqual(t, len(runs), 2)
+	for i := 1; i < len(runs); i++ {
+		assert.WithinDuration(t, runs[i].Add(time.Second), runs[i-1], 100*time.Millisecond)
+	}
+	for _, sch := range replicas {
+		status, err := sch.Job("often")
+		assert.NoError(t, err)
+		if assert.NotNil(t, status.NextRun) {
+			assert.Less(t, status.NextRun.Sub(status.NextRun.Truncate(time.Second)), 100*time.Millisecond)
+		}
+	}
+}
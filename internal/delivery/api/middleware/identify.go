@@ -0,0 +1,59 @@
+package middlewares
+
+import (
+	"GO_APP/internal/delivery/api/user/auth"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RequestIDHeader carries the request ID, in the request and the response
+	RequestIDHeader = "X-Request-ID"
+	// RequestIDKey is the context key holding the request ID
+	RequestIDKey = "request_id"
+	// ActorKey is the context key holding who sent the request
+	ActorKey = "actor"
+	// Anonymous is the actor of a request without a valid token
+	Anonymous = "anonymous"
+)
+
+// RequestID keeps the X-Request-ID header of the request, or makes one up,
+// and sends it back in the response
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			buf := make([]byte, 16)
+			if _, err := rand.Read(buf); err != nil {
+				log.Printf("[middleware][RequestID][rand.Read] error:%+v\n", err)
+			}
+			id = hex.EncodeToString(buf)
+		}
+		context.Set(RequestIDKey, id)
+		context.Header(RequestIDHeader, id)
+		context.Next()
+	}
+}
+
+// Identify names the actor of the request after the username of its token.
+// Unlike Auth it lets requests through without one, as anonymous.
+func Identify() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		actor := Anonymous
+		if tokenString := context.GetHeader("Authorization"); tokenString != "" {
+			claims, err := auth.ParseToken(tokenString)
+			if err != nil {
+				log.Printf("[middleware][Identify][auth.ParseToken] error:%+v\n", err)
+			} else if claims.Username != "" {
+				actor = claims.Username
+			} else {
+				actor = claims.Email
+			}
+		}
+		context.Set(ActorKey, actor)
+		context.Next()
+	}
+}
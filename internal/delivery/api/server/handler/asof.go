@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"GO_APP/internal/inventory"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// asOf parses the as_of query parameter (RFC 3339), ok is false without one
+func asOf(c *gin.Context) (at time.Time, ok bool, err error) {
+	raw := c.Query("as_of")
+	if raw == "" {
+		return time.Time{}, false, nil
+	}
+	at, err = time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, false, &statusError{http.StatusBadRequest, fmt.Errorf("as_of: %q is not an RFC 3339 time", raw)}
+	}
+	return at, true, nil
+}
+
+// inventoryAsOf returns the repository a read is answered from: repo itself,
+// or a snapshot of the inventory as it was at the as_of query parameter when
+// there is one
+func inventoryAsOf(repo repository.ServerRepository, c *gin.Context) (repository.ServerRepository, error) {
+	at, ok, err := asOf(c)
+	if err != nil || !ok {
+		return repo, err
+	}
+	snapshot, err := inventory.Snapshot(repo, at)
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, err}
+	}
+	return snapshot, nil
+}
+
+// serversAsOf returns the live servers of repo, or those there were at the
+// as_of query parameter when there is one, in id order
+func serversAsOf(repo repository.ServerRepository, c *gin.Context) ([]model.Server, error) {
+	at, ok, err := asOf(c)
+	if err != nil {
+		return nil, err
+	}
+	servers := []model.Server{}
+	if !ok {
+		err = repo.Each(repository.ServerFilter{}, func(server model.Server) error {
+			servers = append(servers, server)
+			return nil
+		})
+	} else {
+		var all []model.Server
+		if all, err = inventory.Servers(repo, at); err == nil {
+			for _, server := range all {
+				if !server.DeletedAt.Valid {
+					servers = append(servers, server)
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, &statusError{http.StatusInternalServerError, err}
+	}
+	return servers, nil
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	middlewares "GO_APP/internal/delivery/api/middleware"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordChange appends a change of server to the audit log of tx, naming the
+// actor and request ID the middlewares found. before is nil for a creation,
+// after for a purge.
+func recordChange(tx repository.ServerRepository, c *gin.Context, action string, before, after *model.Server) error {
+	actor := c.GetString(middlewares.ActorKey)
+	if actor == "" {
+		actor = middlewares.Anonymous
+	}
+	entry := model.AuditEntry{Action: action, Actor: actor, RequestID: c.GetString(middlewares.RequestIDKey)}
+	// copy the servers, the caller may go on changing them
+	if before != nil {
+		snapshot := *before
+		entry.Before, entry.ServerID = &snapshot, before.ID
+	}
+	if after != nil {
+		snapshot := *after
+		entry.After, entry.ServerID = &snapshot, after.ID
+	}
+	return tx.Audit().Append(&entry)
+}
+
+// auditPage is the response of GET /audit and GET /servers/:id/history
+type auditPage struct {
+	Entries    []model.AuditEntry `json:"entries"`
+	Pagination pagination         `json:"pagination"`
+}
+
+// listAudit responds with the page of the audit log matching filter, caller
+// names the handler for the logs
+func listAudit(repo repository.ServerRepository, c *gin.Context, filter repository.AuditFilter, caller string) {
+	page, err := repo.Audit().List(filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		log.Printf("[server][%s][Audit.List] error:%+v\n", caller, err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("[server][%s][Audit.List] error:%+v\n", caller, err)
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, auditPage{
+		Entries:    page.Entries,
+		Pagination: pagination{Limit: filter.Limit, NextCursor: page.NextCursor},
+	})
+	if err != nil {
+		log.Printf("[server][%s][respondJSON] error:%+v\n", caller, err)
+	}
+}
+
+// GetAudit lists the audit log of every server, filtered by the server_id,
+// actor, action, request_id, since and until query parameters
+func GetAudit(repo repository.ServerRepository, c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		log.Printf("[server][GetAudit][auditFilter] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	listAudit(repo, c, filter, "GetAudit")
+}
+
+// GetServerHistory lists the audit log of the server given by the id path
+// parameter, which may have been deleted or purged since
+func GetServerHistory(repo repository.ServerRepository, c *gin.Context) {
+	ps := c.Params
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		log.Printf("[server][GetServerHistory][strconv.Atoi] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		log.Printf("[server][GetServerHistory][auditFilter] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.ServerID != 0 && filter.ServerID != uint(id) {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("server_id: %d is not the server %d of the path", filter.ServerID, id))
+		return
+	}
+	filter.ServerID = uint(id)
+	listAudit(repo, c, filter, "GetServerHistory")
+}
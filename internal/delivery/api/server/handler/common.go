@@ -1,7 +1,11 @@
 package handler
 
 import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -23,3 +27,82 @@ func respondJSON(c *gin.Context, status int, payload interface{}) (err error) {
 func respondError(c *gin.Context, code int, message string) {
 	respondJSON(c, code, map[string]string{"error": message})
 }
+
+// validationResponse is the body of a response rejecting fields of a request
+type validationResponse struct {
+	Error  string                 `json:"error"`
+	Fields model.ValidationErrors `json:"fields"`
+}
+
+// respondValidationError makes the error response listing the rejected fields
+func respondValidationError(c *gin.Context, code int, errs model.ValidationErrors) {
+	respondJSON(c, code, validationResponse{Error: "validation failed", Fields: errs})
+}
+
+// decodeError turns an error decoding a JSON body into field errors, so it
+// can be answered the same way as a failed validation
+func decodeError(err error) model.ValidationErrors {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		field := typeErr.Field
+		if field == "" {
+			field = "body"
+		}
+		return model.ValidationErrors{{
+			Field:   field,
+			Rule:    "type",
+			Message: fmt.Sprintf("must be a %s, not a %s", typeErr.Type, typeErr.Value),
+		}}
+	}
+	return model.ValidationErrors{{Field: "body", Rule: "json", Message: err.Error()}}
+}
+
+// conflictResponse is the body of a 409 response, naming the server holding
+// the unique key, conflicting_id is left out when it couldn't be told
+type conflictResponse struct {
+	Error         string `json:"error"`
+	ConflictingID uint   `json:"conflicting_id,omitempty"`
+}
+
+// statusError carries the status code a failed transaction should be answered with
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// respondStatusError makes the error response for err, using the status code
+// of a statusError, 409 for a conflict and 500 for anything else
+func respondStatusError(c *gin.Context, err error) {
+	var conflict *repository.ConflictError
+	if errors.As(err, &conflict) {
+		respondJSON(c, http.StatusConflict, conflictResponse{Error: conflict.Error(), ConflictingID: conflict.ID})
+		return
+	}
+	var se *statusError
+	if errors.As(err, &se) {
+		var errs model.ValidationErrors
+		if errors.As(se.err, &errs) {
+			respondValidationError(c, se.code, errs)
+			return
+		}
+		respondError(c, se.code, se.err.Error())
+		return
+	}
+	respondError(c, http.StatusInternalServerError, err.Error())
+}
+
+// notFoundStatus returns the status code for an error returned while looking up a record
+func notFoundStatus(err error) int {
+	if errors.Is(err, repository.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -1,5 +1,14 @@
 package handler
 
-var (
-	DEFAULT_THESHOLD = 1
-)
+import "GO_APP/internal/model"
+
+// Settings are the deployment settings the server handlers work with
+type Settings struct {
+	// DefaultThreshold is the threshold of the hostname report when the
+	// request doesn't give one
+	DefaultThreshold int
+	// MaxThreshold is the largest threshold the hostname report accepts
+	MaxThreshold int
+	// Validator checks servers and pools before they are stored
+	Validator *model.Validator
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag returns the entity tag of server, its version as a strong ETag
+func etag(server *model.Server) string {
+	return fmt.Sprintf(`"%d"`, server.Version)
+}
+
+// matchesETag reports whether the If-Match header value lists the tag, "*"
+// matching any. Weak tags never match since a write must not go through on
+// a weak comparison.
+func matchesETag(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesETagWeak reports whether the If-None-Match header value lists the
+// tag, "*" matching any. Its comparison is the weak one RFC 9110 asks for,
+// W/"3" matching "3".
+func matchesETagWeak(header, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch makes sure the If-Match header of the request, when there is
+// one, names the current version of server. A stale write is refused with a
+// 412 carrying the current ETag.
+func checkIfMatch(c *gin.Context, server *model.Server) error {
+	header := c.GetHeader("If-Match")
+	if header == "" || matchesETag(header, etag(server)) {
+		return nil
+	}
+	c.Header("ETag", etag(server))
+	return &statusError{http.StatusPreconditionFailed, fmt.Errorf("If-Match %s doesn't match the current ETag %s of server %d", header, etag(server), server.ID)}
+}
+
+// lockServerOr404 gets a Server instance if exists, locking it until the end
+// of the transaction tx is bound to, and checks the If-Match header against it
+func lockServerOr404(tx repository.ServerRepository, id int, c *gin.Context) (*model.Server, error) {
+	server, err := tx.GetForUpdate(uint(id))
+	if err != nil {
+		return nil, &statusError{notFoundStatus(err), err}
+	}
+	if err := checkIfMatch(c, server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// lockDeletedServerOr404 is lockServerOr404 for a soft-deleted server. A
+// server that hasn't been deleted is a 409.
+func lockDeletedServerOr404(tx repository.ServerRepository, id int, c *gin.Context) (*model.Server, error) {
+	server, err := tx.GetDeletedForUpdate(uint(id))
+	if errors.Is(err, repository.ErrNotFound) {
+		if _, liveErr := tx.Get(uint(id)); liveErr == nil {
+			return nil, &statusError{http.StatusConflict, fmt.Errorf("server %d isn't deleted", id)}
+		}
+	}
+	if err != nil {
+		return nil, &statusError{notFoundStatus(err), err}
+	}
+	if err := checkIfMatch(c, server); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EXPORT_FLUSH_ROWS is the number of rows written between two flushes of the response
+const EXPORT_FLUSH_ROWS = 100
+
+// exportWriter writes servers to an export in one format
+type exportWriter interface {
+	begin() error
+	write(server model.Server) error
+	// flush pushes buffered rows to the underlying writer
+	flush() error
+	end() error
+}
+
+// exportHeader is the header of CSV exports, POST /servers/import reads it back
+var exportHeader = []string{"id", "ip", "hostname", "active", "created_at", "updated_at", "pool_id", "labels"}
+
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) begin() error {
+	return e.w.Write(exportHeader)
+}
+
+func (e *csvExport) write(server model.Server) error {
+	poolID := ""
+	if server.PoolID != nil {
+		poolID = strconv.FormatUint(uint64(*server.PoolID), 10)
+	}
+	// labels are written as a JSON object, {} when there are none
+	labels, err := server.Labels.Value()
+	if err != nil {
+		return err
+	}
+	return e.w.Write([]string{
+		strconv.FormatUint(uint64(server.ID), 10),
+		server.IP,
+		server.Hostname,
+		strconv.FormatBool(server.Active),
+		server.CreatedAt.UTC().Format(time.RFC3339),
+		server.UpdatedAt.UTC().Format(time.RFC3339),
+		poolID,
+		labels.(string),
+	})
+}
+
+func (e *csvExport) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) end() error { return e.flush() }
+
+// ndjsonExport writes one server per line
+type ndjsonExport struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExport) begin() error { return nil }
+
+func (e *ndjsonExport) write(server model.Server) error {
+	return e.encoder.Encode(server)
+}
+
+func (e *ndjsonExport) flush() error { return nil }
+
+func (e *ndjsonExport) end() error { return nil }
+
+// jsonExport writes a single JSON array, one element at a time
+type jsonExport struct {
+	w    io.Writer
+	rows int
+}
+
+func (e *jsonExport) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExport) write(server model.Server) error {
+	data, err := json.Marshal(server)
+	if err != nil {
+		return err
+	}
+	if e.rows > 0 {
+		if _, err := io.WriteString(e.w, ","); err != nil {
+			return err
+		}
+	}
+	e.rows++
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExport) flush() error { return nil }
+
+func (e *jsonExport) end() error {
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
+
+// newExportWriter returns the writer of format along with its content type
+func newExportWriter(format string, w io.Writer) (exportWriter, string, error) {
+	switch format {
+	case "csv":
+		return &csvExport{w: csv.NewWriter(w)}, "text/csv; charset=utf-8", nil
+	case "ndjson":
+		return &ndjsonExport{encoder: json.NewEncoder(w)}, "application/x-ndjson", nil
+	case "json":
+		return &jsonExport{w: w}, "application/json; charset=utf-8", nil
+	}
+	return nil, "", fmt.Errorf("format: %q is not one of csv, ndjson, json", format)
+}
+
+// ExportServers streams every server matching the filters of GET /servers as
+// csv, ndjson or json (default) given by the format query parameter. Rows are
+// written as they are read from the database, a page at a time so a slow
+// client doesn't hold a connection, and once the first one is out an error
+// can only end the response early.
+func ExportServers(repo repository.ServerRepository, c *gin.Context) {
+	filter, err := serverFilter(c)
+	if err != nil {
+		log.Printf("[server][ExportServers][serverFilter] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	export, contentType, err := newExportWriter(format, c.Writer)
+	if err != nil {
+		log.Printf("[server][ExportServers][newExportWriter] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// the response starts with the first row, so an error before it can
+	// still be answered with a proper status
+	started := false
+	start := func() error {
+		started = true
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="servers.%s"`, format))
+		c.Status(http.StatusOK)
+		return export.begin()
+	}
+
+	rows := 0
+	err = repo.Each(filter, func(server model.Server) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := export.write(server); err != nil {
+			return err
+		}
+		rows++
+		if rows%EXPORT_FLUSH_ROWS == 0 {
+			if err := export.flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		// no server matched, still answer with an empty export
+		err = start()
+	}
+	if err == nil {
+		err = export.end()
+	}
+	if err != nil {
+		log.Printf("[server][ExportServers][repo.Each] error:%+v\n", err)
+		if !started {
+			respondError(c, http.StatusInternalServerError, err.Error())
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MAX_IMPORT_ROWS bounds the number of rows a single import may carry
+	MAX_IMPORT_ROWS = 10000
+
+	importModeAtomic     = "atomic"
+	importModeBestEffort = "best_effort"
+
+	rowCreated = "created"
+	rowSkipped = "skipped"
+	rowFailed  = "failed"
+)
+
+var (
+	// errRollback makes a transaction roll back once the import has been reported
+	errRollback = errors.New("import rolled back")
+)
+
+// importRow is one server of an import, err is set when the row couldn't be parsed
+type importRow struct {
+	server model.Server
+	err    error
+}
+
+// importRowResult reports what happened to one row, rows are numbered from 1
+type importRowResult struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	// Fields lists the rejected fields of a failed row
+	Fields model.ValidationErrors `json:"fields,omitempty"`
+	Server *model.Server          `json:"server,omitempty"`
+}
+
+// fail marks the row as failed because of err
+func (result *importRowResult) fail(err error) {
+	result.Status, result.Reason = rowFailed, err.Error()
+	errors.As(err, &result.Fields)
+}
+
+// importReport is the response of POST /servers/import
+type importReport struct {
+	Mode   string `json:"mode"`
+	DryRun bool   `json:"dry_run"`
+	// Committed tells whether the created rows were actually stored
+	Committed bool              `json:"committed"`
+	Created   int               `json:"created"`
+	Skipped   int               `json:"skipped"`
+	Failed    int               `json:"failed"`
+	Rows      []importRowResult `json:"rows"`
+}
+
+func (report *importReport) add(result importRowResult) {
+	switch result.Status {
+	case rowCreated:
+		report.Created++
+	case rowSkipped:
+		report.Skipped++
+	case rowFailed:
+		report.Failed++
+	}
+	report.Rows = append(report.Rows, result)
+}
+
+// parseImportCSV reads rows from a CSV document whose header names the IP,
+// Hostname, Active, pool_id and labels columns, in any order and case
+func parseImportCSV(r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"ip", "hostname"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	rows := []importRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(rows) == MAX_IMPORT_ROWS {
+			return nil, fmt.Errorf("an import is limited to %d rows", MAX_IMPORT_ROWS)
+		}
+		if err != nil {
+			// csv.ParseError keeps the reader usable, report the row and go on
+			rows = append(rows, importRow{err: err})
+			continue
+		}
+
+		row := importRow{server: model.Server{IP: field(record, "ip"), Hostname: field(record, "hostname")}}
+		if raw := field(record, "active"); raw != "" {
+			active, err := strconv.ParseBool(raw)
+			if err != nil {
+				row.err = model.ValidationErrors{{Field: "Active", Rule: "type", Message: fmt.Sprintf("%q is not a boolean", raw)}}
+			}
+			row.server.Active = active
+		}
+		if raw := field(record, "pool_id"); raw != "" && row.err == nil {
+			id, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				row.err = model.ValidationErrors{{Field: "PoolID", Rule: "type", Message: fmt.Sprintf("%q is not a pool id", raw)}}
+			}
+			poolID := uint(id)
+			row.server.PoolID = &poolID
+		}
+		if raw := field(record, "labels"); raw != "" && row.err == nil {
+			if err := json.Unmarshal([]byte(raw), &row.server.Labels); err != nil {
+				row.err = model.ValidationErrors{{Field: "Labels", Rule: "type", Message: fmt.Sprintf("%q is not a JSON object of labels", raw)}}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseImportJSON reads rows from a JSON array of servers, an element that
+// doesn't decode fails on its own without failing the others. The array is
+// read an element at a time, so a body past MAX_IMPORT_ROWS is refused
+// without being read whole.
+func parseImportJSON(r io.Reader) ([]importRow, error) {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		if err == nil {
+			err = fmt.Errorf("got %v", token)
+		}
+		return nil, fmt.Errorf("expected a JSON array of servers: %w", err)
+	}
+
+	rows := []importRow{}
+	for decoder.More() {
+		if len(rows) == MAX_IMPORT_ROWS {
+			return nil, fmt.Errorf("an import is limited to %d rows", MAX_IMPORT_ROWS)
+		}
+		element := json.RawMessage{}
+		if err := decoder.Decode(&element); err != nil {
+			return nil, fmt.Errorf("expected a JSON array of servers: %w", err)
+		}
+		row := importRow{}
+		if err := json.Unmarshal(element, &row.server); err != nil {
+			row.err = decodeError(err)
+		}
+		rows = append(rows, row)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, fmt.Errorf("expected a JSON array of servers: %w", err)
+	}
+	return rows, nil
+}
+
+// importServers stores rows the way CreateServer does, one savepoint per row.
+// In atomic mode a single failed row rolls the whole import back, and a dry
+// run always rolls back once every row has been tried.
+func importServers(repo repository.ServerRepository, settings Settings, c *gin.Context, rows []importRow, mode string, dryRun bool) (*importReport, error) {
+	report := &importReport{Mode: mode, DryRun: dryRun, Rows: []importRowResult{}}
+
+	run := func(tx repository.ServerRepository) error {
+		for i, row := range rows {
+			result := importRowResult{Row: i + 1}
+			server := row.server
+
+			if row.err != nil {
+				result.fail(row.err)
+				report.add(result)
+				continue
+			}
+
+			// servers already stored, or created by an earlier row, conflict
+			err := createServer(tx, settings, c, &server)
+			var conflict *repository.ConflictError
+			switch {
+			case errors.As(err, &conflict):
+				result.Status, result.Reason = rowSkipped, conflict.Error()
+			case err != nil:
+				result.fail(err)
+			default:
+				result.Status, result.Server = rowCreated, &server
+			}
+			report.add(result)
+		}
+
+		if dryRun || (mode == importModeAtomic && report.Failed > 0) {
+			return errRollback
+		}
+		return nil
+	}
+
+	var err error
+	if mode == importModeAtomic || dryRun {
+		err = repo.Transaction(run)
+	} else {
+		err = run(repo)
+	}
+	if err != nil && !errors.Is(err, errRollback) {
+		return nil, err
+	}
+	report.Committed = err == nil && report.Created > 0
+	return report, nil
+}
+
+// ImportServers creates servers in bulk from a CSV document (Content-Type
+// text/csv) or a JSON array of servers. The mode query parameter is atomic
+// (default), where any failed row rolls back the whole import, or best_effort,
+// where every row stands on its own. With dry_run=true every row is tried and
+// reported but nothing is stored.
+func ImportServers(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	r := c.Request
+	defer r.Body.Close()
+
+	mode := c.DefaultQuery("mode", importModeAtomic)
+	if mode != importModeAtomic && mode != importModeBestEffort {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("mode: %q is neither %s nor %s", mode, importModeAtomic, importModeBestEffort))
+		return
+	}
+	dryRun, err := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, fmt.Sprintf("dry_run: %q is not a boolean", c.Query("dry_run")))
+		return
+	}
+
+	var rows []importRow
+	if c.ContentType() == "text/csv" {
+		rows, err = parseImportCSV(r.Body)
+	} else {
+		rows, err = parseImportJSON(r.Body)
+	}
+	if err != nil {
+		log.Printf("[server][ImportServers][parse] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := importServers(repo, settings, c, rows, mode, dryRun)
+	if err != nil {
+		log.Printf("[server][ImportServers][importServers] error:%+v\n", err)
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if mode == importModeAtomic && report.Failed > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	err = respondJSON(c, status, report)
+	if err != nil {
+		log.Printf("[server][ImportServers][respondJSON] error:%+v\n", err)
+	}
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetServerLabels sets the labels of the body, a JSON object such as
+// {"region": "eu", "warmup": null}, on the server given by the id path
+// parameter. A null value removes the label, labels left out are kept.
+func SetServerLabels(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	r := c.Request
+	changes := map[string]*string{}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&changes); err != nil {
+		log.Printf("[server][SetServerLabels][decoder.Decode] error:%+v\n", err)
+		respondValidationError(c, http.StatusBadRequest, decodeError(err))
+		return
+	}
+
+	modifyServer(repo, settings, c, "SetServerLabels", func(server *model.Server) error {
+		labels := server.Labels.Clone()
+		if labels == nil {
+			labels = model.Labels{}
+		}
+		for key, value := range changes {
+			if value == nil {
+				delete(labels, key)
+			} else {
+				labels[key] = *value
+			}
+		}
+		server.Labels = labels.Clone()
+		return nil
+	})
+}
+
+// DeleteServerLabel removes the label given by the key path parameter from
+// the server given by the id path parameter, a missing label is no error
+func DeleteServerLabel(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	// the key is a catch-all parameter so prefixed keys keep their slash
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	modifyServer(repo, settings, c, "DeleteServerLabel", func(server *model.Server) error {
+		labels := server.Labels.Clone()
+		delete(labels, key)
+		server.Labels = labels.Clone()
+		return nil
+	})
+}
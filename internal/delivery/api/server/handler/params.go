@@ -0,0 +1,230 @@
+package handler
+
+import (
+	"GO_APP/internal/planner"
+	"GO_APP/internal/repository"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serverFilter reads the filter query parameters shared by the endpoints
+// listing servers: active, hostname, hostname_prefix, cidr, pool_id, selector
+// and include_deleted
+func serverFilter(c *gin.Context) (repository.ServerFilter, error) {
+	filter := repository.ServerFilter{
+		Hostname:       c.Query("hostname"),
+		HostnamePrefix: c.Query("hostname_prefix"),
+	}
+
+	selector, err := labelSelector(c)
+	if err != nil {
+		return filter, err
+	}
+	filter.Selector = selector
+
+	if raw := c.Query("pool_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			return filter, fmt.Errorf("pool_id: %q is not a pool id", raw)
+		}
+		poolID := uint(id)
+		filter.PoolID = &poolID
+	}
+
+	if raw := c.Query("include_deleted"); raw != "" {
+		includeDeleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("include_deleted: %q is not a boolean", raw)
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+
+	if raw := c.Query("active"); raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("active: %q is not a boolean", raw)
+		}
+		filter.Active = &active
+	}
+
+	if raw := c.Query("cidr"); raw != "" {
+		// a bare IP is accepted as a single address network
+		if !strings.Contains(raw, "/") {
+			if ip := net.ParseIP(raw); ip != nil && ip.To4() != nil {
+				raw += "/32"
+			} else {
+				raw += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			return filter, fmt.Errorf("cidr: %q is not a valid CIDR", c.Query("cidr"))
+		}
+		filter.CIDR = network
+	}
+	return filter, nil
+}
+
+// listOptions reads the filter, sort and pagination query parameters of GET /servers
+func listOptions(c *gin.Context) (repository.ListOptions, error) {
+	filter, err := serverFilter(c)
+	opts := repository.ListOptions{
+		ServerFilter: filter,
+		Sort:         c.DefaultQuery("sort", "id"),
+		Cursor:       c.Query("cursor"),
+		Limit:        repository.DefaultLimit,
+	}
+	if err != nil {
+		return opts, err
+	}
+
+	switch order := c.DefaultQuery("order", "asc"); order {
+	case "asc":
+	case "desc":
+		opts.Desc = true
+	default:
+		return opts, fmt.Errorf("order: %q is neither asc nor desc", order)
+	}
+
+	validSort := false
+	for _, field := range repository.SortFields {
+		validSort = validSort || field == opts.Sort
+	}
+	if !validSort {
+		return opts, fmt.Errorf("sort: %q is not one of %s", opts.Sort, strings.Join(repository.SortFields, ", "))
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > repository.MaxLimit {
+			return opts, fmt.Errorf("limit: must be an integer between 1 and %d", repository.MaxLimit)
+		}
+		opts.Limit = limit
+	}
+	return opts, nil
+}
+
+// auditFilter reads the filter and pagination query parameters of the audit
+// log: server_id, actor, action, request_id, since, until, limit and cursor
+func auditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{
+		Actor:     c.Query("actor"),
+		Action:    c.Query("action"),
+		RequestID: c.Query("request_id"),
+		Cursor:    c.Query("cursor"),
+		Limit:     repository.DefaultLimit,
+	}
+
+	if raw := c.Query("server_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || id == 0 {
+			return filter, fmt.Errorf("server_id: %q is not a server id", raw)
+		}
+		filter.ServerID = uint(id)
+	}
+
+	for _, bound := range []struct {
+		name  string
+		value *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, fmt.Errorf("%s: %q is not an RFC 3339 time", bound.name, raw)
+		}
+		*bound.value = at
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > repository.MaxLimit {
+			return filter, fmt.Errorf("limit: must be an integer between 1 and %d", repository.MaxLimit)
+		}
+		filter.Limit = limit
+	}
+	return filter, nil
+}
+
+// labelSelector reads the selector query parameter, a Kubernetes style label
+// selector such as region=eu,warmup!=true
+func labelSelector(c *gin.Context) (repository.Selector, error) {
+	selector, err := repository.ParseSelector(c.Query("selector"))
+	if err != nil {
+		return nil, fmt.Errorf("selector: %w", err)
+	}
+	return selector, nil
+}
+
+// planBounds reads the range of active IPs a consolidation plan aims for:
+// min and max, or thresh when min is left out, the hostnames having at most
+// thresh active IPs being the under-utilised ones. thresh defaults to
+// DefaultThreshold.
+func (s Settings) planBounds(c *gin.Context) (planner.Bounds, error) {
+	thresh := s.DefaultThreshold
+	if raw, ok := c.GetQuery("thresh"); ok {
+		var err error
+		if thresh, err = s.threshold(raw); err != nil {
+			return planner.Bounds{}, err
+		}
+	}
+	bounds := planner.Bounds{Min: thresh + 1}
+	count := func(name string) (*int, error) {
+		raw := c.Query(name)
+		if raw == "" {
+			return nil, nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > s.MaxThreshold {
+			return nil, fmt.Errorf("%s: %q is not an integer between 0 and %d", name, raw, s.MaxThreshold)
+		}
+		return &n, nil
+	}
+	min, err := count("min")
+	if err != nil {
+		return bounds, err
+	}
+	if min != nil {
+		bounds.Min = *min
+	}
+	if bounds.Max, err = count("max"); err != nil {
+		return bounds, err
+	}
+	return bounds, bounds.Validate()
+}
+
+// activeCount reads the op, min and max query parameters selecting the
+// hostnames of the threshold report by their number of active IPs
+func activeCount(c *gin.Context, thresh int) (repository.ActiveCount, error) {
+	count := repository.ActiveCount{Op: c.DefaultQuery("op", repository.OpLTE), Thresh: thresh}
+	for _, bound := range []struct {
+		name  string
+		value **int
+	}{{"min", &count.Min}, {"max", &count.Max}} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return count, fmt.Errorf("%s: %q is not a non-negative integer", bound.name, raw)
+		}
+		*bound.value = &n
+	}
+
+	valid := false
+	for _, op := range repository.Ops {
+		valid = valid || op == count.Op
+	}
+	if !valid {
+		return count, fmt.Errorf("op: %q is not one of %s", count.Op, strings.Join(repository.Ops, ", "))
+	}
+	return count, count.Validate()
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// MERGE_PATCH_CONTENT_TYPE selects a JSON Merge Patch (RFC 7396), the default
+	MERGE_PATCH_CONTENT_TYPE = "application/merge-patch+json"
+	// JSON_PATCH_CONTENT_TYPE selects a JSON Patch (RFC 6902)
+	JSON_PATCH_CONTENT_TYPE = "application/json-patch+json"
+)
+
+// patchableServer is the document a patch applies to, the fields of a server
+// a client may change
+type patchableServer struct {
+	IP       string `json:"IP"`
+	Hostname string `json:"Hostname"`
+	Active   bool   `json:"Active"`
+	PoolID   *uint  `json:"PoolID"`
+	// Labels is an object even when empty, so single labels can be added
+	Labels model.Labels `json:"Labels"`
+}
+
+// applyServerPatch applies the patch of the given content type to server. A
+// malformed patch is a 400, a patch that can't be applied, e.g. a failed test
+// operation or a missing path, a 422.
+func applyServerPatch(server *model.Server, contentType string, patch []byte) error {
+	labels := server.Labels.Clone()
+	if labels == nil {
+		labels = model.Labels{}
+	}
+	doc, err := json.Marshal(patchableServer{IP: server.IP, Hostname: server.Hostname, Active: server.Active, PoolID: server.PoolID, Labels: labels})
+	if err != nil {
+		return err
+	}
+
+	switch contentType {
+	case JSON_PATCH_CONTENT_TYPE:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return &statusError{http.StatusBadRequest, fmt.Errorf("invalid JSON Patch: %w", err)}
+		}
+		if doc, err = ops.Apply(doc); err != nil {
+			return &statusError{http.StatusUnprocessableEntity, fmt.Errorf("applying JSON Patch: %w", err)}
+		}
+	default:
+		if doc, err = jsonpatch.MergePatch(doc, patch); err != nil {
+			return &statusError{http.StatusBadRequest, fmt.Errorf("invalid JSON Merge Patch: %w", err)}
+		}
+	}
+
+	// JSON keys match case sensitively here, unlike encoding/json, so a
+	// patch of "active" can't go unnoticed next to "Active"
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(doc, &fields); err != nil {
+		return &statusError{http.StatusUnprocessableEntity, decodeError(err)}
+	}
+	unknown := model.ValidationErrors{}
+	for name := range fields {
+		if name != "IP" && name != "Hostname" && name != "Active" && name != "PoolID" && name != "Labels" {
+			unknown = append(unknown, model.FieldError{Field: name, Rule: "unknown", Message: "is not a field that can be patched"})
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Slice(unknown, func(i, j int) bool { return unknown[i].Field < unknown[j].Field })
+		return &statusError{http.StatusUnprocessableEntity, unknown}
+	}
+
+	// a removed field gets its zero value
+	patched := patchableServer{}
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return &statusError{http.StatusUnprocessableEntity, decodeError(err)}
+	}
+	server.IP, server.Hostname, server.Active, server.PoolID = patched.IP, patched.Hostname, patched.Active, patched.PoolID
+	server.Labels = patched.Labels.Clone()
+	return nil
+}
+
+// PatchServer changes some fields of a server. The body is a JSON Merge Patch
+// (Content-Type application/merge-patch+json or application/json) or a JSON
+// Patch (application/json-patch+json). Unlike UpdateServer, values sent
+// explicitly are applied even when they are false or empty, and fields left
+// out keep their value.
+func PatchServer(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	r := c.Request
+	contentType := c.ContentType()
+	switch contentType {
+	case MERGE_PATCH_CONTENT_TYPE, JSON_PATCH_CONTENT_TYPE, "application/json", "":
+	default:
+		respondError(c, http.StatusUnsupportedMediaType, fmt.Sprintf("Content-Type %q is neither %s nor %s", contentType, MERGE_PATCH_CONTENT_TYPE, JSON_PATCH_CONTENT_TYPE))
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		log.Printf("[server][PatchServer][io.ReadAll] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	modifyServer(repo, settings, c, "PatchServer", func(server *model.Server) error {
+		return applyServerPatch(server, contentType, patch)
+	})
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/planner"
+	"GO_APP/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// planResponse is the body of the plan endpoints, Applied tells whether the
+// steps were carried out
+type planResponse struct {
+	*planner.Plan
+	Applied bool `json:"applied"`
+}
+
+// buildPlan plans the consolidation of the live servers matching selector
+func buildPlan(repo repository.ServerRepository, selector repository.Selector, bounds planner.Bounds) (*planner.Plan, error) {
+	servers := []model.Server{}
+	err := repo.Each(repository.ServerFilter{Selector: selector}, func(server model.Server) error {
+		servers = append(servers, server)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return planner.Build(servers, bounds), nil
+}
+
+// applyStep carries out one step of a plan and records it in the audit log.
+// A server changed since it was planned is a 409, plan again.
+func applyStep(tx repository.ServerRepository, c *gin.Context, step planner.Step) error {
+	server, err := tx.GetForUpdate(step.ServerID)
+	if err != nil {
+		return err
+	}
+	if server.Version != step.Version {
+		return &statusError{http.StatusConflict, fmt.Errorf("server %d changed while the plan was applied", step.ServerID)}
+	}
+	before := *server
+
+	action := model.AuditUpdate
+	switch step.Action {
+	case planner.ActionMove:
+		server.Hostname = step.To
+		if step.Enable {
+			server.Enable()
+		}
+	case planner.ActionEnable:
+		server.Enable()
+		action = model.AuditEnable
+	case planner.ActionDisable:
+		server.Disable()
+		action = model.AuditDisable
+	}
+	if err := tx.Update(server); err != nil {
+		return err
+	}
+	return recordChange(tx, c, action, &before, server)
+}
+
+// planServers answers with the plan selected by the query parameters,
+// carrying it out in one transaction when apply is set
+func planServers(repo repository.ServerRepository, settings Settings, c *gin.Context, apply bool, caller string) {
+	bounds, err := settings.planBounds(c)
+	if err != nil {
+		log.Printf("[server][%s][planBounds] error:%+v\n", caller, err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	selector, err := labelSelector(c)
+	if err != nil {
+		log.Printf("[server][%s][labelSelector] error:%+v\n", caller, err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var plan *planner.Plan
+	if !apply {
+		plan, err = buildPlan(repo, selector, bounds)
+	} else {
+		// the plan is built in the transaction applying it, from the servers it sees
+		err = repo.Transaction(func(tx repository.ServerRepository) error {
+			var err error
+			if plan, err = buildPlan(tx, selector, bounds); err != nil {
+				return err
+			}
+			for _, step := range plan.Steps {
+				if err := applyStep(tx, c, step); err != nil {
+					log.Printf("[server][%s][applyStep] error:%+v\n", caller, err)
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		// a planned server was deleted in between, plan again
+		err = &statusError{http.StatusConflict, fmt.Errorf("a server was deleted while the plan was applied: %w", err)}
+	}
+	if err != nil {
+		log.Printf("[server][%s][buildPlan] error:%+v\n", caller, err)
+		respondStatusError(c, err)
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, planResponse{Plan: plan, Applied: apply})
+	// Create log for the error
+	if err != nil {
+		log.Printf("[server][%s][respondJSON] error:%+v\n", caller, err)
+	}
+}
+
+// GetServerPlan plans the fewest changes, moves of IPs between hostnames and
+// enabling or disabling them, bringing every hostname within min and max
+// active IPs. Without min, hostnames at or below thresh are brought to
+// thresh+1. selector limits the plan to the servers whose labels match it.
+func GetServerPlan(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	planServers(repo, settings, c, false, "GetServerPlan")
+}
+
+// ApplyServerPlan makes the plan of GetServerPlan and carries it out in one
+// transaction, recording an audit entry for every step
+func ApplyServerPlan(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	planServers(repo, settings, c, true, "ApplyServerPlan")
+}
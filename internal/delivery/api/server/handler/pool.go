@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// poolStatus returns the status code for an error returned while storing a pool
+func poolStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrPoolExists), errors.Is(err, repository.ErrPoolInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// decodePool reads a pool from the request body and validates it with the
+// validator of settings
+func decodePool(settings Settings, c *gin.Context, pool *model.Pool) error {
+	r := c.Request
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(pool); err != nil {
+		return decodeError(err)
+	}
+	return settings.Validator.Pool(pool)
+}
+
+// GetPools lists every pool, sorted by name
+func GetPools(repo repository.ServerRepository, c *gin.Context) {
+	pools, err := repo.Pools().List()
+	if err != nil {
+		log.Printf("[pool][GetPools][repo.List] error:%+v\n", err)
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, pools)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[pool][GetPools][respondJSON] error:%+v\n", err)
+	}
+}
+
+func CreatePool(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	pool := model.Pool{}
+	if err := decodePool(settings, c, &pool); err != nil {
+		log.Printf("[pool][CreatePool][decodePool] error:%+v\n", err)
+		respondStatusError(c, &statusError{http.StatusBadRequest, err})
+		return
+	}
+
+	if err := repo.Pools().Create(&pool); err != nil {
+		log.Printf("[pool][CreatePool][repo.Create] error:%+v\n", err)
+		respondError(c, poolStatus(err), err.Error())
+		return
+	}
+
+	err := respondJSON(c, http.StatusOK, pool)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[pool][CreatePool][respondJSON] error:%+v\n", err)
+	}
+}
+
+func GetPool(repo repository.ServerRepository, c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("[pool][GetPool][strconv.Atoi] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	pool, err := repo.Pools().Get(uint(id))
+	if err != nil {
+		log.Printf("[pool][GetPool][repo.Get] error:%+v\n", err)
+		respondError(c, notFoundStatus(err), err.Error())
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, pool)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[pool][GetPool][respondJSON] error:%+v\n", err)
+	}
+}
+
+// UpdatePool replaces the Name, Purpose, Owner and MinActiveIPs of a pool
+// with the ones of the request body
+func UpdatePool(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("[pool][UpdatePool][strconv.Atoi] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	replacement := model.Pool{}
+	if err := decodePool(settings, c, &replacement); err != nil {
+		log.Printf("[pool][UpdatePool][decodePool] error:%+v\n", err)
+		respondStatusError(c, &statusError{http.StatusBadRequest, err})
+		return
+	}
+
+	var pool *model.Pool
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		var err error
+		if pool, err = tx.Pools().Get(uint(id)); err != nil {
+			return err
+		}
+		pool.Name, pool.Purpose = replacement.Name, replacement.Purpose
+		pool.Owner, pool.MinActiveIPs = replacement.Owner, replacement.MinActiveIPs
+		return tx.Pools().Update(pool)
+	})
+	if err != nil {
+		log.Printf("[pool][UpdatePool][repo.Update] error:%+v\n", err)
+		respondError(c, poolStatus(err), err.Error())
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, pool)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[pool][UpdatePool][respondJSON] error:%+v\n", err)
+	}
+}
+
+// DeletePool deletes a pool, it is refused with 409 while servers belong to it
+func DeletePool(repo repository.ServerRepository, c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		log.Printf("[pool][DeletePool][strconv.Atoi] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		if _, err := tx.Pools().Get(uint(id)); err != nil {
+			return err
+		}
+		return tx.Pools().Delete(uint(id))
+	})
+	if err != nil {
+		log.Printf("[pool][DeletePool][repo.Delete] error:%+v\n", err)
+		respondError(c, poolStatus(err), err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPoolReport counts the active and inactive IPs of every pool and flags the
+// pools with fewer active IPs than their own min_active_ips. With below=true
+// only the flagged pools are listed.
+func GetPoolReport(repo repository.ServerRepository, c *gin.Context) {
+	below := false
+	if raw := c.Query("below"); raw != "" {
+		var err error
+		if below, err = strconv.ParseBool(raw); err != nil {
+			log.Printf("[pool][GetPoolReport][strconv.ParseBool] error:%+v\n", err)
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("below: %q is not a boolean", raw))
+			return
+		}
+	}
+
+	report, err := repo.Pools().Report()
+	if err != nil {
+		log.Printf("[pool][GetPoolReport][repo.Report] error:%+v\n", err)
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if below {
+		flagged := []repository.PoolStats{}
+		for _, stats := range report {
+			if stats.BelowMin {
+				flagged = append(flagged, stats)
+			}
+		}
+		report = flagged
+	}
+
+	err = respondJSON(c, http.StatusOK, report)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[pool][GetPoolReport][respondJSON] error:%+v\n", err)
+	}
+}
@@ -2,67 +2,171 @@ package handler
 
 import (
 	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"gorm.io/gorm"
 )
 
 // getServerOr404 gets a Server instance if exists, or respond the 404 error otherwise
-func getServerOr404(db *gorm.DB, id int, c *gin.Context) (*model.Server, error) {
-	server := model.Server{}
-	err := db.Where("id = ?", id).First(&server).Error
+func getServerOr404(repo repository.ServerRepository, id int, c *gin.Context) (*model.Server, error) {
+	server, err := repo.Get(uint(id))
 	if err != nil {
 		return nil, err
 	}
-	return &server, err
+	return server, err
 }
 
-func GetServerHostName(db *gorm.DB, c *gin.Context) {
+// threshold parses the threshold of the hostname report, a non-negative
+// integer up to MaxThreshold
+func (s Settings) threshold(raw string) (int, error) {
+	thresh, err := strconv.Atoi(raw)
+	if err != nil || thresh < 0 || thresh > s.MaxThreshold {
+		return 0, fmt.Errorf("thresh: %q is not an integer between 0 and %d", raw, s.MaxThreshold)
+	}
+	return thresh, nil
+}
+
+// GetHostnames lists the hostnames whose number of active IPs is at most the
+// thresh query parameter, settings.DefaultThreshold when it is left out. The op query
+// parameter picks another comparison (lt, lte, eq, gte or gt), min and max
+// bound the count as well, and report=true answers with the counts and IPs of
+// every hostname rather than its name alone. selector only counts the servers
+// whose labels match it.
+func GetHostnames(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	thresh := settings.DefaultThreshold
+	if raw, ok := c.GetQuery("thresh"); ok {
+		var err error
+		if thresh, err = settings.threshold(raw); err != nil {
+			log.Printf("[server][GetHostnames][threshold] error:%+v\n", err)
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	hostnameReport(repo, c, thresh, "GetHostnames")
+}
+
+// GetServerHostName is GetHostnames with the threshold in the path. It is
+// deprecated, the response points to its successor.
+func GetServerHostName(repo repository.ServerRepository, settings Settings, c *gin.Context) {
 	ps := c.Params
-	thresh, err := strconv.Atoi(ps.ByName("thresh"))
+	c.Header("Deprecation", "true")
+	c.Header("Link", fmt.Sprintf("</servers/hostnames?thresh=%s>; rel=\"successor-version\"", url.QueryEscape(ps.ByName("thresh"))))
+
+	thresh, err := settings.threshold(ps.ByName("thresh"))
 	if err != nil {
-		// pass default value
-		thresh = DEFAULT_THESHOLD
-		log.Printf("[server][GetServerHostName][strconv.Atoi] error:%+v\n", err)
-		// respondError(w, http.StatusBadRequest, err.Error())
-	}
-
-	// Begin transaction
-	tx := db.Begin()
-	// defer func() {
-	// 	if r := recover(); r != nil {
-	// 		tx.Rollback()
-	// 	}
-	// }()
-
-	hostnames := []string{}
-	err = tx.Table("servers").
-		Select("hostname as Hostnames").
-		Group("hostname").
-		Having("COUNT(CASE WHEN active THEN 1 END) <= ?", thresh).
-		Scan(&hostnames).Error
-	tx.Commit()
+		log.Printf("[server][GetServerHostName][threshold] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	hostnameReport(repo, c, thresh, "GetServerHostName")
+}
 
+// hostnameReport responds with the hostnames selected by thresh and the
+// query parameters of GetHostnames, caller names the handler for the logs
+func hostnameReport(repo repository.ServerRepository, c *gin.Context, thresh int, caller string) {
+	count, err := activeCount(c, thresh)
 	if err != nil {
-		// tx.Rollback()
-		log.Printf("[server][GetServerHostName][db.Table] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		log.Printf("[server][%s][activeCount] error:%+v\n", caller, err)
+		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	selector, err := labelSelector(c)
+	if err != nil {
+		log.Printf("[server][%s][labelSelector] error:%+v\n", caller, err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	report := false
+	if raw := c.Query("report"); raw != "" {
+		if report, err = strconv.ParseBool(raw); err != nil {
+			log.Printf("[server][%s][strconv.ParseBool] error:%+v\n", caller, err)
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("report: %q is not a boolean", raw))
+			return
+		}
+	}
 
-	err = respondJSON(c, http.StatusOK, hostnames)
+	repo, err = inventoryAsOf(repo, c)
+	if err != nil {
+		log.Printf("[server][%s][inventoryAsOf] error:%+v\n", caller, err)
+		respondStatusError(c, err)
+		return
+	}
+
+	var payload interface{}
+	if !report && count.Op == repository.OpLTE && count.Min == nil && count.Max == nil && len(selector) == 0 {
+		if payload, err = repo.HostnamesBelowThreshold(thresh); err != nil {
+			log.Printf("[server][%s][repo.HostnamesBelowThreshold] error:%+v\n", caller, err)
+			respondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		stats, err := repo.HostnameReport(selector, count)
+		if err != nil {
+			log.Printf("[server][%s][repo.HostnameReport] error:%+v\n", caller, err)
+			respondError(c, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload = stats
+		if !report {
+			hostnames := []string{}
+			for _, s := range stats {
+				hostnames = append(hostnames, s.Hostname)
+			}
+			payload = hostnames
+		}
+	}
+
+	err = respondJSON(c, http.StatusOK, payload)
 	// Create log for the error
 	if err != nil {
-		log.Printf("[server][GetServerHostName][respondJSON] error:%+v\n", err)
+		log.Printf("[server][%s][respondJSON] error:%+v\n", caller, err)
+	}
+}
+
+// checkPool makes sure the pool server belongs to, if any, exists
+func checkPool(tx repository.ServerRepository, server *model.Server) error {
+	if server.PoolID == nil {
+		return nil
+	}
+	_, err := tx.Pools().Get(*server.PoolID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &statusError{http.StatusBadRequest, model.ValidationErrors{{
+			Field:   "PoolID",
+			Rule:    "exists",
+			Message: fmt.Sprintf("pool %d doesn't exist", *server.PoolID),
+		}}}
 	}
+	return err
 }
 
-func CreateServer(db *gorm.DB, c *gin.Context) {
+// createServer validates server with the validator of settings and stores it along with its audit entry in
+// a transaction of its own, nested as a savepoint when repo is already bound
+// to a transaction
+func createServer(repo repository.ServerRepository, settings Settings, c *gin.Context, server *model.Server) error {
+	if err := settings.Validator.Server(server); err != nil {
+		return &statusError{http.StatusBadRequest, err}
+	}
+	return repo.Transaction(func(tx repository.ServerRepository) error {
+		if err := checkPool(tx, server); err != nil {
+			return err
+		}
+		if err := tx.Create(server); err != nil {
+			return err
+		}
+		return recordChange(tx, c, model.AuditCreate, nil, server)
+	})
+}
+
+func CreateServer(repo repository.ServerRepository, settings Settings, c *gin.Context) {
 	server := model.Server{}
 	r := c.Request
 	decoder := json.NewDecoder(r.Body)
@@ -70,26 +174,16 @@ func CreateServer(db *gorm.DB, c *gin.Context) {
 
 	if err := decoder.Decode(&server); err != nil {
 		log.Printf("[server][CreateServer][decoder.Decode] error:%+v\n", err)
-		respondError(c, http.StatusBadRequest, err.Error())
+		respondValidationError(c, http.StatusBadRequest, decodeError(err))
 		return
 	}
 
-	// Begin transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	err := tx.Create(&server).Error
+	err := createServer(repo, settings, c, &server)
 	if err != nil {
-		tx.Rollback()
-		log.Printf("[server][CreateServer][db.Create] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		log.Printf("[server][CreateServer][createServer] error:%+v\n", err)
+		respondStatusError(c, err)
 		return
 	}
-	tx.Commit()
 
 	err = respondJSON(c, http.StatusOK, server)
 	// Create log for the error
@@ -98,23 +192,57 @@ func CreateServer(db *gorm.DB, c *gin.Context) {
 	}
 }
 
-func GetAllServer(db *gorm.DB, c *gin.Context) {
-	servers := []model.Server{}
-	err := db.Find(&servers).Error
+// serverPage is the response of GET /servers
+type serverPage struct {
+	Servers    []model.Server `json:"servers"`
+	Pagination pagination     `json:"pagination"`
+}
+
+type pagination struct {
+	Limit int `json:"limit"`
+	// NextCursor is passed as the cursor parameter to get the next page,
+	// it is left out on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func GetAllServer(repo repository.ServerRepository, c *gin.Context) {
+	opts, err := listOptions(c)
+	if err != nil {
+		log.Printf("[server][GetAllServer][listOptions] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	repo, err = inventoryAsOf(repo, c)
 	if err != nil {
-		log.Printf("[server][GetAllServer][db.Find] error:%+v\n", err)
+		log.Printf("[server][GetAllServer][inventoryAsOf] error:%+v\n", err)
+		respondStatusError(c, err)
+		return
+	}
+
+	page, err := repo.List(opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		log.Printf("[server][GetAllServer][repo.List] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("[server][GetAllServer][repo.List] error:%+v\n", err)
 		respondError(c, http.StatusNotFound, err.Error())
 		return
 	}
 
-	err = respondJSON(c, http.StatusOK, servers)
+	err = respondJSON(c, http.StatusOK, serverPage{
+		Servers:    page.Servers,
+		Pagination: pagination{Limit: opts.Limit, NextCursor: page.NextCursor},
+	})
 	// Create log for the error
 	if err != nil {
 		log.Printf("[server][GetAllServer][respondJson] error:%+v\n", err)
 	}
 }
 
-func GetServer(db *gorm.DB, c *gin.Context) {
+func GetServer(repo repository.ServerRepository, c *gin.Context) {
 	ps := c.Params
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
@@ -122,14 +250,19 @@ func GetServer(db *gorm.DB, c *gin.Context) {
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	server, err := getServerOr404(db, id, c)
+	server, err := getServerOr404(repo, id, c)
 	if err != nil {
 		log.Printf("[server][GetServer][getServerOr404] error:%+v\n", err)
-		respondError(c, http.StatusNotFound, err.Error())
+		respondError(c, notFoundStatus(err), err.Error())
 		return
 	}
 
 	if server != nil {
+		c.Header("ETag", etag(server))
+		if matchesETagWeak(c.GetHeader("If-None-Match"), etag(server)) {
+			c.Status(http.StatusNotModified)
+			return
+		}
 		err = respondJSON(c, http.StatusOK, server)
 		// Create log for the error
 		if err != nil {
@@ -139,170 +272,250 @@ func GetServer(db *gorm.DB, c *gin.Context) {
 
 }
 
-func UpdateServer(db *gorm.DB, c *gin.Context) {
-	r := c.Request
+// modifyServer runs modify on the server given by the id path parameter and
+// stores the result, all in one transaction. modify returns a statusError to
+// pick the status of a rejected change, caller names the handler for the logs.
+func modifyServer(repo repository.ServerRepository, settings Settings, c *gin.Context, caller string, modify func(server *model.Server) error) {
 	ps := c.Params
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		log.Printf("[server][UpdateServer][strconv.Atoi] error:%+v\n", err)
+		log.Printf("[server][%s][strconv.Atoi] error:%+v\n", caller, err)
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Begin transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	var server *model.Server
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		server, err = lockServerOr404(tx, id, c)
+		if err != nil {
+			log.Printf("[server][%s][lockServerOr404] error:%+v\n", caller, err)
+			return err
 		}
-	}()
+		before := *server
 
-	server, err := getServerOr404(db, id, c)
-	if err != nil {
-		log.Printf("[server][UpdateServer][getServerOr404] error:%+v\n", err)
-		respondError(c, http.StatusNotFound, err.Error())
-		return
-	}
+		if err := modify(server); err != nil {
+			log.Printf("[server][%s][modify] error:%+v\n", caller, err)
+			return err
+		}
 
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&server); err != nil {
-		log.Printf("[server][UpdateServer][decoder.Decode] error:%+v\n", err)
-		respondError(c, http.StatusBadRequest, err.Error())
-		return
-	}
-	defer r.Body.Close()
+		if err := settings.Validator.Server(server); err != nil {
+			log.Printf("[server][%s][Validator.Server] error:%+v\n", caller, err)
+			return &statusError{http.StatusBadRequest, err}
+		}
+		if err := checkPool(tx, server); err != nil {
+			log.Printf("[server][%s][checkPool] error:%+v\n", caller, err)
+			return err
+		}
 
-	err = tx.Model(&model.Server{}).Where("id = ?", server.ID).Updates(model.Server{IP: server.IP, Hostname: server.Hostname, Active: server.Active}).Error
+		if err := tx.Update(server); err != nil {
+			log.Printf("[server][%s][tx.Update] error:%+v\n", caller, err)
+			return err
+		}
+		if err := recordChange(tx, c, model.AuditUpdate, &before, server); err != nil {
+			log.Printf("[server][%s][recordChange] error:%+v\n", caller, err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
-		log.Printf("[server][UpdateServer][tx.Commit] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		respondStatusError(c, err)
 		return
 	}
-	tx.Commit()
+
+	c.Header("ETag", etag(server))
 	err = respondJSON(c, http.StatusOK, server)
 	// Create log for the error
 	if err != nil {
-		log.Printf("[server][UpdateServer][respondJSON] error:%+v\n", err)
+		log.Printf("[server][%s][respondJSON] error:%+v\n", caller, err)
 	}
+}
 
+// UpdateServer replaces the IP, Hostname, Active, PoolID and Labels fields of a server
+// with the ones of the body, a field missing from the body is set to its zero value
+func UpdateServer(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	r := c.Request
+	replacement := model.Server{}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&replacement); err != nil {
+		log.Printf("[server][UpdateServer][decoder.Decode] error:%+v\n", err)
+		respondValidationError(c, http.StatusBadRequest, decodeError(err))
+		return
+	}
+
+	modifyServer(repo, settings, c, "UpdateServer", func(server *model.Server) error {
+		server.IP = replacement.IP
+		server.Hostname = replacement.Hostname
+		server.Active = replacement.Active
+		server.PoolID = replacement.PoolID
+		server.Labels = replacement.Labels
+		return nil
+	})
 }
 
-func DisableServer(db *gorm.DB, c *gin.Context) {
+// setServerActive enables or disables the server given by the id path
+// parameter, caller names the handler for the logs
+func setServerActive(repo repository.ServerRepository, c *gin.Context, active bool, caller string) {
 	ps := c.Params
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		log.Printf("[server][DisableServer][strconv.Atoi] error:%+v\n", err)
+		log.Printf("[server][%s][strconv.Atoi] error:%+v\n", caller, err)
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	// Begin transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
 
-	server, err := getServerOr404(db, id, c)
-	if err != nil {
-		log.Printf("[server][DisableServer][getServerOr404] error:%+v\n", err)
-		respondError(c, http.StatusNotFound, err.Error())
-		return
-	}
+	var server *model.Server
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		server, err = lockServerOr404(tx, id, c)
+		if err != nil {
+			log.Printf("[server][%s][lockServerOr404] error:%+v\n", caller, err)
+			return err
+		}
+		before := *server
 
-	server.Disable()
+		action := model.AuditDisable
+		if active {
+			action = model.AuditEnable
+		}
 
-	err = tx.Model(&model.Server{}).Where("id = ?", server.ID).Update("active", server.Active).Error
+		if err := tx.SetActive(server, active); err != nil {
+			log.Printf("[server][%s][tx.SetActive] error:%+v\n", caller, err)
+			return err
+		}
+		if err := recordChange(tx, c, action, &before, server); err != nil {
+			log.Printf("[server][%s][recordChange] error:%+v\n", caller, err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		tx.Rollback()
-		log.Printf("[server][DisableServer][tx.Commit] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		respondStatusError(c, err)
 		return
 	}
-	tx.Commit()
+
+	c.Header("ETag", etag(server))
 	err = respondJSON(c, http.StatusOK, server)
 	// Create log for the error
 	if err != nil {
-		log.Printf("[server][DisableServer][respondJSON] error:%+v\n", err)
+		log.Printf("[server][%s][respondJSON] error:%+v\n", caller, err)
 	}
+}
 
+func DisableServer(repo repository.ServerRepository, c *gin.Context) {
+	setServerActive(repo, c, false, "DisableServer")
 }
 
-func EnableServer(db *gorm.DB, c *gin.Context) {
+func EnableServer(repo repository.ServerRepository, c *gin.Context) {
+	setServerActive(repo, c, true, "EnableServer")
+}
+
+// DeleteServer soft-deletes the server given by the id path parameter, or
+// removes it for good with purge=true, which also works on a server that is
+// already soft-deleted
+func DeleteServer(repo repository.ServerRepository, c *gin.Context) {
 	ps := c.Params
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		log.Printf("[server][EnableServer][strconv.Atoi] error:%+v\n", err)
+		log.Printf("[server][DeleteServer][strconv.Atoi] error:%+v\n", err)
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	// Begin transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	purge := false
+	if raw := c.Query("purge"); raw != "" {
+		if purge, err = strconv.ParseBool(raw); err != nil {
+			log.Printf("[server][DeleteServer][strconv.ParseBool] error:%+v\n", err)
+			respondError(c, http.StatusBadRequest, fmt.Sprintf("purge: %q is not a boolean", raw))
+			return
 		}
-	}()
-
-	server, err := getServerOr404(db, id, c)
-	if err != nil {
-		log.Printf("[server][EnableServer][getServerOr404] error:%+v\n", err)
-		respondError(c, http.StatusNotFound, err.Error())
-		return
 	}
 
-	server.Enable()
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		server, err := lockServerOr404(tx, id, c)
+		if purge && errors.Is(err, repository.ErrNotFound) {
+			server, err = lockDeletedServerOr404(tx, id, c)
+		}
+		if err != nil {
+			log.Printf("[server][DeleteServer][lockServerOr404] error:%+v\n", err)
+			return err
+		}
 
-	err = tx.Model(&model.Server{}).Where("id = ?", server.ID).Update("active", server.Active).Error
+		if purge {
+			if err := tx.Purge(uint(id)); err != nil {
+				log.Printf("[server][DeleteServer][tx.Purge] error:%+v\n", err)
+				return err
+			}
+			err = recordChange(tx, c, model.AuditPurge, server, nil)
+		} else {
+			if err := tx.Delete(uint(id)); err != nil {
+				log.Printf("[server][DeleteServer][tx.Delete] error:%+v\n", err)
+				return err
+			}
+			deleted := *server
+			deleted.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+			err = recordChange(tx, c, model.AuditDelete, server, &deleted)
+		}
+		if err != nil {
+			log.Printf("[server][DeleteServer][recordChange] error:%+v\n", err)
+		}
+		return err
+	})
 	if err != nil {
-		tx.Rollback()
-		log.Printf("[server][EnableServer][tx.Commit] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		respondStatusError(c, err)
 		return
 	}
-	tx.Commit()
-	err = respondJSON(c, http.StatusOK, server)
-	// Create log for the error
+
+	err = respondJSON(c, http.StatusOK, nil)
 	if err != nil {
-		log.Printf("[server][EnableServer][respondJSON] error:%+v\n", err)
+		log.Printf("[server][DeleteServer][respondJSON] error:%+v\n", err)
+		respondError(c, http.StatusInternalServerError, err.Error())
 	}
 }
 
-func DeleteServer(db *gorm.DB, c *gin.Context) {
+// RestoreServer undoes the soft delete of the server given by the id path
+// parameter. It is a 409 when the server isn't deleted or when a live server
+// took its unique key in the meantime.
+func RestoreServer(repo repository.ServerRepository, c *gin.Context) {
 	ps := c.Params
 	id, err := strconv.Atoi(ps.ByName("id"))
 	if err != nil {
-		log.Printf("[server][DeleteServer][strconv.Atoi] error:%+v\n", err)
+		log.Printf("[server][RestoreServer][strconv.Atoi] error:%+v\n", err)
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	// Begin transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+
+	var server *model.Server
+	err = repo.Transaction(func(tx repository.ServerRepository) error {
+		server, err = lockDeletedServerOr404(tx, id, c)
+		if err != nil {
+			log.Printf("[server][RestoreServer][lockDeletedServerOr404] error:%+v\n", err)
+			return err
 		}
-	}()
+		before := *server
 
-	_, err = getServerOr404(db, id, c)
+		// its pool may have been deleted while it was
+		if err := checkPool(tx, server); err != nil {
+			log.Printf("[server][RestoreServer][checkPool] error:%+v\n", err)
+			return err
+		}
+		if err := tx.Restore(server); err != nil {
+			log.Printf("[server][RestoreServer][tx.Restore] error:%+v\n", err)
+			return err
+		}
+		if err := recordChange(tx, c, model.AuditRestore, &before, server); err != nil {
+			log.Printf("[server][RestoreServer][recordChange] error:%+v\n", err)
+			return err
+		}
+		return nil
+	})
 	if err != nil {
-		log.Printf("[server][DeleteServer][getServerOr404] error:%+v\n", err)
-		respondError(c, http.StatusNotFound, err.Error())
+		respondStatusError(c, err)
 		return
 	}
 
-	err = tx.Delete(&model.Server{}, id).Error
-	if err != nil {
-		tx.Rollback()
-		log.Printf("[server][DeleteServer][tx.Commit] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
-		return
-	}
-	tx.Commit()
-	err = respondJSON(c, http.StatusOK, nil)
+	c.Header("ETag", etag(server))
+	err = respondJSON(c, http.StatusOK, server)
 	if err != nil {
-		log.Printf("[server][DeleteServer][respondJSON] error:%+v\n", err)
-		respondError(c, http.StatusInternalServerError, err.Error())
+		log.Printf("[server][RestoreServer][respondJSON] error:%+v\n", err)
 	}
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
 	"bytes"
 	"database/sql"
 	"encoding/json"
@@ -20,6 +21,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// testSettings are the settings of the default configuration
+var testSettings = Settings{DefaultThreshold: 1, MaxThreshold: 10000, Validator: model.NewValidator(false)}
+
 // MockDB creates a mocked database and returns a *gorm.DB and a sqlmock.Sqlmock.
 func MockDB() (*gorm.DB, sqlmock.Sqlmock, *sql.DB, error) {
 	mockDB, mock, err := sqlmock.New()
@@ -121,7 +125,7 @@ func TestGetServerOr404(t *testing.T) {
 
 			// Create a new Gin context with the custom response writer
 			c, _ := gin.CreateTestContext(w)
-			server, err := getServerOr404(db, tt.args.id, c)
+			server, err := getServerOr404(repository.NewGormServerRepository(db, repository.UniqueIP), tt.args.id, c)
 
 			assert.Equal(t, tt.wantServer, server)
 			assert.Equal(t, tt.wantError, err)
@@ -145,7 +149,7 @@ func TestGetServerHostName(t *testing.T) {
 		name       string
 		args       args
 		field      field
-		want       []string
+		want       interface{}
 		wantStatus int
 		mock       func()
 	}
@@ -169,21 +173,28 @@ func TestGetServerHostName(t *testing.T) {
 			},
 		},
 		{
-			name: "Get server hostname : bad request on thresh pass default value",
+			name: "Get server hostname : bad request on thresh",
 			args: args{
 				c: &gin.Context{
-					Params: gin.Params{gin.Param{Key: "thresh", Value: ""}},
+					Params: gin.Params{gin.Param{Key: "thresh", Value: "abc"}},
 				},
 			},
 			field:      field{},
-			want:       []string{"mta-prod-1", "mta-prod-2"},
-			wantStatus: http.StatusOK,
-			mock: func() {
-				rows := sqlmock.NewRows([]string{"hostname"}).AddRow("mta-prod-1").AddRow("mta-prod-2")
-				mock.ExpectBegin()
-				mock.ExpectQuery("SELECT (.+)").WithArgs(1).WillReturnRows(rows)
-				mock.ExpectCommit()
+			want:       map[string]string{"error": `thresh: "abc" is not an integer between 0 and 10000`},
+			wantStatus: http.StatusBadRequest,
+			mock:       func() {},
+		},
+		{
+			name: "Get server hostname : bad request on negative thresh",
+			args: args{
+				c: &gin.Context{
+					Params: gin.Params{gin.Param{Key: "thresh", Value: "-1"}},
+				},
 			},
+			field:      field{},
+			want:       map[string]string{"error": `thresh: "-1" is not an integer between 0 and 10000`},
+			wantStatus: http.StatusBadRequest,
+			mock:       func() {},
 		},
 		// {
 		// 	name: "Get server hostname : status code -> 404",
@@ -210,13 +221,17 @@ func TestGetServerHostName(t *testing.T) {
 			w := &testResponseWriter{httptest.NewRecorder()}
 			// Create a new Gin context with the custom response writer
 			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/servers/get_hostname/"+tt.args.c.Params.ByName("thresh"), nil)
 			c.Params = tt.args.c.Params
 
-			GetServerHostName(db, c)
+			GetServerHostName(repository.NewGormServerRepository(db, repository.UniqueIP), testSettings, c)
 			// Check the response status code
 			if w.Code != tt.wantStatus {
 				t.Errorf("Expected status code %v but got %v", tt.wantStatus, w.Code)
 			}
+			if w.Header().Get("Deprecation") != "true" {
+				t.Errorf("Expected the response to be marked deprecated")
+			}
 
 			// Check the response body
 			expectedBody, _ := json.Marshal(tt.want)
@@ -244,6 +259,7 @@ func TestGetServer(t *testing.T) {
 
 	// Create a new Gin context with the custom response writer
 	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/server/1", nil)
 	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 	// Create expected server
 	expectedServer := &model.Server{Hostname: "Test Server"}
@@ -255,7 +271,7 @@ func TestGetServer(t *testing.T) {
 			AddRow(expectedServer.Hostname))
 
 	// Call the function being tested
-	GetServer(db, c)
+	GetServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
 
 	// Check that the response status code is correct
 	if w.Code != http.StatusOK {
@@ -311,7 +327,7 @@ func TestGetAllServer(t *testing.T) {
 	mock.ExpectQuery(`SELECT (.+) FROM "servers"`).WillReturnRows(rows)
 
 	// Call the function being tested
-	GetAllServer(db, c)
+	GetAllServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
 
 	// Check that the response status code is correct
 	if w.Code != http.StatusOK {
@@ -319,13 +335,16 @@ func TestGetAllServer(t *testing.T) {
 	}
 
 	// Check that the response body is correct
-	var server []model.Server
-	err = json.NewDecoder(w.Body).Decode(&server)
+	var page serverPage
+	err = json.NewDecoder(w.Body).Decode(&page)
 	if err != nil {
 		t.Errorf("error decoding response body: %s", err)
 	}
-	if !reflect.DeepEqual(server, expectedServer) {
-		t.Errorf("unexpected server: got %+v, expected %+v", server, expectedServer)
+	if !reflect.DeepEqual(page.Servers, expectedServer) {
+		t.Errorf("unexpected server: got %+v, expected %+v", page.Servers, expectedServer)
+	}
+	if page.Pagination.NextCursor != "" {
+		t.Errorf("unexpected next cursor on the last page: %s", page.Pagination.NextCursor)
 	}
 
 	// Check that there were no unexpected database interactions
@@ -338,7 +357,7 @@ func TestCreateServer(t *testing.T) {
 	db, mock, dbmock, _ := MockDB()
 	defer dbmock.Close()
 	server := model.Server{
-		IP:       "192.168.1.1",
+		IP:       "93.184.216.34",
 		Hostname: "test.com",
 		Active:   true,
 	}
@@ -350,25 +369,27 @@ func TestCreateServer(t *testing.T) {
 		WillReturnRows(
 			sqlmock.NewRows(cols).
 				AddRow(server.Hostname, server.IP, server.Active))
+	mock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	reqBody, err := json.Marshal(server)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// rr := httptest.NewRecorder()
 	req, err := http.NewRequest("POST", "/servers/create", bytes.NewBuffer(reqBody))
 	if err != nil {
 		t.Fatalf("Error creating request: %v", err)
 	}
-	params := gin.Params{gin.Param{Key: "id", Value: "1"}}
-	context := gin.Context{Request: req, Params: params}
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 
-	CreateServer(db, &context)
+	CreateServer(repository.NewGormServerRepository(db, repository.UniqueIP), testSettings, c)
 
-	// if rr.Code != http.StatusOK {
-	// 	t.Errorf("Expected response code %d, but got %d", http.StatusOK, rr.Code)
-	// }
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected response code %d, but got %d", http.StatusOK, w.Code)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
@@ -378,7 +399,7 @@ func TestUpdateServer(t *testing.T) {
 	db, mock, dbmock, _ := MockDB()
 	defer dbmock.Close()
 	server := model.Server{
-		IP:       "192.168.1.1",
+		IP:       "93.184.216.34",
 		Hostname: "test.com",
 		Active:   true,
 	}
@@ -386,26 +407,30 @@ func TestUpdateServer(t *testing.T) {
 
 	mock.ExpectBegin()
 	mock.ExpectQuery("SELECT").WithArgs(id).WillReturnRows(sqlmock.NewRows([]string{"ip", "hostname", "active"}).AddRow(server.IP, server.Hostname, server.Active))
-	mock.ExpectExec("UPDATE").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	// no other server holds the IP
+	mock.ExpectQuery("SELECT (.+) WHERE ip = (.+) AND id <> (.+)").WithArgs(server.IP, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	body, err := json.Marshal(server)
 	if err != nil {
 		t.Fatalf("Error marshaling server: %v", err)
 	}
-	// rr := httptest.NewRecorder()
 	req, err := http.NewRequest("PUT", "/servers/1/update_server", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("Error creating request: %v", err)
 	}
-	params := gin.Params{gin.Param{Key: "id", Value: "1"}}
-	context := gin.Context{Request: req, Params: params}
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 
-	UpdateServer(db, &context)
+	UpdateServer(repository.NewGormServerRepository(db, repository.UniqueIP), testSettings, c)
 
-	// if rr.Code != http.StatusOK {
-	// 	t.Errorf("Expected response code %d, but got %d", http.StatusOK, rr.Code)
-	// }
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected response code %d, but got %d", http.StatusOK, w.Code)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -415,7 +440,7 @@ func TestDisableServer(t *testing.T) {
 	db, mock, dbmock, _ := MockDB()
 	defer dbmock.Close()
 	server := model.Server{
-		IP:       "192.168.1.1",
+		IP:       "93.184.216.34",
 		Hostname: "test.com",
 		Active:   false,
 	}
@@ -425,25 +450,27 @@ func TestDisableServer(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("SELECT").WithArgs(id).WillReturnRows(sqlmock.NewRows([]string{"ip", "hostname", "active"}).AddRow(server.IP, server.Hostname, server.Active))
 	mock.ExpectExec("UPDATE").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	body, err := json.Marshal(server)
 	if err != nil {
 		t.Fatalf("Error marshaling server: %v", err)
 	}
-	// rr := httptest.NewRecorder()
 	req, err := http.NewRequest("PUT", "/servers/1/disable", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("Error creating request: %v", err)
 	}
-	params := gin.Params{gin.Param{Key: "id", Value: "1"}}
-	context := gin.Context{Request: req, Params: params}
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 
-	DisableServer(db, &context)
+	DisableServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
 
-	// if rr.Code != http.StatusOK {
-	// 	t.Errorf("Expected response code %d, but got %d", http.StatusOK, rr.Code)
-	// }
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected response code %d, but got %d", http.StatusOK, w.Code)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -453,7 +480,7 @@ func TestEnableServer(t *testing.T) {
 	db, mock, dbmock, _ := MockDB()
 	defer dbmock.Close()
 	server := model.Server{
-		IP:       "192.168.1.1",
+		IP:       "93.184.216.34",
 		Hostname: "test.com",
 		Active:   false,
 	}
@@ -462,25 +489,27 @@ func TestEnableServer(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("SELECT").WithArgs(id).WillReturnRows(sqlmock.NewRows([]string{"ip", "hostname", "active"}).AddRow(server.IP, server.Hostname, server.Active))
 	mock.ExpectExec("UPDATE").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	body, err := json.Marshal(server)
 	if err != nil {
 		t.Fatalf("Error marshaling server: %v", err)
 	}
-	// rr := httptest.NewRecorder()
 	req, err := http.NewRequest("PUT", "/servers/1/enable", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("Error creating request: %v", err)
 	}
-	params := gin.Params{gin.Param{Key: "id", Value: "1"}}
-	context := gin.Context{Request: req, Params: params}
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 
-	EnableServer(db, &context)
+	EnableServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
 
-	// if rr.Code != http.StatusOK {
-	// 	t.Errorf("Expected response code %d, but got %d", http.StatusOK, rr.Code)
-	// }
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected response code %d, but got %d", http.StatusOK, w.Code)
+	}
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -490,7 +519,7 @@ func TestDeleteServer(t *testing.T) {
 	db, mock, dbmock, _ := MockDB()
 	defer dbmock.Close()
 	server := model.Server{
-		IP:       "192.168.1.1",
+		IP:       "93.184.216.34",
 		Hostname: "test.com",
 		Active:   true,
 	}
@@ -500,25 +529,56 @@ func TestDeleteServer(t *testing.T) {
 	mock.ExpectBegin()
 	mock.ExpectQuery("SELECT").WithArgs(id).WillReturnRows(sqlmock.NewRows([]string{"id", "ip", "hostname", "active"}).AddRow(id, server.IP, server.Hostname, server.Active))
 	mock.ExpectExec("UPDATE").WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_log"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.ExpectCommit()
 
 	body, err := json.Marshal(server)
 	if err != nil {
 		t.Fatalf("Error marshaling server: %v", err)
 	}
-	// rr := httptest.NewRecorder()
 	req, err := http.NewRequest("DELETE", "/servers/1", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("Error creating request: %v", err)
 	}
-	params := gin.Params{gin.Param{Key: "id", Value: "1"}}
-	context := gin.Context{Request: req, Params: params}
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
+
+	DeleteServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected response code %d, but got %d", http.StatusOK, w.Code)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDisableServerStaleIfMatch(t *testing.T) {
+	db, mock, dbmock, _ := MockDB()
+	defer dbmock.Close()
+
+	// the locked row is at version 3, the client read version 2
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT (.+) FROM "servers" WHERE id = (.+) FOR UPDATE`).WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "ip", "hostname", "active", "version"}).AddRow(1, "93.184.216.34", "test.com", true, 3))
+	mock.ExpectRollback()
+
+	req, err := http.NewRequest("PUT", "/servers/1/disable", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("If-Match", `"2"`)
+	w := &testResponseWriter{httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{gin.Param{Key: "id", Value: "1"}}
 
-	DeleteServer(db, &context)
+	DisableServer(repository.NewGormServerRepository(db, repository.UniqueIP), c)
 
-	// if rr.Code != http.StatusOK {
-	// 	t.Errorf("Expected response code %d, but got %d", http.StatusOK, rr.Code)
-	// }
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"GO_APP/internal/planner"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hostnameDiff tells how a hostname is affected by the simulated changes,
+// Selected tells whether the threshold report picks it
+type hostnameDiff struct {
+	Hostname       string `json:"hostname"`
+	ActiveBefore   int    `json:"active_before"`
+	ActiveAfter    int    `json:"active_after"`
+	InactiveBefore int    `json:"inactive_before"`
+	InactiveAfter  int    `json:"inactive_after"`
+	SelectedBefore bool   `json:"selected_before"`
+	SelectedAfter  bool   `json:"selected_after"`
+}
+
+// simulation is the response of POST /servers/simulate
+type simulation struct {
+	// Before and After are the threshold report now and once the changes are made
+	Before []repository.HostnameStats `json:"before"`
+	After  []repository.HostnameStats `json:"after"`
+	// Diff lists the hostnames whose counts or selection change
+	Diff []hostnameDiff `json:"diff"`
+}
+
+// reportState runs the threshold report over repo, returning the selected
+// hostnames and the counts of every hostname matching selector
+func reportState(repo repository.ServerRepository, selector repository.Selector, count repository.ActiveCount) ([]repository.HostnameStats, map[string]repository.HostnameStats, error) {
+	selected, err := repo.HostnameReport(selector, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := repo.HostnameReport(selector, repository.ActiveCount{Op: repository.OpGTE})
+	if err != nil {
+		return nil, nil, err
+	}
+	byName := map[string]repository.HostnameStats{}
+	for _, stats := range all {
+		byName[stats.Hostname] = stats
+	}
+	return selected, byName, nil
+}
+
+// diffReports compares the threshold report before and after the changes
+func diffReports(before, after []repository.HostnameStats, allBefore, allAfter map[string]repository.HostnameStats) []hostnameDiff {
+	selected := func(report []repository.HostnameStats, hostname string) bool {
+		for _, stats := range report {
+			if stats.Hostname == hostname {
+				return true
+			}
+		}
+		return false
+	}
+
+	names := []string{}
+	for name := range allBefore {
+		names = append(names, name)
+	}
+	for name := range allAfter {
+		if _, ok := allBefore[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diff := []hostnameDiff{}
+	for _, name := range names {
+		d := hostnameDiff{
+			Hostname:       name,
+			ActiveBefore:   allBefore[name].Active,
+			ActiveAfter:    allAfter[name].Active,
+			InactiveBefore: allBefore[name].Inactive,
+			InactiveAfter:  allAfter[name].Inactive,
+			SelectedBefore: selected(before, name),
+			SelectedAfter:  selected(after, name),
+		}
+		if d.ActiveBefore != d.ActiveAfter || d.InactiveBefore != d.InactiveAfter || d.SelectedBefore != d.SelectedAfter {
+			diff = append(diff, d)
+		}
+	}
+	return diff
+}
+
+// SimulateServers answers with the threshold report as it would be after the
+// changes of the body, a JSON array of steps like those of a plan:
+// {"action": "disable", "server_id": 3}, {"action": "enable", ...} or
+// {"action": "move", "server_id": 4, "to": "mta-prod-2", "enable": true}.
+// Nothing is stored. It takes the query parameters of GetHostnames, thresh,
+// op, min, max, selector and as_of, and lists the hostnames the changes affect.
+func SimulateServers(repo repository.ServerRepository, settings Settings, c *gin.Context) {
+	r := c.Request
+	changes := []planner.Step{}
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+
+	if err := decoder.Decode(&changes); err != nil {
+		log.Printf("[server][SimulateServers][decoder.Decode] error:%+v\n", err)
+		respondValidationError(c, http.StatusBadRequest, decodeError(err))
+		return
+	}
+
+	thresh := settings.DefaultThreshold
+	if raw, ok := c.GetQuery("thresh"); ok {
+		var err error
+		if thresh, err = settings.threshold(raw); err != nil {
+			log.Printf("[server][SimulateServers][threshold] error:%+v\n", err)
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	count, err := activeCount(c, thresh)
+	if err != nil {
+		log.Printf("[server][SimulateServers][activeCount] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	selector, err := labelSelector(c)
+	if err != nil {
+		log.Printf("[server][SimulateServers][labelSelector] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	servers, err := serversAsOf(repo, c)
+	if err != nil {
+		log.Printf("[server][SimulateServers][serversAsOf] error:%+v\n", err)
+		respondStatusError(c, err)
+		return
+	}
+	changed, err := planner.Apply(servers, changes)
+	if err != nil {
+		log.Printf("[server][SimulateServers][planner.Apply] error:%+v\n", err)
+		respondStatusError(c, &statusError{http.StatusBadRequest, err})
+		return
+	}
+
+	// both states are reported by the same aggregation, over in-memory copies
+	result := simulation{}
+	before, allBefore, err := reportState(repository.NewMemoryServerRepositoryFrom(repo.UniqueKey(), servers), selector, count)
+	if err == nil {
+		var allAfter map[string]repository.HostnameStats
+		result.Before = before
+		result.After, allAfter, err = reportState(repository.NewMemoryServerRepositoryFrom(repo.UniqueKey(), changed), selector, count)
+		result.Diff = diffReports(result.Before, result.After, allBefore, allAfter)
+	}
+	if err != nil {
+		log.Printf("[server][SimulateServers][reportState] error:%+v\n", err)
+		respondError(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = respondJSON(c, http.StatusOK, result)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[server][SimulateServers][respondJSON] error:%+v\n", err)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"GO_APP/internal/repository"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DEFAULT_UTILISATION_RANGE is how far back a utilisation series goes
+	// when the request gives no from
+	DEFAULT_UTILISATION_RANGE = 7 * 24 * time.Hour
+	// DEFAULT_UTILISATION_STEP is the bucket size of a utilisation series
+	DEFAULT_UTILISATION_STEP = time.Hour
+)
+
+// utilisationSeries is the response of GET /hostnames/:hostname/utilisation
+type utilisationSeries struct {
+	Hostname string                        `json:"hostname"`
+	From     time.Time                     `json:"from"`
+	To       time.Time                     `json:"to"`
+	Step     string                        `json:"step"`
+	Points   []repository.UtilisationPoint `json:"points"`
+}
+
+// parseStep parses a Go duration such as 15m or 6h, or a number of days such as 7d
+func parseStep(raw string) (time.Duration, error) {
+	if days := strings.TrimSuffix(raw, "d"); days != raw {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("step: %q is not a duration", raw)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	step, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("step: %q is not a duration", raw)
+	}
+	return step, nil
+}
+
+// utilisationQuery reads the from and to (RFC 3339) and step query parameters
+// of a utilisation series, the last week by the hour by default
+func utilisationQuery(c *gin.Context) (repository.UtilisationQuery, error) {
+	query := repository.UtilisationQuery{Hostname: c.Param("hostname"), To: time.Now(), Step: DEFAULT_UTILISATION_STEP}
+	if raw := c.Query("to"); raw != "" {
+		to, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, fmt.Errorf("to: %q is not an RFC 3339 time", raw)
+		}
+		query.To = to
+	}
+	query.From = query.To.Add(-DEFAULT_UTILISATION_RANGE)
+	if raw := c.Query("from"); raw != "" {
+		from, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, fmt.Errorf("from: %q is not an RFC 3339 time", raw)
+		}
+		query.From = from
+	}
+	if raw := c.Query("step"); raw != "" {
+		step, err := parseStep(raw)
+		if err != nil {
+			return query, err
+		}
+		query.Step = step
+	}
+	return query, query.Validate()
+}
+
+// GetHostnameUtilisation answers with the active and inactive IP counts the
+// cron recorded for a hostname between from and to, averaged over buckets of
+// step. A hostname without samples has no points.
+func GetHostnameUtilisation(repo repository.ServerRepository, c *gin.Context) {
+	query, err := utilisationQuery(c)
+	if err != nil {
+		log.Printf("[server][GetHostnameUtilisation][utilisationQuery] error:%+v\n", err)
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	points, err := repo.Utilisation().Series(query)
+	if err != nil {
+		log.Printf("[server][GetHostnameUtilisation][repo.Series] error:%+v\n", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrInvalidSeries) {
+			status = http.StatusBadRequest
+		}
+		respondError(c, status, err.Error())
+		return
+	}
+
+	series := utilisationSeries{Hostname: query.Hostname, From: query.From, To: query.To, Step: query.Step.String(), Points: points}
+	err = respondJSON(c, http.StatusOK, series)
+	// Create log for the error
+	if err != nil {
+		log.Printf("[server][GetHostnameUtilisation][respondJSON] error:%+v\n", err)
+	}
+}
@@ -1,17 +1,19 @@
 package server
 
 import (
+	middlewares "GO_APP/internal/delivery/api/middleware"
 	"GO_APP/internal/delivery/api/server/handler"
+	"GO_APP/internal/repository"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 type ServerRoute struct {
-	Router *gin.Engine
-	DB     *gorm.DB
+	Router   *gin.Engine
+	Repo     repository.ServerRepository
+	Settings handler.Settings
 }
 
 // This will have server related api
@@ -20,48 +22,148 @@ type ServerRoute struct {
 
 func (a *ServerRoute) SetServiceRouter() {
 	router := a.Router
+	// every change is audited under the request ID and the actor of its token
+	router.Use(middlewares.RequestID(), middlewares.Identify())
 	// Routing for handling the projects
+	router.GET("/servers/hostnames", a.GetHostnames)
+	// deprecated, use /servers/hostnames?thresh=
 	router.GET("/servers/get_hostname/:thresh", a.GetServerHostname)
 	router.GET("/servers", a.GetAllServer)
+	router.GET("/servers/plan", a.GetServerPlan)
+	router.POST("/servers/plan/apply", a.ApplyServerPlan)
+	router.POST("/servers/simulate", a.SimulateServers)
+	router.GET("/servers/export", a.ExportServers)
 	router.GET("/server/:id", a.GetServer)
 	router.POST("/servers/create", a.CreateServer)
+	router.POST("/servers/import", a.ImportServers)
 	router.PUT("/servers/:id/update_server", a.UpdateServer)
+	router.PATCH("/servers/:id", a.PatchServer)
 	router.PUT("/servers/:id/disable", a.DisableServer)
 	router.PUT("/servers/:id/enable", a.EnableServer)
 	router.DELETE("/servers/:id", a.DeleteServer)
+	router.POST("/servers/:id/restore", a.RestoreServer)
+	router.GET("/servers/:id/history", a.GetServerHistory)
+	router.PUT("/servers/:id/labels", a.SetServerLabels)
+	router.DELETE("/servers/:id/labels/*key", a.DeleteServerLabel)
+	router.GET("/audit", a.GetAudit)
+	router.GET("/hostnames/:hostname/utilisation", a.GetHostnameUtilisation)
+	// servers are grouped in pools, each with its own minimum of active IPs
+	router.GET("/pools", a.GetPools)
+	router.POST("/pools", a.CreatePool)
+	router.GET("/pools/report", a.GetPoolReport)
+	router.GET("/pools/:id", a.GetPool)
+	router.PUT("/pools/:id", a.UpdatePool)
+	router.DELETE("/pools/:id", a.DeletePool)
 }
 
 // Handlers to manage Server Data
 func (a *ServerRoute) CreateServer(c *gin.Context) {
-	handler.CreateServer(a.DB, c)
+	handler.CreateServer(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) ImportServers(c *gin.Context) {
+	handler.ImportServers(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) GetHostnames(c *gin.Context) {
+	handler.GetHostnames(a.Repo, a.Settings, c)
 }
 
 func (a *ServerRoute) GetServerHostname(c *gin.Context) {
-	handler.GetServerHostName(a.DB, c)
+	handler.GetServerHostName(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) GetServerPlan(c *gin.Context) {
+	handler.GetServerPlan(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) ApplyServerPlan(c *gin.Context) {
+	handler.ApplyServerPlan(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) SimulateServers(c *gin.Context) {
+	handler.SimulateServers(a.Repo, a.Settings, c)
 }
 
 func (a *ServerRoute) GetServer(c *gin.Context) {
-	handler.GetServer(a.DB, c)
+	handler.GetServer(a.Repo, c)
 }
 
 func (a *ServerRoute) GetAllServer(c *gin.Context) {
-	handler.GetAllServer(a.DB, c)
+	handler.GetAllServer(a.Repo, c)
+}
+
+func (a *ServerRoute) ExportServers(c *gin.Context) {
+	handler.ExportServers(a.Repo, c)
 }
 
 func (a *ServerRoute) UpdateServer(c *gin.Context) {
-	handler.UpdateServer(a.DB, c)
+	handler.UpdateServer(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) PatchServer(c *gin.Context) {
+	handler.PatchServer(a.Repo, a.Settings, c)
 }
 
 func (a *ServerRoute) DisableServer(c *gin.Context) {
-	handler.DisableServer(a.DB, c)
+	handler.DisableServer(a.Repo, c)
 }
 
 func (a *ServerRoute) EnableServer(c *gin.Context) {
-	handler.EnableServer(a.DB, c)
+	handler.EnableServer(a.Repo, c)
 }
 
 func (a *ServerRoute) DeleteServer(c *gin.Context) {
-	handler.DeleteServer(a.DB, c)
+	handler.DeleteServer(a.Repo, c)
+}
+
+func (a *ServerRoute) RestoreServer(c *gin.Context) {
+	handler.RestoreServer(a.Repo, c)
+}
+
+func (a *ServerRoute) GetServerHistory(c *gin.Context) {
+	handler.GetServerHistory(a.Repo, c)
+}
+
+func (a *ServerRoute) SetServerLabels(c *gin.Context) {
+	handler.SetServerLabels(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) DeleteServerLabel(c *gin.Context) {
+	handler.DeleteServerLabel(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) GetAudit(c *gin.Context) {
+	handler.GetAudit(a.Repo, c)
+}
+
+func (a *ServerRoute) GetHostnameUtilisation(c *gin.Context) {
+	handler.GetHostnameUtilisation(a.Repo, c)
+}
+
+// Handlers to manage Pool Data
+func (a *ServerRoute) GetPools(c *gin.Context) {
+	handler.GetPools(a.Repo, c)
+}
+
+func (a *ServerRoute) CreatePool(c *gin.Context) {
+	handler.CreatePool(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) GetPoolReport(c *gin.Context) {
+	handler.GetPoolReport(a.Repo, c)
+}
+
+func (a *ServerRoute) GetPool(c *gin.Context) {
+	handler.GetPool(a.Repo, c)
+}
+
+func (a *ServerRoute) UpdatePool(c *gin.Context) {
+	handler.UpdatePool(a.Repo, a.Settings, c)
+}
+
+func (a *ServerRoute) DeletePool(c *gin.Context) {
+	handler.DeletePool(a.Repo, c)
 }
 
 // Run the ServerRoute on it's router
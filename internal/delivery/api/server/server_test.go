@@ -0,0 +1,1146 @@
+package server
+
+import (
+	"GO_APP/internal/delivery/api/server/handler"
+	"GO_APP/internal/delivery/api/user/auth"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// newTestRoute returns a ServerRoute backed by an in-memory repository, so the
+// whole api can be exercised without a database.
+func newTestRoute() *ServerRoute {
+	gin.SetMode(gin.TestMode)
+	route := &ServerRoute{
+		Router: gin.New(),
+		Repo:   repository.NewMemoryServerRepository(repository.UniqueIP),
+		Settings: handler.Settings{
+			DefaultThreshold: 1,
+			MaxThreshold:     10000,
+			Validator:        model.NewValidator(false),
+		},
+	}
+	route.SetServiceRouter()
+	return route
+}
+
+func (a *ServerRoute) serve(t *testing.T, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Error marshaling body: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServerRouteLifecycle(t *testing.T) {
+	route := newTestRoute()
+
+	servers := []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: false},
+		{IP: "11.0.0.3", Hostname: "mta-prod-2", Active: true},
+		{IP: "11.0.0.4", Hostname: "mta-prod-2", Active: true},
+		{IP: "11.0.0.5", Hostname: "mta-prod-3", Active: false},
+	}
+	for i := range servers {
+		rr := route.serve(t, "POST", "/servers/create", servers[i])
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &servers[i]))
+		assert.NotZero(t, servers[i].ID)
+	}
+
+	rr := route.serve(t, "GET", "/servers/get_hostname/1", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["mta-prod-1","mta-prod-3"]`, rr.Body.String())
+
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/disable", servers[2].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/enable", servers[4].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "GET", "/servers/get_hostname/1", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["mta-prod-1","mta-prod-2","mta-prod-3"]`, rr.Body.String())
+
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/update_server", servers[0].ID), map[string]interface{}{"IP": "11.0.0.1", "Hostname": "mta-prod-9", "Active": true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "GET", fmt.Sprintf("/server/%d", servers[0].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "mta-prod-9", got.Hostname)
+	assert.Equal(t, "11.0.0.1", got.IP)
+
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", servers[1].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "GET", fmt.Sprintf("/server/%d", servers[1].ID), nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", servers[1].ID), nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = route.serve(t, "GET", "/servers", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, len(servers)-1)
+}
+
+type listResponse struct {
+	Servers    []model.Server `json:"servers"`
+	Pagination struct {
+		Limit      int    `json:"limit"`
+		NextCursor string `json:"next_cursor"`
+	} `json:"pagination"`
+}
+
+func TestGetAllServerPagination(t *testing.T) {
+	route := newTestRoute()
+	for i := 1; i <= 7; i++ {
+		server := model.Server{IP: fmt.Sprintf("11.0.%d.%d", i%2, i), Hostname: fmt.Sprintf("mta-prod-%d", 8-i), Active: i%2 == 0}
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	// walk every page sorted by hostname in descending order
+	hostnames := []string{}
+	path := "/servers?limit=3&sort=hostname&order=desc"
+	for pages := 0; path != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("pagination doesn't terminate")
+		}
+		rr := route.serve(t, "GET", path, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		page := listResponse{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+		assert.Equal(t, 3, page.Pagination.Limit)
+		for _, server := range page.Servers {
+			hostnames = append(hostnames, server.Hostname)
+		}
+		path = ""
+		if page.Pagination.NextCursor != "" {
+			path = "/servers?limit=3&sort=hostname&order=desc&cursor=" + page.Pagination.NextCursor
+		}
+	}
+	assert.Equal(t, []string{"mta-prod-7", "mta-prod-6", "mta-prod-5", "mta-prod-4", "mta-prod-3", "mta-prod-2", "mta-prod-1"}, hostnames)
+
+	rr := route.serve(t, "GET", "/servers?active=true&cidr=11.0.0.0/24", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, 3)
+
+	for _, path := range []string{
+		"/servers?limit=0",
+		"/servers?sort=ip",
+		"/servers?order=up",
+		"/servers?active=maybe",
+		"/servers?cidr=11.0.0.0/99",
+		"/servers?cursor=garbage",
+	} {
+		rr := route.serve(t, "GET", path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+}
+
+type importResponse struct {
+	Committed bool `json:"committed"`
+	Created   int  `json:"created"`
+	Skipped   int  `json:"skipped"`
+	Failed    int  `json:"failed"`
+	Rows      []struct {
+		Row    int           `json:"row"`
+		Status string        `json:"status"`
+		Server *model.Server `json:"server"`
+	} `json:"rows"`
+}
+
+func (a *ServerRoute) serveCSV(t *testing.T, path, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("POST", path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func (a *ServerRoute) count(t *testing.T) int {
+	rr := a.serve(t, "GET", "/servers", nil)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	return len(page.Servers)
+}
+
+func TestImportServers(t *testing.T) {
+	route := newTestRoute()
+	rr := route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	csv := "Hostname,IP,Active\n" +
+		"mta-prod-1,11.0.0.1,true\n" + // already stored
+		"mta-prod-2,11.0.0.2,true\n" +
+		"mta-prod-2,11.0.0.2,false\n" + // repeats row 2
+		"mta-prod-3,11.0.0.3,maybe\n" +
+		",11.0.0.4,false\n"
+
+	// atomic: the failed rows roll everything back
+	rr = route.serveCSV(t, "/servers/import", csv)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+	report := importResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.False(t, report.Committed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Skipped)
+	assert.Equal(t, 2, report.Failed)
+	statuses := []string{}
+	for _, row := range report.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	assert.Equal(t, []string{"skipped", "created", "skipped", "failed", "failed"}, statuses)
+	assert.Equal(t, 1, route.count(t))
+
+	// a dry run reports the same but never stores
+	rr = route.serveCSV(t, "/servers/import?mode=best_effort&dry_run=true", csv)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	report = importResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.False(t, report.Committed)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, route.count(t))
+
+	// best effort keeps the rows that went through
+	rr = route.serveCSV(t, "/servers/import?mode=best_effort", csv)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	report = importResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.True(t, report.Committed)
+	assert.Equal(t, 2, route.count(t))
+
+	// JSON arrays go through the same path
+	rr = route.serve(t, "POST", "/servers/import", []model.Server{
+		{IP: "11.0.0.5", Hostname: "mta-prod-5"},
+		{IP: "11.0.0.6", Hostname: "mta-prod-5", Active: true},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4, route.count(t))
+
+	// labels are a JSON object, a row whose labels don't parse fails
+	rr = route.serveCSV(t, "/servers/import?mode=best_effort", "ip,hostname,labels\n"+
+		`11.0.0.7,mta-prod-7,"{""region"":""eu""}"`+"\n"+
+		"11.0.0.8,mta-prod-8,region=eu\n")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	report = importResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Created)
+	if assert.Len(t, report.Rows, 2) {
+		assert.Equal(t, model.Labels{"region": "eu"}, report.Rows[0].Server.Labels)
+		assert.Equal(t, "failed", report.Rows[1].Status)
+	}
+
+	for _, path := range []string{"/servers/import?mode=maybe", "/servers/import?dry_run=maybe"} {
+		rr := route.serve(t, "POST", path, []model.Server{})
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+	rr = route.serveCSV(t, "/servers/import", "name,active\nmta-prod-1,true\n")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// a JSON array is refused once past the cap, before the rest of it is read
+	body := "[" + strings.Repeat(`{"ip":"11.0.0.9"},`, handler.MAX_IMPORT_ROWS+1) + "this is never read"
+	req, err := http.NewRequest("POST", "/servers/import", strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	route.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "limited to")
+	rr = route.serve(t, "POST", "/servers/import", model.Server{IP: "11.0.0.9"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestExportServers(t *testing.T) {
+	route := newTestRoute()
+	for i := 1; i <= 3; i++ {
+		server := model.Server{IP: fmt.Sprintf("11.0.0.%d", i), Hostname: "mta-prod-1", Active: i != 2}
+		if i == 3 {
+			server.Labels = model.Labels{"region": "eu", "example.com/warmup": "true"}
+		}
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr := route.serve(t, "GET", "/servers/export", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rr.Header().Get("Content-Type"))
+	servers := []model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &servers))
+	assert.Len(t, servers, 3)
+
+	rr = route.serve(t, "GET", "/servers/export?format=ndjson&active=true", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.Len(t, lines, 2)
+	got := model.Server{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+	assert.Equal(t, "11.0.0.3", got.IP)
+
+	rr = route.serve(t, "GET", "/servers/export?format=csv&cidr=11.0.0.2", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `attachment; filename="servers.csv"`, rr.Header().Get("Content-Disposition"))
+	lines = strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.Equal(t, "id,ip,hostname,active,created_at,updated_at,pool_id,labels", lines[0])
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[1], "2,11.0.0.2,mta-prod-1,false,"))
+	assert.True(t, strings.HasSuffix(lines[1], ",{}"), lines[1])
+
+	// a CSV export can be imported back
+	fresh := newTestRoute()
+	rr = route.serve(t, "GET", "/servers/export?format=csv", nil)
+	rr = fresh.serveCSV(t, "/servers/import", rr.Body.String())
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 3, fresh.count(t))
+	rr = fresh.serve(t, "GET", "/servers?selector="+url.QueryEscape("region=eu,example.com/warmup=true"), nil)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	if assert.Len(t, page.Servers, 1) {
+		assert.Equal(t, "11.0.0.3", page.Servers[0].IP)
+	}
+
+	rr = route.serve(t, "GET", "/servers/export?hostname=mta-prod-9", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+
+	for _, path := range []string{"/servers/export?format=xml", "/servers/export?active=maybe"} {
+		rr := route.serve(t, "GET", path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+}
+
+func TestServerValidation(t *testing.T) {
+	route := newTestRoute()
+
+	rr := route.serve(t, "POST", "/servers/create", map[string]interface{}{"IP": "::ffff:93.184.216.34", "Hostname": "mta-prod-1"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	created := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Equal(t, "93.184.216.34", created.IP)
+
+	type fieldErrors struct {
+		Error  string             `json:"error"`
+		Fields []model.FieldError `json:"fields"`
+	}
+	tests := []struct {
+		method, path string
+		body         interface{}
+		want         []string
+	}{
+		{"POST", "/servers/create", map[string]interface{}{"IP": "192.168.1.1", "Hostname": "mta_prod"}, []string{"IP", "Hostname"}},
+		{"POST", "/servers/create", map[string]interface{}{"IP": "93.184.216.35", "Hostname": "mta-prod-1", "Active": "yes"}, []string{"Active"}},
+		{"PUT", fmt.Sprintf("/servers/%d/update_server", created.ID), map[string]interface{}{"IP": "not an ip", "Hostname": "mta-prod-1"}, []string{"IP"}},
+		{"PUT", fmt.Sprintf("/servers/%d/update_server", created.ID), map[string]interface{}{"Active": true}, []string{"IP", "Hostname"}},
+		{"PATCH", fmt.Sprintf("/servers/%d", created.ID), map[string]interface{}{"Hostname": "-mta"}, []string{"Hostname"}},
+	}
+	for _, tt := range tests {
+		rr := route.serve(t, tt.method, tt.path, tt.body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, tt.path)
+		got := fieldErrors{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		fields := []string{}
+		for _, fe := range got.Fields {
+			fields = append(fields, fe.Field)
+		}
+		assert.Equal(t, tt.want, fields, tt.body)
+	}
+
+	// a rejected update leaves the server untouched
+	rr = route.serve(t, "GET", fmt.Sprintf("/server/%d", created.ID), nil)
+	got := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "93.184.216.34", got.IP)
+}
+
+func TestServerConflicts(t *testing.T) {
+	route := newTestRoute()
+	servers := []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1"},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1"},
+	}
+	for i := range servers {
+		rr := route.serve(t, "POST", "/servers/create", servers[i])
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &servers[i]))
+	}
+
+	type conflictBody struct {
+		Error         string `json:"error"`
+		ConflictingID uint   `json:"conflicting_id"`
+	}
+	for _, rr := range []*httptest.ResponseRecorder{
+		// the same IP in another canonical form is still the same IP
+		route.serve(t, "POST", "/servers/create", model.Server{IP: "::ffff:11.0.0.1", Hostname: "mta-prod-2"}),
+		route.serve(t, "PATCH", fmt.Sprintf("/servers/%d", servers[1].ID), map[string]string{"IP": "11.0.0.1"}),
+	} {
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		got := conflictBody{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		assert.Equal(t, servers[0].ID, got.ConflictingID)
+		assert.Contains(t, got.Error, "11.0.0.1")
+	}
+
+	// once deleted, the IP can be used again
+	rr := route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", servers[0].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "PATCH", fmt.Sprintf("/servers/%d", servers[1].ID), map[string]string{"IP": "11.0.0.1"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func (a *ServerRoute) servePatch(t *testing.T, path, contentType, body string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("PATCH", path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestPatchServer(t *testing.T) {
+	route := newTestRoute()
+	rr := route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	server := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+	path := fmt.Sprintf("/server/%d", server.ID)
+	patchPath := fmt.Sprintf("/servers/%d", server.ID)
+
+	get := func() model.Server {
+		rr := route.serve(t, "GET", path, nil)
+		got := model.Server{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+		return got
+	}
+
+	// an explicit false is applied, the fields left out are kept
+	rr = route.servePatch(t, patchPath, "application/merge-patch+json", `{"Active": false}`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got := get()
+	assert.False(t, got.Active)
+	assert.Equal(t, "11.0.0.1", got.IP)
+	assert.Equal(t, "mta-prod-1", got.Hostname)
+
+	rr = route.servePatch(t, patchPath, "application/json-patch+json",
+		`[{"op": "test", "path": "/Active", "value": false}, {"op": "replace", "path": "/Active", "value": true}, {"op": "replace", "path": "/Hostname", "value": "mta-prod-2"}]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got = get()
+	assert.True(t, got.Active)
+	assert.Equal(t, "mta-prod-2", got.Hostname)
+
+	// a PUT replaces everything, an Active left out becomes false
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/update_server", server.ID), map[string]string{"IP": "11.0.0.1", "Hostname": "mta-prod-2"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.False(t, get().Active)
+
+	for _, tt := range []struct {
+		contentType, body string
+		want              int
+	}{
+		{"application/json-patch+json", `[{"op": "test", "path": "/Active", "value": true}]`, http.StatusUnprocessableEntity},
+		{"application/json-patch+json", `[{"op": "replace", "path": "/ID", "value": 7}]`, http.StatusUnprocessableEntity},
+		{"application/json-patch+json", `{"op": "replace"}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `{"active": true}`, http.StatusUnprocessableEntity},
+		{"application/merge-patch+json", `{"Active": "yes"}`, http.StatusUnprocessableEntity},
+		{"application/merge-patch+json", `{"IP": null}`, http.StatusBadRequest},
+		{"application/merge-patch+json", `not json`, http.StatusBadRequest},
+		{"text/plain", `{"Active": true}`, http.StatusUnsupportedMediaType},
+	} {
+		rr := route.servePatch(t, patchPath, tt.contentType, tt.body)
+		assert.Equal(t, tt.want, rr.Code, tt.body)
+	}
+	// rejected patches leave the server untouched
+	got = get()
+	assert.False(t, got.Active)
+	assert.Equal(t, "11.0.0.1", got.IP)
+
+	rr = route.servePatch(t, "/servers/99", "application/merge-patch+json", `{"Active": true}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func (a *ServerRoute) serveWithHeader(t *testing.T, method, path, header, value string, body interface{}) *httptest.ResponseRecorder {
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Error marshaling body: %v", err)
+	}
+	req, err := http.NewRequest(method, path, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error creating request: %v", err)
+	}
+	req.Header.Set(header, value)
+	rr := httptest.NewRecorder()
+	a.Router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestServerETag(t *testing.T) {
+	route := newTestRoute()
+	rr := route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	server := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+	path := fmt.Sprintf("/server/%d", server.ID)
+
+	rr = route.serve(t, "GET", path, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	first := rr.Header().Get("ETag")
+	assert.Equal(t, `"1"`, first)
+
+	rr = route.serveWithHeader(t, "GET", path, "If-None-Match", first, nil)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	// If-None-Match compares weakly
+	rr = route.serveWithHeader(t, "GET", path, "If-None-Match", `"9", W/`+first, nil)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+
+	// the first operator wins, the second one read the same version and is refused
+	rr = route.serveWithHeader(t, "PUT", fmt.Sprintf("/servers/%d/disable", server.ID), "If-Match", first, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	second := rr.Header().Get("ETag")
+	assert.Equal(t, `"2"`, second)
+
+	for _, rr := range []*httptest.ResponseRecorder{
+		route.serveWithHeader(t, "PUT", fmt.Sprintf("/servers/%d/enable", server.ID), "If-Match", first, nil),
+		route.serveWithHeader(t, "PUT", fmt.Sprintf("/servers/%d/update_server", server.ID), "If-Match", first, map[string]interface{}{"IP": "11.0.0.1", "Hostname": "mta-prod-2"}),
+		route.serveWithHeader(t, "PATCH", fmt.Sprintf("/servers/%d", server.ID), "If-Match", first, map[string]interface{}{"Active": true}),
+		route.serveWithHeader(t, "DELETE", fmt.Sprintf("/servers/%d", server.ID), "If-Match", first, nil),
+	} {
+		assert.Equal(t, http.StatusPreconditionFailed, rr.Code)
+		assert.Equal(t, second, rr.Header().Get("ETag"))
+	}
+
+	rr = route.serve(t, "GET", path, nil)
+	got := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.False(t, got.Active)
+	assert.Equal(t, "mta-prod-1", got.Hostname)
+
+	rr = route.serveWithHeader(t, "PATCH", fmt.Sprintf("/servers/%d", server.ID), "If-Match", `"7", `+second, map[string]interface{}{"Active": true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"3"`, rr.Header().Get("ETag"))
+
+	rr = route.serveWithHeader(t, "DELETE", fmt.Sprintf("/servers/%d", server.ID), "If-Match", "*", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	route := newTestRoute()
+	servers := []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: true},
+	}
+	for i := range servers {
+		rr := route.serve(t, "POST", "/servers/create", servers[i])
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &servers[i]))
+	}
+	deleted := fmt.Sprintf("/servers/%d", servers[0].ID)
+
+	rr := route.serve(t, "POST", deleted+"/restore", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = route.serve(t, "DELETE", deleted, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "GET", "/servers?include_deleted=true", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, 2)
+	assert.True(t, page.Servers[0].DeletedAt.Valid)
+
+	rr = route.serve(t, "GET", "/servers?include_deleted=maybe", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = route.serve(t, "POST", deleted+"/restore", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"2"`, rr.Header().Get("ETag"))
+	assert.Equal(t, 2, route.count(t))
+
+	// the IP of a deleted server can be reused, after which it can't be restored
+	rr = route.serve(t, "DELETE", deleted, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-2"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "POST", deleted+"/restore", nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = route.serve(t, "DELETE", deleted+"?purge=true", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "POST", deleted+"/restore", nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	// a live server is purged right away
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d?purge=true", servers[1].ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "GET", "/servers?include_deleted=true", nil)
+	page = listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, 1)
+	assert.Equal(t, "mta-prod-2", page.Servers[0].Hostname)
+
+	rr = route.serve(t, "DELETE", deleted+"?purge=yes", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+type auditResponse struct {
+	Entries []model.AuditEntry `json:"entries"`
+}
+
+func TestRestoreServerOutOfDeletedPool(t *testing.T) {
+	route := newTestRoute()
+	rr := route.serve(t, "POST", "/pools", map[string]interface{}{"name": "marketing-eu", "purpose": "marketing"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	pool := model.Pool{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pool))
+	rr = route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", PoolID: &pool.ID})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	server := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+
+	// deleting the pool takes the deleted server out of it
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", server.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/pools/%d", pool.ID), nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = route.serve(t, "POST", fmt.Sprintf("/servers/%d/restore", server.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+	assert.Nil(t, server.PoolID)
+
+	// a deleted server still in a pool that is gone isn't restored
+	missing := uint(7)
+	deleted := model.Server{IP: "11.0.0.3", Hostname: "mta-prod-3", PoolID: &missing, Version: 1}
+	deleted.ID, deleted.DeletedAt = 3, gorm.DeletedAt{Time: time.Now(), Valid: true}
+	route.Repo = repository.NewMemoryServerRepositoryFrom(repository.UniqueIP, []model.Server{deleted})
+	route.Router = gin.New()
+	route.SetServiceRouter()
+	rr = route.serve(t, "POST", "/servers/3/restore", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "pool 7 doesn't exist")
+}
+func (a *ServerRoute) audit(t *testing.T, path string) []model.AuditEntry {
+	rr := a.serve(t, "GET", path, nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := auditResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	return page.Entries
+}
+
+func TestServerAudit(t *testing.T) {
+	route := newTestRoute()
+	auth.SetJWTKey("test-key")
+	token, err := auth.GenerateJWT("kriti@example.com", "kriti")
+	if err != nil {
+		t.Fatalf("Error generating token: %v", err)
+	}
+
+	data, _ := json.Marshal(model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true})
+	req, _ := http.NewRequest("POST", "/servers/create", bytes.NewReader(data))
+	req.Header.Set("Authorization", token)
+	req.Header.Set("X-Request-ID", "req-create")
+	rr := httptest.NewRecorder()
+	route.Router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "req-create", rr.Header().Get("X-Request-ID"))
+	server := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/disable", server.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	generated := rr.Header().Get("X-Request-ID")
+	assert.NotEmpty(t, generated)
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", server.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// refused and rolled back changes leave no trace
+	rr = route.serve(t, "POST", "/servers/create", model.Server{IP: "10.0.0.1", Hostname: "mta-prod-2"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = route.serveCSV(t, "/servers/import?dry_run=true", "ip,hostname\n11.0.0.2,mta-prod-2\n")
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	history := route.audit(t, fmt.Sprintf("/servers/%d/history", server.ID))
+	if assert.Len(t, history, 3) {
+		assert.Equal(t, model.AuditCreate, history[0].Action)
+		assert.Equal(t, "kriti", history[0].Actor)
+		assert.Equal(t, "req-create", history[0].RequestID)
+		assert.Nil(t, history[0].Before)
+		assert.True(t, history[0].After.Active)
+
+		assert.Equal(t, model.AuditDisable, history[1].Action)
+		assert.Equal(t, "anonymous", history[1].Actor)
+		assert.Equal(t, generated, history[1].RequestID)
+		assert.True(t, history[1].Before.Active)
+		assert.False(t, history[1].After.Active)
+
+		assert.Equal(t, model.AuditDelete, history[2].Action)
+		assert.True(t, history[2].After.DeletedAt.Valid)
+	}
+
+	assert.Len(t, route.audit(t, "/audit?actor=kriti"), 1)
+	assert.Len(t, route.audit(t, "/audit?action=disable&request_id="+generated), 1)
+	assert.Len(t, route.audit(t, fmt.Sprintf("/audit?server_id=%d&limit=2", server.ID)), 2)
+	assert.Empty(t, route.audit(t, "/audit?until=2000-01-01T00:00:00Z"))
+
+	rr = route.serve(t, "GET", "/audit?since=yesterday", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = route.serve(t, "GET", "/audit?cursor=nope", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestInventoryAsOf(t *testing.T) {
+	route := newTestRoute()
+	before := time.Now()
+	server := model.Server{}
+	rr := route.serve(t, "POST", "/servers/create", model.Server{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &server))
+	enabled := time.Now()
+	rr = route.serve(t, "PUT", fmt.Sprintf("/servers/%d/disable", server.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	asOf := func(at time.Time) string { return url.QueryEscape(at.Format(time.RFC3339Nano)) }
+
+	rr = route.serve(t, "GET", "/servers/get_hostname/0", nil)
+	assert.JSONEq(t, `["mta-prod-1"]`, rr.Body.String())
+	rr = route.serve(t, "GET", "/servers/get_hostname/0?as_of="+asOf(enabled), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+
+	rr = route.serve(t, "GET", "/servers?active=true&as_of="+asOf(enabled), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, 1)
+
+	rr = route.serve(t, "GET", "/servers?as_of="+asOf(before), nil)
+	page = listResponse{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Empty(t, page.Servers)
+
+	// the server was active at enabled, so disabling it changes its hostname
+	disable := []map[string]interface{}{{"action": "disable", "server_id": server.ID}}
+	rr = route.serve(t, "POST", "/servers/simulate?as_of="+asOf(enabled), disable)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"active_before":1,"active_after":0`)
+	rr = route.serve(t, "POST", "/servers/simulate?as_of=last-tuesday", disable)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = route.serve(t, "GET", "/servers?as_of=last-tuesday", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	rr = route.serve(t, "GET", "/servers/get_hostname/1?as_of=last-tuesday", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHostnameReport(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: false},
+		{IP: "11.0.0.3", Hostname: "mta-prod-2", Active: true},
+		{IP: "11.0.0.4", Hostname: "mta-prod-2", Active: true},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr := route.serve(t, "GET", "/servers/get_hostname/1?report=true", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[{"hostname": "mta-prod-1", "active": 1, "inactive": 1, "total": 2, "active_ips": ["11.0.0.1"], "inactive_ips": ["11.0.0.2"]}]`, rr.Body.String())
+
+	rr = route.serve(t, "GET", "/servers/get_hostname/2?op=eq", nil)
+	assert.JSONEq(t, `["mta-prod-2"]`, rr.Body.String())
+	rr = route.serve(t, "GET", "/servers/get_hostname/0?op=gt&max=1", nil)
+	assert.JSONEq(t, `["mta-prod-1"]`, rr.Body.String())
+
+	for _, query := range []string{"op=ne", "min=-1", "min=2&max=1", "report=maybe"} {
+		rr = route.serve(t, "GET", "/servers/get_hostname/1?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestGetHostnames(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-2", Active: true},
+		{IP: "11.0.0.3", Hostname: "mta-prod-2", Active: true},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr := route.serve(t, "GET", "/servers/hostnames?thresh=2", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["mta-prod-1","mta-prod-2"]`, rr.Body.String())
+	assert.Empty(t, rr.Header().Get("Deprecation"))
+
+	rr = route.serve(t, "GET", "/servers/hostnames", nil)
+	assert.JSONEq(t, `["mta-prod-1"]`, rr.Body.String())
+
+	// the default threshold is configured per deployment
+	route.Settings.DefaultThreshold = 0
+	rr = route.serve(t, "GET", "/servers/hostnames", nil)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+
+	for _, thresh := range []string{"", "abc", "-1", "1.5", "10001"} {
+		rr = route.serve(t, "GET", "/servers/hostnames?thresh="+thresh, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, thresh)
+	}
+
+	rr = route.serve(t, "GET", "/servers/get_hostname/2", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["mta-prod-1","mta-prod-2"]`, rr.Body.String())
+	assert.Equal(t, "true", rr.Header().Get("Deprecation"))
+	assert.Equal(t, `</servers/hostnames?thresh=2>; rel="successor-version"`, rr.Header().Get("Link"))
+
+	rr = route.serve(t, "GET", "/servers/get_hostname/abc", nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestPools(t *testing.T) {
+	route := newTestRoute()
+
+	rr := route.serve(t, "POST", "/pools", map[string]interface{}{"name": " transactional-eu ", "purpose": "transactional", "min_active_ips": 2})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	pool := model.Pool{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pool))
+	assert.Equal(t, "transactional-eu", pool.Name)
+
+	rr = route.serve(t, "POST", "/pools", map[string]interface{}{"name": "transactional-eu", "purpose": "transactional"})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	rr = route.serve(t, "POST", "/pools", map[string]interface{}{"name": "bulk", "purpose": "bulk", "min_active_ips": -1})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"purpose"`)
+	assert.Contains(t, rr.Body.String(), `"field":"min_active_ips"`)
+
+	// servers may only join a pool that exists
+	rr = route.serve(t, "POST", "/servers/create", map[string]interface{}{"IP": "11.0.0.1", "Hostname": "mta-prod-1", "PoolID": pool.ID + 1})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"PoolID"`)
+	for _, ip := range []string{"11.0.0.1", "11.0.0.2"} {
+		rr = route.serve(t, "POST", "/servers/create", map[string]interface{}{"IP": ip, "Hostname": "mta-prod-1", "Active": true, "PoolID": pool.ID})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	rr = route.serve(t, "POST", "/servers/create", map[string]interface{}{"IP": "11.0.0.3", "Hostname": "mta-prod-2", "Active": true})
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = route.serve(t, "GET", fmt.Sprintf("/servers?pool_id=%d", pool.ID), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := struct{ Servers []model.Server }{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page.Servers, 2)
+
+	report := []repository.PoolStats{}
+	rr = route.serve(t, "GET", "/pools/report?below=true", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	assert.Empty(t, report)
+
+	// raising the minimum above the active IPs flags the pool
+	rr = route.serve(t, "PUT", fmt.Sprintf("/pools/%d", pool.ID), map[string]interface{}{"name": "transactional-eu", "purpose": "transactional", "min_active_ips": 3})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "GET", "/pools/report?below=true", nil)
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	if assert.Len(t, report, 1) {
+		assert.Equal(t, 2, report[0].Active)
+		assert.Equal(t, []string{"11.0.0.1", "11.0.0.2"}, report[0].ActiveIPs)
+		assert.True(t, report[0].BelowMin)
+	}
+
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/pools/%d", pool.ID), nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+	for _, id := range []int{1, 2} {
+		rr = route.serve(t, "DELETE", fmt.Sprintf("/servers/%d", id), nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	rr = route.serve(t, "DELETE", fmt.Sprintf("/pools/%d", pool.ID), nil)
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = route.serve(t, "GET", fmt.Sprintf("/pools/%d", pool.ID), nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = route.serve(t, "GET", "/pools", nil)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+}
+
+func TestServerLabels(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []map[string]interface{}{
+		{"IP": "11.0.0.1", "Hostname": "mta-prod-1", "Active": true, "Labels": map[string]string{"region": "eu"}},
+		{"IP": "11.0.0.2", "Hostname": "mta-prod-2", "Active": true, "Labels": map[string]string{"region": "us"}},
+		{"IP": "11.0.0.3", "Hostname": "mta-prod-2", "Active": true},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	rr := route.serve(t, "PUT", "/servers/1/labels", map[string]interface{}{"example.com/warmup": "true", "provider": "ovh"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got := model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, model.Labels{"region": "eu", "example.com/warmup": "true", "provider": "ovh"}, got.Labels)
+
+	rr = route.serve(t, "PUT", "/servers/1/labels", map[string]interface{}{"provider": nil})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	rr = route.serve(t, "DELETE", "/servers/1/labels/example.com/warmup", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got = model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, model.Labels{"region": "eu"}, got.Labels)
+
+	rr = route.serve(t, "PUT", "/servers/1/labels", map[string]interface{}{"region": "eu west"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"Labels"`)
+	rr = route.serve(t, "PUT", "/servers/9/labels", map[string]interface{}{"region": "eu"})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = route.serve(t, "GET", "/servers?selector="+url.QueryEscape("region in (eu,us),region!=us"), nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	page := struct{ Servers []model.Server }{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	if assert.Len(t, page.Servers, 1) {
+		assert.Equal(t, "11.0.0.1", page.Servers[0].IP)
+	}
+
+	// only the servers matching the selector are counted
+	rr = route.serve(t, "GET", "/servers/hostnames?thresh=1&selector=region", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `["mta-prod-1","mta-prod-2"]`, rr.Body.String())
+	rr = route.serve(t, "GET", "/servers/hostnames?thresh=1", nil)
+	assert.JSONEq(t, `["mta-prod-1"]`, rr.Body.String())
+
+	// an empty value is a valid one
+	rr = route.serve(t, "GET", "/servers?selector=region%3D", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	for _, path := range []string{"/servers?selector=region+in+eu", "/servers/hostnames?selector=%21"} {
+		rr = route.serve(t, "GET", path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+
+	// label changes are audited like any other update
+	entries := route.audit(t, "/servers/1/history")
+	if assert.Len(t, entries, 4) {
+		assert.Equal(t, model.AuditUpdate, entries[3].Action)
+		assert.Equal(t, model.Labels{"region": "eu"}, entries[3].After.Labels)
+	}
+
+	// PUT replaces the labels like every other field
+	rr = route.serve(t, "PUT", "/servers/2/update_server", map[string]interface{}{"IP": "11.0.0.2", "Hostname": "mta-prod-2", "Labels": map[string]string{"region": "eu"}})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got = model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, model.Labels{"region": "eu"}, got.Labels)
+	rr = route.serve(t, "PUT", "/servers/2/update_server", map[string]interface{}{"IP": "11.0.0.2", "Hostname": "mta-prod-2"})
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got = model.Server{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Empty(t, got.Labels)
+}
+
+func TestServerPlan(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.3", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.4", Hostname: "mta-prod-2", Active: false},
+		{IP: "11.0.0.5", Hostname: "mta-prod-3", Active: true},
+		{IP: "11.0.0.6", Hostname: "mta-prod-3", Active: false},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	type plan struct {
+		Steps []struct {
+			Action   string
+			ServerID uint `json:"server_id"`
+			To       string
+		}
+		Unresolved []string
+		Applied    bool
+	}
+	rr := route.serve(t, "GET", "/servers/plan?thresh=1&max=2", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got := plan{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.False(t, got.Applied)
+	assert.Empty(t, got.Unresolved)
+	if assert.Len(t, got.Steps, 3) {
+		// the surplus of mta-prod-1 moves, the inactive IP of mta-prod-2 is enabled
+		assert.Equal(t, "move", got.Steps[0].Action)
+		assert.Equal(t, uint(3), got.Steps[0].ServerID)
+		assert.Equal(t, "mta-prod-2", got.Steps[0].To)
+		assert.Equal(t, "enable", got.Steps[1].Action)
+		assert.Equal(t, uint(4), got.Steps[1].ServerID)
+		assert.Equal(t, uint(6), got.Steps[2].ServerID)
+	}
+	// planning changes nothing
+	rr = route.serve(t, "GET", "/servers/hostnames?thresh=1", nil)
+	assert.JSONEq(t, `["mta-prod-2","mta-prod-3"]`, rr.Body.String())
+
+	for _, path := range []string{"/servers/plan?min=3&max=2", "/servers/plan?thresh=-1", "/servers/plan?max=x", "/servers/plan?selector=%21"} {
+		rr = route.serve(t, "GET", path, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+	}
+
+	rr = route.serve(t, "POST", "/servers/plan/apply?min=2&max=2", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got = plan{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.True(t, got.Applied)
+	assert.Empty(t, got.Unresolved)
+
+	rr = route.serve(t, "GET", "/servers/hostnames?thresh=0&op=gte&report=true", nil)
+	report := []repository.HostnameStats{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+	for _, stats := range report {
+		assert.Equal(t, 2, stats.Active, stats.Hostname)
+	}
+
+	// every step is audited
+	entries := route.audit(t, "/audit?limit=100")
+	if assert.Len(t, entries, 6+3) {
+		assert.Equal(t, model.AuditUpdate, entries[6].Action)
+		assert.Equal(t, "mta-prod-2", entries[6].After.Hostname)
+		assert.Equal(t, model.AuditEnable, entries[8].Action)
+	}
+
+	// nothing is left to do
+	rr = route.serve(t, "POST", "/servers/plan/apply?min=2&max=2", nil)
+	got = plan{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Empty(t, got.Steps)
+}
+
+func TestSimulateServers(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.3", Hostname: "mta-prod-2", Active: true},
+		{IP: "11.0.0.4", Hostname: "mta-prod-2", Active: false},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+
+	changes := []map[string]interface{}{
+		{"action": "disable", "server_id": 1},
+		{"action": "move", "server_id": 4, "to": "mta-prod-3", "enable": true},
+	}
+	rr := route.serve(t, "POST", "/servers/simulate?thresh=1", changes)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	got := struct {
+		Before, After []repository.HostnameStats
+		Diff          []map[string]interface{}
+	}{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	hostnames := func(report []repository.HostnameStats) []string {
+		names := []string{}
+		for _, stats := range report {
+			names = append(names, stats.Hostname)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"mta-prod-2"}, hostnames(got.Before))
+	assert.Equal(t, []string{"mta-prod-1", "mta-prod-2", "mta-prod-3"}, hostnames(got.After))
+	if assert.Len(t, got.Diff, 3) {
+		assert.Equal(t, map[string]interface{}{
+			"hostname": "mta-prod-1", "active_before": 2.0, "active_after": 1.0, "inactive_before": 0.0, "inactive_after": 1.0,
+			"selected_before": false, "selected_after": true,
+		}, got.Diff[0])
+		assert.Equal(t, "mta-prod-2", got.Diff[1]["hostname"])
+		assert.Equal(t, 0.0, got.Diff[1]["inactive_after"])
+		assert.Equal(t, "mta-prod-3", got.Diff[2]["hostname"])
+		assert.Equal(t, 1.0, got.Diff[2]["active_after"])
+	}
+
+	// nothing was stored
+	rr = route.serve(t, "GET", "/servers/hostnames?thresh=1", nil)
+	assert.JSONEq(t, `["mta-prod-2"]`, rr.Body.String())
+	assert.Len(t, route.audit(t, "/audit"), 4)
+
+	rr = route.serve(t, "POST", "/servers/simulate", []map[string]interface{}{{"action": "disable", "server_id": 9}})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"field":"changes[0].server_id"`)
+	rr = route.serve(t, "POST", "/servers/simulate?thresh=x", changes)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestHostnameUtilisation(t *testing.T) {
+	route := newTestRoute()
+	for _, server := range []model.Server{
+		{IP: "11.0.0.1", Hostname: "mta-prod-1", Active: true},
+		{IP: "11.0.0.2", Hostname: "mta-prod-1", Active: false},
+	} {
+		rr := route.serve(t, "POST", "/servers/create", server)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	}
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for day := 0; day < 3; day++ {
+		_, err := repository.RecordUtilisation(route.Repo, start.Add(time.Duration(day)*24*time.Hour))
+		assert.NoError(t, err)
+	}
+
+	rr := route.serve(t, "GET", "/hostnames/mta-prod-1/utilisation?from=2024-05-01T00:00:00Z&to=2024-05-08T00:00:00Z&step=2d", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	series := struct {
+		Hostname string
+		Step     string
+		Points   []repository.UtilisationPoint
+	}{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &series))
+	assert.Equal(t, "mta-prod-1", series.Hostname)
+	assert.Equal(t, "48h0m0s", series.Step)
+	if assert.Len(t, series.Points, 2) {
+		assert.Equal(t, 2, series.Points[0].Samples)
+		assert.Equal(t, 1.0, series.Points[0].Active)
+		assert.Equal(t, 1.0, series.Points[0].Inactive)
+	}
+
+	// the last week by the hour by default, long before the samples
+	rr = route.serve(t, "GET", "/hostnames/mta-prod-1/utilisation", nil)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"points":[]`)
+
+	for _, query := range []string{"from=yesterday", "to=2024-05-01", "step=fortnight", "step=0s", "step=1s", "from=2024-05-08T00:00:00Z&to=2024-05-01T00:00:00Z"} {
+		rr = route.serve(t, "GET", "/hostnames/mta-prod-1/utilisation?"+query, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
@@ -7,7 +7,12 @@ import (
 	"github.com/dgrijalva/jwt-go"
 )
 
-var jwtKey = []byte("supersecretkey")
+var jwtKey []byte
+
+// SetJWTKey sets the key used to sign and validate tokens.
+func SetJWTKey(key string) {
+	jwtKey = []byte(key)
+}
 
 type JWTClaim struct {
 	Username string `json:"username"`
@@ -29,6 +34,12 @@ func GenerateJWT(email string, username string) (tokenString string, err error)
 	return
 }
 func ValidateToken(signedToken string) (err error) {
+	_, err = ParseToken(signedToken)
+	return
+}
+
+// ParseToken validates signedToken and returns its claims
+func ParseToken(signedToken string) (*JWTClaim, error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&JWTClaim{},
@@ -37,16 +48,14 @@ func ValidateToken(signedToken string) (err error) {
 		},
 	)
 	if err != nil {
-		return
+		return nil, err
 	}
 	claims, ok := token.Claims.(*JWTClaim)
 	if !ok {
-		err = errors.New("couldn't parse claims")
-		return
+		return nil, errors.New("couldn't parse claims")
 	}
 	if claims.ExpiresAt < time.Now().Local().Unix() {
-		err = errors.New("token expired")
-		return
+		return nil, errors.New("token expired")
 	}
-	return
+	return claims, nil
 }
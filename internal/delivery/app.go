@@ -2,16 +2,20 @@ package api
 
 import (
 	"GO_APP/config"
+	"GO_APP/internal/database"
 	"GO_APP/internal/delivery/api/cron"
 	"GO_APP/internal/delivery/api/cron/handler"
 	"GO_APP/internal/delivery/api/server"
+	serverHandler "GO_APP/internal/delivery/api/server/handler"
 	"GO_APP/internal/delivery/api/user"
+	"GO_APP/internal/delivery/api/user/auth"
+	"GO_APP/internal/migrations"
 	"GO_APP/internal/model"
-	"fmt"
+	"GO_APP/internal/repository"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
@@ -23,36 +27,54 @@ type App struct {
 	UserAuthRouter  user.UserAuthRoute
 }
 
+// serverUniqueKey returns the key no two live servers may share, along with
+// the placeholders the migrations build its index with
+func serverUniqueKey(config *config.Config) (repository.UniqueKey, migrations.Placeholders) {
+	key, err := repository.ParseUniqueKey(config.DB.ServerUniqueKey)
+	if err != nil {
+		log.Fatalf("Could not configure: %v", err)
+	}
+	return key, migrations.Placeholders{ServerUniqueColumns: key.Columns()}
+}
+
 // App initialize with predefined configuration
 func (a *App) Init(config *config.Config) {
-	dbURI := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		config.DB.Host,
-		config.DB.Port,
-		config.DB.User,
-		config.DB.Password,
-		config.DB.DBname,
-	)
-
-	// db, err := sqlx.Connect(config.DB.Dialect, dbURI)
-	db, err := gorm.Open(postgres.Open(dbURI))
+	key, placeholders := serverUniqueKey(config)
+	db, err := database.Open(config.DB)
 	if err != nil {
-		log.Fatal("Could not connect database")
+		log.Fatalf("Could not connect database: %v", err)
 	} else {
-		log.Printf("Connected to database\n")
+		log.Printf("Connected to %s database\n", config.DB.Dialect)
+	}
+
+	// refuse to serve against a schema this build wasn't written for
+	if err := migrations.Check(db, placeholders); err != nil {
+		log.Fatalf("Could not start: %v", err)
 	}
+	a.DB = db
 
-	a.DB = model.DBMigrate(db)
+	auth.SetJWTKey(config.Auth.JWTKey)
 
 	// set service routers
 	// serviceRouter := a.ServiceRouter
 	eng := gin.New()
 	a.ServiceRouter.Router = eng
-	a.ServiceRouter.DB = a.DB
+	repo := repository.NewGormServerRepository(a.DB, key)
+	a.ServiceRouter.Repo = repo
+	a.ServiceRouter.Settings = serverHandler.Settings{
+		DefaultThreshold: config.Server.DefaultThreshold,
+		MaxThreshold:     config.Server.MaxThreshold,
+		Validator:        model.NewValidator(config.Server.AllowPrivateIPs),
+	}
 	a.ServiceRouter.SetServiceRouter()
 
 	a.SchedulerRouter.Router = gin.New()
 	a.SchedulerRouter.DB = a.DB
-	a.SchedulerRouter.SchedulerJob = handler.InitializeScheduler()
+	store := repository.NewGormSchedulerRepository(a.DB)
+	a.SchedulerRouter.SchedulerJob, err = handler.InitializeScheduler(repo, store, config.Cron)
+	if err != nil {
+		log.Fatalf("Could not load the cron jobs: %v", err)
+	}
 	a.SchedulerRouter.SetSchedulerRouter()
 
 	a.UserAuthRouter.Router = eng
@@ -61,11 +83,25 @@ func (a *App) Init(config *config.Config) {
 
 }
 
+// Migrate runs the migrate subcommand given by args, e.g. ["up"] or ["down", "2"]
+func (a *App) Migrate(config *config.Config, args []string) error {
+	_, placeholders := serverUniqueKey(config)
+	db, err := database.Open(config.DB)
+	if err != nil {
+		return err
+	}
+	a.DB = db
+	return migrations.Run(db, placeholders, args, os.Stdout)
+}
+
 // Run the app on it's router
 func (a *App) Run(host string) {
 	a.ServiceRouter.Run(host)
 }
 
 func (a *App) RunCron(host string) {
+	if err := a.SchedulerRouter.SchedulerJob.Start(); err != nil {
+		log.Fatalf("Could not start the cron jobs: %v", err)
+	}
 	a.SchedulerRouter.Run(host)
 }
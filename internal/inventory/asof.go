@@ -0,0 +1,67 @@
+// Package inventory answers questions about the server inventory as it was
+// at some point in the past, by replaying the audit log.
+package inventory
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Servers returns every server, soft-deleted ones included, as it was at the
+// moment at, in id order.
+//
+// A server's state is the After of its last entry up to at. A server whose
+// entries all come later is described by the Before of the first of them,
+// which is nil when it was created after at. A server without any entry
+// hasn't changed since the audit log started, its current row is used if it
+// was created by then.
+func Servers(repo repository.ServerRepository, at time.Time) ([]model.Server, error) {
+	entries, err := repo.Audit().AsOf(at)
+	if err != nil {
+		return nil, err
+	}
+
+	audited := make(map[uint]bool, len(entries))
+	servers := []model.Server{}
+	for _, entry := range entries {
+		audited[entry.ServerID] = true
+		server := entry.After
+		if entry.CreatedAt.After(at) {
+			server = entry.Before
+		}
+		// a server that predates the audit log may still postdate at
+		if server != nil && !server.CreatedAt.After(at) {
+			servers = append(servers, *server)
+		}
+	}
+	err = repo.Each(repository.ServerFilter{IncludeDeleted: true, CreatedUntil: at}, func(server model.Server) error {
+		if audited[server.ID] {
+			return nil
+		}
+		if server.DeletedAt.Valid && server.DeletedAt.Time.After(at) {
+			server.DeletedAt = gorm.DeletedAt{}
+		}
+		servers = append(servers, server)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers, nil
+}
+
+// Snapshot returns an in-memory repository holding the inventory as it was at
+// the moment at, so it can be listed and reported on like the current one.
+func Snapshot(repo repository.ServerRepository, at time.Time) (repository.ServerRepository, error) {
+	servers, err := Servers(repo, at)
+	if err != nil {
+		return nil, err
+	}
+	return repository.NewMemoryServerRepositoryFrom(repo.UniqueKey(), servers), nil
+}
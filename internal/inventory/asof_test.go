@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func server(id uint, ip, hostname string, active bool, created time.Time) model.Server {
+	s := model.Server{IP: ip, Hostname: hostname, Active: active}
+	s.ID, s.CreatedAt = id, created
+	return s
+}
+
+func TestServers(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) time.Time { return t0.Add(time.Duration(hours) * time.Hour) }
+
+	// 1 predates the audit log and never changed, 2 predates it and was
+	// renamed at 5, 3 was created at 2, disabled at 4 and deleted at 6, 4 was
+	// created at 1 and purged at 3
+	untouched := server(1, "11.0.0.1", "mta-prod-1", true, t0)
+	renamedBefore := server(2, "11.0.0.2", "mta-old-2", true, t0)
+	renamedAfter := renamedBefore
+	renamedAfter.Hostname = "mta-prod-2"
+	created := server(3, "11.0.0.3", "mta-prod-3", true, at(2))
+	disabled := created
+	disabled.Active = false
+	deleted := disabled
+	deleted.DeletedAt = gorm.DeletedAt{Time: at(6), Valid: true}
+	purged := server(4, "11.0.0.4", "mta-prod-4", true, at(1))
+
+	repo := repository.NewMemoryServerRepositoryFrom(repository.UniqueIP, []model.Server{untouched, renamedAfter, deleted})
+	entries := []model.AuditEntry{
+		{ServerID: 4, Action: model.AuditCreate, After: &purged, CreatedAt: at(1)},
+		{ServerID: 3, Action: model.AuditCreate, After: &created, CreatedAt: at(2)},
+		{ServerID: 4, Action: model.AuditPurge, Before: &purged, CreatedAt: at(3)},
+		{ServerID: 3, Action: model.AuditDisable, Before: &created, After: &disabled, CreatedAt: at(4)},
+		{ServerID: 2, Action: model.AuditUpdate, Before: &renamedBefore, After: &renamedAfter, CreatedAt: at(5)},
+		{ServerID: 3, Action: model.AuditDelete, Before: &disabled, After: &deleted, CreatedAt: at(6)},
+	}
+	for i := range entries {
+		assert.NoError(t, repo.Audit().Append(&entries[i]))
+	}
+
+	tests := []struct {
+		at   time.Time
+		want []model.Server
+	}{
+		{t0.Add(-time.Hour), []model.Server{}},
+		{at(1), []model.Server{untouched, renamedBefore, purged}},
+		{at(2), []model.Server{untouched, renamedBefore, created, purged}},
+		{at(4), []model.Server{untouched, renamedBefore, disabled}},
+		{at(5), []model.Server{untouched, renamedAfter, disabled}},
+		{at(7), []model.Server{untouched, renamedAfter, deleted}},
+	}
+	for _, tt := range tests {
+		got, err := Servers(repo, tt.at)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got, tt.at.String())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	now := time.Now()
+	repo := repository.NewMemoryServerRepositoryFrom(repository.UniqueIP, []model.Server{
+		server(1, "11.0.0.1", "mta-prod-1", true, now.Add(-2*time.Hour)),
+		server(2, "11.0.0.2", "mta-prod-1", false, now.Add(-2*time.Hour)),
+		server(3, "11.0.0.3", "mta-prod-2", true, now),
+	})
+
+	snapshot, err := Snapshot(repo, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	hostnames, err := snapshot.HostnamesBelowThreshold(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mta-prod-1"}, hostnames)
+	page, err := snapshot.List(repository.ListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, page.Servers, 2)
+}
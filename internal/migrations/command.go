@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Usage describes the arguments of the migrate subcommand.
+const Usage = "migrate up | down [steps] | status | reindex"
+
+// SplitCommand separates the leading words of args, e.g. "migrate down 2",
+// from the flags following them.
+func SplitCommand(args []string) (command, rest []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+// Run executes the migrate subcommand given by args (without the leading
+// "migrate") against db, rendering the migrations with placeholders, and
+// writes a report to out.
+func Run(db *gorm.DB, placeholders Placeholders, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s", Usage)
+	}
+	m, err := New(db, placeholders)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		done, err := m.Up()
+		for _, migration := range done {
+			fmt.Fprintf(out, "applied  %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(out, "schema is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("down: steps must be a positive integer, got %q", args[1])
+			}
+		} else if len(args) > 2 {
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		done, err := m.Down(steps)
+		for _, migration := range done {
+			fmt.Fprintf(out, "reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%04d_%-40s %s\n", status.Version, status.Name, state)
+		}
+		return nil
+	case "reindex":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s", Usage)
+		}
+		if err := m.Reindex(); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "rebuilt  %s on (%s)\n", uniqueIndex, m.placeholders.ServerUniqueColumns)
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, usage: %s", args[0], Usage)
+	}
+}
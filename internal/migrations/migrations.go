@@ -0,0 +1,419 @@
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed sql
+var files embed.FS
+
+// fileName matches migration files, e.g. 0001_create_servers.up.sql.
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// placeholder matches the ${name} placeholders migrations are rendered with.
+var placeholder = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// Placeholders holds the values substituted for ${name} in migration files,
+// for the parts of the schema that depend on the configuration. A value is
+// only read when its migration is applied, Check refuses a database built
+// with another one.
+type Placeholders struct {
+	// ServerUniqueColumns are the columns no two live servers may share, as
+	// listed in an index definition, e.g. "ip, hostname".
+	ServerUniqueColumns string
+}
+
+func (p Placeholders) values() map[string]string {
+	return map[string]string{"server_unique_columns": p.ServerUniqueColumns}
+}
+
+const (
+	// uniqueIndex is the index built on ServerUniqueColumns.
+	uniqueIndex = "uniq_servers_ip"
+	// uniqueMigration creates uniqueIndex, Reindex runs it again.
+	uniqueMigration = "unique_server_ip"
+)
+
+// indexColumns matches the column list of an index definition, the first
+// parenthesized part of it on both dialects.
+var indexColumns = regexp.MustCompile(`\(([^()]*)\)`)
+
+var (
+	// ErrPending is returned by Check when migrations are waiting to be applied.
+	ErrPending = errors.New("database schema is out of date")
+	// ErrTooNew is returned by Check when the database has migrations this build doesn't know about.
+	ErrTooNew = errors.New("database schema is newer than this build")
+	// ErrMismatch is returned by Check when the schema was built with other
+	// Placeholders than the configured ones.
+	ErrMismatch = errors.New("database schema doesn't match the configuration")
+)
+
+// Migration is one step of the schema history.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// SchemaMigration is a row of the schema_migrations table, one per applied migration.
+type SchemaMigration struct {
+	Version   int64 `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status reports whether a migration has been applied to the database.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load returns the migrations of the given dialect ordered by version.
+func Load(dialect string) ([]Migration, error) {
+	dir := path.Join("sql", dialect)
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := fs.ReadFile(files, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// render substitutes the placeholders of sql, failing on unknown ones so a
+// typo can't end up in the schema.
+func render(sql string, values map[string]string) (string, error) {
+	var missing error
+	rendered := placeholder.ReplaceAllStringFunc(sql, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+		value, ok := values[name]
+		if !ok && missing == nil {
+			missing = fmt.Errorf("no value for placeholder ${%s}", name)
+		}
+		return value
+	})
+	return rendered, missing
+}
+
+// Migrator applies the migrations of its dialect to a database.
+type Migrator struct {
+	db           *gorm.DB
+	migrations   []Migration
+	placeholders Placeholders
+}
+
+// New returns a Migrator for db, picking the migrations matching its dialect
+// and rendering them with placeholders.
+func New(db *gorm.DB, placeholders Placeholders) (*Migrator, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	values := placeholders.values()
+	for i := range migrations {
+		migration := &migrations[i]
+		if migration.Up, err = render(migration.Up, values); err == nil {
+			migration.Down, err = render(migration.Down, values)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+	return &Migrator{db: db, migrations: migrations, placeholders: placeholders}, nil
+}
+
+// createTable creates schema_migrations unless it exists. Only the commands
+// changing the schema call it, checking and listing the migrations never
+// write to the database.
+func (m *Migrator) createTable() error {
+	if err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`).Error; err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// applied returns the applied migrations, none when schema_migrations doesn't
+// exist yet.
+func (m *Migrator) applied() ([]SchemaMigration, error) {
+	applied := []SchemaMigration{}
+	if !m.db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	err := m.db.Order("version").Find(&applied).Error
+	return applied, err
+}
+
+// Status lists every known migration along with the applied ones this build
+// doesn't know about, ordered by version.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := map[int64]time.Time{}
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := []Status{}
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Migration: migration}
+		if at, ok := appliedAt[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	for _, a := range applied {
+		if !known[a.Version] {
+			at := a.AppliedAt
+			statuses = append(statuses, Status{Migration: Migration{Version: a.Version, Name: a.Name}, Applied: true, AppliedAt: &at})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check makes sure the database is at exactly the latest known version, and
+// that its unique index of the servers is on the configured columns.
+func (m *Migrator) Check() error {
+	if err := m.checkVersion(); err != nil {
+		return err
+	}
+	return m.checkUniqueIndex()
+}
+
+func (m *Migrator) checkVersion() error {
+	statuses, err := m.Status()
+	if err != nil {
+		return err
+	}
+	known := map[int64]bool{}
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+	}
+	migrated := false
+	for _, status := range statuses {
+		migrated = migrated || status.Applied
+	}
+	if !migrated {
+		return fmt.Errorf("%w: the database isn't migrated, run `migrate up`", ErrPending)
+	}
+	for _, status := range statuses {
+		if !known[status.Version] {
+			return fmt.Errorf("%w: unknown migration %04d_%s is applied", ErrTooNew, status.Version, status.Name)
+		}
+		if !status.Applied {
+			return fmt.Errorf("%w: migration %04d_%s is pending, run `migrate up`", ErrPending, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// uniqueIndexColumns returns the columns uniqueIndex is on, "" when there is
+// no such index.
+func (m *Migrator) uniqueIndexColumns() (string, error) {
+	definitions := []string{}
+	q := m.db.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", uniqueIndex)
+	if m.db.Dialector.Name() == "postgres" {
+		q = m.db.Raw("SELECT indexdef FROM pg_indexes WHERE indexname = ?", uniqueIndex)
+	}
+	if err := q.Scan(&definitions).Error; err != nil {
+		return "", err
+	}
+	if len(definitions) == 0 {
+		return "", nil
+	}
+	match := indexColumns.FindStringSubmatch(definitions[0])
+	if match == nil {
+		return "", fmt.Errorf("can't read the columns of index %s from %q", uniqueIndex, definitions[0])
+	}
+	return normalizeColumns(match[1]), nil
+}
+
+// normalizeColumns spaces a column list the way ServerUniqueColumns is written.
+func normalizeColumns(columns string) string {
+	names := strings.Split(columns, ",")
+	for i, name := range names {
+		names[i] = strings.Trim(strings.TrimSpace(name), `"`)
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkUniqueIndex refuses a database whose unique index of the servers was
+// built on other columns than the configured ones, the repositories would
+// check one key and the database enforce another.
+func (m *Migrator) checkUniqueIndex() error {
+	columns, err := m.uniqueIndexColumns()
+	if err != nil {
+		return err
+	}
+	if columns == "" {
+		return fmt.Errorf("%w: index %s is missing, run `migrate reindex` to build it", ErrMismatch, uniqueIndex)
+	}
+	if want := normalizeColumns(m.placeholders.ServerUniqueColumns); columns != want {
+		return fmt.Errorf("%w: index %s is on (%s) rather than (%s), run `migrate reindex` to rebuild it", ErrMismatch, uniqueIndex, columns, want)
+	}
+	return nil
+}
+
+// Reindex rebuilds the unique index of the servers on the configured
+// columns, reverting and applying its migration again in one transaction. It
+// fails while live servers share the new key.
+func (m *Migrator) Reindex() error {
+	if err := m.createTable(); err != nil {
+		return err
+	}
+	if err := m.checkVersion(); err != nil {
+		return err
+	}
+	for _, migration := range m.migrations {
+		if migration.Name != uniqueMigration {
+			continue
+		}
+		return m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Exec(migration.Up).Error
+		})
+	}
+	return fmt.Errorf("no migration %s", uniqueMigration)
+}
+
+// Up applies every pending migration in order, each one in its own
+// transaction, and returns the ones it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, status := range statuses {
+		if status.Applied {
+			continue
+		}
+		migration := status.Migration
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Up).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("applying migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		status := statuses[i]
+		if !status.Applied {
+			continue
+		}
+		migration := status.Migration
+		if migration.Down == "" {
+			return done, fmt.Errorf("migration %04d_%s is unknown to this build and can't be reverted", migration.Version, migration.Name)
+		}
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(migration.Down).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, migration.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %04d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Up applies every pending migration to db.
+func Up(db *gorm.DB, placeholders Placeholders) ([]Migration, error) {
+	m, err := New(db, placeholders)
+	if err != nil {
+		return nil, err
+	}
+	return m.Up()
+}
+
+// Check makes sure db is at exactly the latest known version and was built
+// with placeholders.
+func Check(db *gorm.DB, placeholders Placeholders) error {
+	m, err := New(db, placeholders)
+	if err != nil {
+		return err
+	}
+	return m.Check()
+}
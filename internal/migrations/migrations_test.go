@@ -0,0 +1,157 @@
+package migrations
+
+import (
+	"GO_APP/config"
+	"GO_APP/internal/database"
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openSqlite(t *testing.T) *gorm.DB {
+	db, err := database.Open(&config.DBConfig{Dialect: "sqlite", Path: database.MemoryPath}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+// ipKey renders the migrations the way the default configuration does
+var ipKey = Placeholders{ServerUniqueColumns: "ip"}
+
+func TestLoadEveryDialect(t *testing.T) {
+	postgres, err := Load("postgres")
+	assert.NoError(t, err)
+	sqlite, err := Load("sqlite")
+	assert.NoError(t, err)
+
+	// both dialects must describe the same history
+	if assert.Equal(t, len(postgres), len(sqlite)) {
+		for i := range postgres {
+			assert.Equal(t, postgres[i].Version, sqlite[i].Version)
+			assert.Equal(t, postgres[i].Name, sqlite[i].Name)
+		}
+	}
+	for i := 1; i < len(postgres); i++ {
+		assert.Less(t, postgres[i-1].Version, postgres[i].Version)
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	db := openSqlite(t)
+	m, err := New(db, ipKey)
+	if err != nil {
+		t.Fatalf("Error creating migrator: %v", err)
+	}
+
+	// checking a database never migrated leaves it untouched
+	err = m.Check()
+	assert.True(t, errors.Is(err, ErrPending), "expected ErrPending, got %v", err)
+	assert.Contains(t, err.Error(), "isn't migrated")
+	assert.False(t, db.Migrator().HasTable("schema_migrations"))
+	statuses, err := m.Status()
+	assert.NoError(t, err)
+	assert.Len(t, statuses, len(m.migrations))
+	assert.False(t, db.Migrator().HasTable("schema_migrations"))
+
+	done, err := m.Up()
+	assert.NoError(t, err)
+	assert.Len(t, done, len(m.migrations))
+	assert.NoError(t, m.Check())
+	assert.True(t, db.Migrator().HasTable("servers"))
+
+	done, err = m.Up()
+	assert.NoError(t, err)
+	assert.Empty(t, done)
+
+	done, err = m.Down(len(m.migrations))
+	assert.NoError(t, err)
+	assert.Len(t, done, len(m.migrations))
+	assert.False(t, db.Migrator().HasTable("servers"))
+
+	statuses, err = m.Status()
+	assert.NoError(t, err)
+	for _, status := range statuses {
+		assert.False(t, status.Applied)
+	}
+}
+
+func TestCheckRejectsNewerSchema(t *testing.T) {
+	db := openSqlite(t)
+	if _, err := Up(db, ipKey); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	db.Create(&SchemaMigration{Version: 9999, Name: "from_the_future"})
+
+	err := Check(db, ipKey)
+	assert.True(t, errors.Is(err, ErrTooNew), "expected ErrTooNew, got %v", err)
+}
+
+func TestRunCommand(t *testing.T) {
+	db := openSqlite(t)
+	out := &bytes.Buffer{}
+
+	assert.NoError(t, Run(db, ipKey, []string{"up"}, out))
+	assert.Contains(t, out.String(), "applied  0001_create_servers_and_users")
+
+	out.Reset()
+	assert.NoError(t, Run(db, ipKey, []string{"status"}, out))
+	assert.Contains(t, out.String(), "0001_create_servers_and_users")
+	assert.NotContains(t, out.String(), "pending")
+
+	out.Reset()
+	assert.NoError(t, Run(db, ipKey, []string{"down", "1"}, out))
+	assert.Contains(t, out.String(), "reverted")
+
+	assert.Error(t, Run(db, ipKey, []string{"down", "zero"}, out))
+	assert.Error(t, Run(db, ipKey, []string{"sideways"}, out))
+}
+
+func TestCheckRejectsAnotherUniqueKey(t *testing.T) {
+	db := openSqlite(t)
+	if _, err := Up(db, ipKey); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	ipHostname := Placeholders{ServerUniqueColumns: "ip, hostname"}
+
+	err := Check(db, ipHostname)
+	assert.True(t, errors.Is(err, ErrMismatch), "expected ErrMismatch, got %v", err)
+	assert.Contains(t, err.Error(), "(ip) rather than (ip, hostname)")
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, Run(db, ipHostname, []string{"reindex"}, out))
+	assert.Contains(t, out.String(), "uniq_servers_ip on (ip, hostname)")
+	assert.NoError(t, Check(db, ipHostname))
+	assert.True(t, errors.Is(Check(db, ipKey), ErrMismatch))
+
+	assert.NoError(t, db.Exec("DROP INDEX uniq_servers_ip").Error)
+	err = Check(db, ipKey)
+	assert.True(t, errors.Is(err, ErrMismatch), "expected ErrMismatch, got %v", err)
+	assert.Contains(t, err.Error(), "missing")
+}
+
+func TestSplitCommand(t *testing.T) {
+	command, rest := SplitCommand([]string{"migrate", "down", "2", "-db-dialect", "sqlite"})
+	assert.Equal(t, []string{"migrate", "down", "2"}, command)
+	assert.Equal(t, []string{"-db-dialect", "sqlite"}, rest)
+}
+
+func TestRenderPlaceholders(t *testing.T) {
+	sql, err := render("CREATE UNIQUE INDEX u ON servers (${columns});", map[string]string{"columns": "ip, hostname"})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE UNIQUE INDEX u ON servers (ip, hostname);", sql)
+
+	_, err = render("CREATE UNIQUE INDEX u ON servers (${colums});", map[string]string{"columns": "ip"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "${colums}")
+}
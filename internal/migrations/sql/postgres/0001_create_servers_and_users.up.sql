@@ -0,0 +1,24 @@
+-- Matches the schema AutoMigrate used to create, so existing databases can be
+-- adopted by running the migrations against them.
+CREATE TABLE IF NOT EXISTS servers (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	ip TEXT,
+	hostname TEXT,
+	active BOOLEAN
+);
+CREATE INDEX IF NOT EXISTS idx_servers_deleted_at ON servers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	created_at TIMESTAMPTZ,
+	updated_at TIMESTAMPTZ,
+	deleted_at TIMESTAMPTZ,
+	name TEXT,
+	username TEXT UNIQUE,
+	email TEXT UNIQUE,
+	password TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
@@ -0,0 +1,4 @@
+-- No two live servers may share ${server_unique_columns}; soft-deleted rows
+-- are left out so a deleted server's IP can be reused. Fails if duplicates
+-- already exist, they have to be deleted first.
+CREATE UNIQUE INDEX uniq_servers_ip ON servers (${server_unique_columns}) WHERE deleted_at IS NULL;
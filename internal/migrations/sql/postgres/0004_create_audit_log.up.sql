@@ -0,0 +1,22 @@
+-- Append-only history of server changes. before and after hold the server as
+-- JSON, server_id has no foreign key so the history outlives a purge.
+CREATE TABLE audit_log (
+	id BIGSERIAL PRIMARY KEY,
+	server_id BIGINT NOT NULL,
+	action TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	"before" TEXT,
+	"after" TEXT,
+	created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_log_server_id ON audit_log (server_id, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
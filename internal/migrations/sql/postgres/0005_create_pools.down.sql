@@ -0,0 +1,2 @@
+ALTER TABLE servers DROP COLUMN pool_id;
+DROP TABLE pools;
@@ -0,0 +1,3 @@
+-- Labels tag servers with key/value pairs, stored as a JSON object so
+-- label selectors can look keys up without a join.
+ALTER TABLE servers ADD COLUMN labels TEXT NOT NULL DEFAULT '{}';
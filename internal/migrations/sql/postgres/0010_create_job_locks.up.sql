@@ -0,0 +1,8 @@
+-- The leases the replicas of the cron take on the jobs, so each run happens
+-- on a single replica.
+CREATE TABLE job_locks (
+	job_name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	acquired_at TIMESTAMPTZ NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);
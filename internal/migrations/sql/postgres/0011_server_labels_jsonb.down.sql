@@ -0,0 +1,4 @@
+DROP INDEX idx_servers_labels;
+ALTER TABLE servers ALTER COLUMN labels DROP DEFAULT;
+ALTER TABLE servers ALTER COLUMN labels TYPE TEXT USING labels::text;
+ALTER TABLE servers ALTER COLUMN labels SET DEFAULT '{}';
@@ -0,0 +1,6 @@
+-- Labels move to jsonb so selectors read them without a cast, and the GIN
+-- index serves the containment (@>) lookups of equality selectors.
+ALTER TABLE servers ALTER COLUMN labels DROP DEFAULT;
+ALTER TABLE servers ALTER COLUMN labels TYPE jsonb USING labels::jsonb;
+ALTER TABLE servers ALTER COLUMN labels SET DEFAULT '{}';
+CREATE INDEX idx_servers_labels ON servers USING GIN (labels);
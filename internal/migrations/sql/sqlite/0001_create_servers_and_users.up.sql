@@ -0,0 +1,24 @@
+-- Matches the schema AutoMigrate used to create, so existing databases can be
+-- adopted by running the migrations against them.
+CREATE TABLE IF NOT EXISTS servers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME,
+	ip TEXT,
+	hostname TEXT,
+	active NUMERIC
+);
+CREATE INDEX IF NOT EXISTS idx_servers_deleted_at ON servers (deleted_at);
+
+CREATE TABLE IF NOT EXISTS users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME,
+	name TEXT,
+	username TEXT UNIQUE,
+	email TEXT UNIQUE,
+	password TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
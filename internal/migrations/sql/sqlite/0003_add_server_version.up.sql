@@ -0,0 +1,2 @@
+-- version is bumped by every write, it backs the ETag of a server.
+ALTER TABLE servers ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
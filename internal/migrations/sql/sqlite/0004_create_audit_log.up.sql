@@ -0,0 +1,23 @@
+-- Append-only history of server changes. before and after hold the server as
+-- JSON, server_id has no foreign key so the history outlives a purge.
+CREATE TABLE audit_log (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	server_id INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	actor TEXT NOT NULL,
+	request_id TEXT NOT NULL DEFAULT '',
+	"before" TEXT,
+	"after" TEXT,
+	created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_audit_log_server_id ON audit_log (server_id, id);
+CREATE INDEX idx_audit_log_created_at ON audit_log (created_at);
+
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'audit_log is append-only');
+END;
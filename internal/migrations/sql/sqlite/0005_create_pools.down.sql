@@ -0,0 +1,3 @@
+DROP INDEX idx_servers_pool_id;
+ALTER TABLE servers DROP COLUMN pool_id;
+DROP TABLE pools;
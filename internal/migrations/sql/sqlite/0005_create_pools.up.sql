@@ -0,0 +1,17 @@
+-- Pools group servers; a server belongs to at most one. Pools are
+-- soft-deleted like servers and no two live pools share a name.
+CREATE TABLE pools (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at DATETIME,
+	updated_at DATETIME,
+	deleted_at DATETIME,
+	name TEXT NOT NULL,
+	purpose TEXT NOT NULL,
+	owner TEXT NOT NULL DEFAULT '',
+	min_active_ips INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_pools_deleted_at ON pools (deleted_at);
+CREATE UNIQUE INDEX uniq_pools_name ON pools (name) WHERE deleted_at IS NULL;
+
+ALTER TABLE servers ADD COLUMN pool_id INTEGER REFERENCES pools (id);
+CREATE INDEX idx_servers_pool_id ON servers (pool_id);
@@ -0,0 +1 @@
+DROP TABLE hostname_utilisation;
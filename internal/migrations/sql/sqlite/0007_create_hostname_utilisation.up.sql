@@ -0,0 +1,11 @@
+-- Snapshots of the active and inactive IP counts of every hostname, taken by
+-- the cron and pruned once older than its retention.
+CREATE TABLE hostname_utilisation (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	hostname TEXT NOT NULL,
+	active INTEGER NOT NULL,
+	inactive INTEGER NOT NULL,
+	taken_at DATETIME NOT NULL
+);
+CREATE INDEX idx_hostname_utilisation_hostname ON hostname_utilisation (hostname, taken_at);
+CREATE INDEX idx_hostname_utilisation_taken_at ON hostname_utilisation (taken_at);
@@ -0,0 +1,14 @@
+-- The jobs of the cron, rehydrated when it starts, with when they last ran
+-- and are due next.
+CREATE TABLE scheduler_jobs (
+	name TEXT PRIMARY KEY,
+	job_type TEXT NOT NULL,
+	cron_expr TEXT NOT NULL DEFAULT '',
+	run_interval TEXT NOT NULL DEFAULT '',
+	params TEXT,
+	paused BOOLEAN NOT NULL DEFAULT FALSE,
+	last_run_at DATETIME,
+	next_run_at DATETIME,
+	created_at DATETIME,
+	updated_at DATETIME
+);
@@ -0,0 +1,12 @@
+-- Every run of the cron jobs, pruned by the retention of the cron.
+CREATE TABLE job_runs (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	job_name TEXT NOT NULL,
+	started_at DATETIME NOT NULL,
+	finished_at DATETIME NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	result TEXT
+);
+CREATE INDEX idx_job_runs_job_name ON job_runs (job_name, id);
@@ -0,0 +1,2 @@
+-- SQLite has no jsonb column nor GIN index, labels stay JSON text read with
+-- json_extract. The migration only keeps the versions of both dialects aligned.
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Actions recorded in the audit log
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditEnable  = "enable"
+	AuditDisable = "disable"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+)
+
+// AuditEntry records one change made to a server. Entries are only ever
+// appended, Before is null for a creation and After for a purge.
+type AuditEntry struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	ServerID  uint      `json:"server_id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor"`
+	RequestID string    `json:"request_id"`
+	Before    *Server   `gorm:"serializer:json" json:"before"`
+	After     *Server   `gorm:"serializer:json" json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
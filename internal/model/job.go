@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SchedulerJob is a job of the cron as stored, so it is run again after a
+// restart. It is scheduled on Cron or every Interval unless it is Paused.
+type SchedulerJob struct {
+	Name      string            `gorm:"primaryKey"`
+	Type      string            `gorm:"column:job_type"`
+	Cron      string            `gorm:"column:cron_expr"`
+	Interval  string            `gorm:"column:run_interval"`
+	Params    map[string]string `gorm:"serializer:json"`
+	Paused    bool
+	LastRunAt *time.Time
+	NextRunAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Outcomes of a JobRun
+const (
+	JobRunSucceeded = "succeeded"
+	JobRunFailed    = "failed"
+)
+
+// JobRun is one run of a job of the cron, Result is what the run found or did
+// and Error why it failed
+type JobRun struct {
+	ID         uint            `gorm:"primarykey" json:"id"`
+	JobName    string          `json:"job"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	Result     json.RawMessage `gorm:"serializer:json" json:"result,omitempty"`
+}
+
+// JobLock is the lease a replica of the cron takes on a job to run it, the
+// other replicas skip the job until ExpiresAt
+type JobLock struct {
+	JobName    string    `gorm:"primaryKey" json:"job"`
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Held tells whether the lock hasn't expired yet by the clock of the
+	// database, it is only read
+	Held bool `gorm:"->;-:migration" json:"held"`
+}
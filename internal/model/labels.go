@@ -0,0 +1,140 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Labels tags a server with key/value pairs such as region=eu or provider=ovh.
+// They are stored as a JSON object.
+type Labels map[string]string
+
+// Value stores labels as a JSON object, {} when there are none
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+// Scan reads labels stored by Value
+func (l *Labels) Scan(src interface{}) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("labels: can't scan a %T", src)
+	}
+	labels := Labels{}
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return fmt.Errorf("labels: %w", err)
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	*l = labels
+	return nil
+}
+
+// Clone returns a copy of l, nil when it is empty like labels read back from
+// the database
+func (l Labels) Clone() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+	clone := make(Labels, len(l))
+	for key, value := range l {
+		clone[key] = value
+	}
+	return clone
+}
+
+// Keys returns the keys of l, sorted
+func (l Labels) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelNameProblem returns why name isn't a label name, up to 63 letters,
+// digits, '-', '_' or '.', starting and ending with a letter or digit, or ""
+// if it is. An empty name is accepted when optional is set.
+func labelNameProblem(name string, optional bool) string {
+	if name == "" {
+		if optional {
+			return ""
+		}
+		return "must not be empty"
+	}
+	if len(name) > 63 {
+		return fmt.Sprintf("%q is longer than 63 characters", name)
+	}
+	alnum := func(r byte) bool {
+		return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	}
+	if !alnum(name[0]) || !alnum(name[len(name)-1]) {
+		return fmt.Sprintf("%q must start and end with a letter or digit", name)
+	}
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !alnum(c) && c != '-' && c != '_' && c != '.' {
+			return fmt.Sprintf("%q may only contain letters, digits, '-', '_' and '.'", name)
+		}
+	}
+	return ""
+}
+
+// LabelKeyProblem returns why key can't be a label key, or "" if it can. Like
+// Kubernetes label keys, a key is a name optionally prefixed by a DNS
+// subdomain and a slash, e.g. example.com/warmup.
+func LabelKeyProblem(key string) string {
+	name := key
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		prefix := key[:i]
+		name = key[i+1:]
+		if prefix == "" {
+			return fmt.Sprintf("key %q has an empty prefix", key)
+		}
+		if problem := HostnameProblem(prefix); problem != "" {
+			return fmt.Sprintf("prefix of key %q %s", key, problem)
+		}
+	}
+	if problem := labelNameProblem(name, false); problem != "" {
+		return "key " + problem
+	}
+	return ""
+}
+
+// LabelValueProblem returns why value can't be a label value, or "" if it can.
+// Values follow the rules of names, but may be empty.
+func LabelValueProblem(value string) string {
+	if problem := labelNameProblem(value, true); problem != "" {
+		return "value " + problem
+	}
+	return ""
+}
+
+// labelsProblem returns why labels can't be stored, or "" if they can
+func labelsProblem(labels Labels) string {
+	for _, key := range labels.Keys() {
+		if problem := LabelKeyProblem(key); problem != "" {
+			return problem
+		}
+		if problem := LabelValueProblem(labels[key]); problem != "" {
+			return fmt.Sprintf("%s of key %q", problem, key)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Purposes a pool of IPs can serve
+const (
+	PurposeTransactional = "transactional"
+	PurposeMarketing     = "marketing"
+)
+
+// Pool groups the servers sending one kind of traffic. It is flagged by the
+// pool report when fewer than MinActiveIPs of its servers are active.
+type Pool struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	Name         string         `json:"name" validate:"required,max=100"`
+	Purpose      string         `json:"purpose" validate:"required,oneof=transactional marketing"`
+	Owner        string         `json:"owner" validate:"max=100"`
+	MinActiveIPs int            `gorm:"column:min_active_ips" json:"min_active_ips" validate:"min=0"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
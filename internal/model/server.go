@@ -7,17 +7,15 @@ import (
 
 type Server struct {
 	gorm.Model        // Includes fields `ID`, `CreatedAt`, `UpdatedAt`, `DeletedAt`
-	IP         string `json:"IP" validate:"ip_address"`
-	Hostname   string
+	IP         string `json:"IP" validate:"required,ip_address"`
+	Hostname   string `validate:"required,rfc1123_hostname"`
 	Active     bool
-}
-
-func DBMigrate(db *gorm.DB) *gorm.DB {
-	// Auto migrate the models
-	db.AutoMigrate(&Server{})
-	db.AutoMigrate(&User{})
-
-	return db
+	// PoolID is the pool the server belongs to, if any
+	PoolID *uint
+	// Labels tag the server, e.g. region=eu, for label selectors to pick it
+	Labels Labels `json:",omitempty" validate:"labels"`
+	// Version is bumped by every write, it is handed out as the ETag of the server
+	Version uint `gorm:"not null;default:1"`
 }
 
 func (s *Server) Disable() {
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// UtilisationSample is how many active and inactive IPs a hostname had when
+// the cron took a snapshot of the inventory
+type UtilisationSample struct {
+	ID       uint      `gorm:"primarykey" json:"-"`
+	Hostname string    `json:"hostname"`
+	Active   int       `json:"active"`
+	Inactive int       `json:"inactive"`
+	TakenAt  time.Time `json:"taken_at"`
+}
+
+func (UtilisationSample) TableName() string {
+	return "hostname_utilisation"
+}
@@ -1,25 +1,199 @@
 package model
 
-// struct
-// func ()initValidator() *validator.Validate {
-// 	v := validator.New()
-// 	return v
-// }
-
-// func(*validator.Validate) RegisterIPValidation(){
-// 	_ = v.RegisterTranslation("required", trans, func(ut ut.Translator) error {
-// 		return ut.Add("required", "{0} is a required field", true) // see universal-translator for details
-// 	}, func(ut ut.Translator, fe validator.FieldError) string {
-// 		t, _ := ut.T("required", fe.Field())
-// 		return t
-// 	})
-// }
-// _ = v.RegisterValidation("ip_address", func(fl validator.FieldLevel) bool {
-// 	return net.ParseIP(fl.Field().String()) == nil
-// })
-
-// if err := v.Struct(server); err != nil {
-// 	log.Printf("[server][CreateServer][struct] error:%+v\n", err)
-// 	respondError(c, http.StatusBadRequest, err.Error())
-// 	return
-// }
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// reservedPrefixes are the special purpose ranges, on top of the private,
+// loopback, link-local, multicast and unspecified ones, that a server can't use
+// unless private addresses are allowed
+var reservedPrefixes = []struct {
+	prefix netip.Prefix
+	name   string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), "this network"},
+	{netip.MustParsePrefix("100.64.0.0/10"), "shared address space"},
+	{netip.MustParsePrefix("192.0.0.0/24"), "IETF protocol assignments"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "documentation"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "benchmarking"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "documentation"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "documentation"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "reserved"},
+	{netip.MustParsePrefix("64:ff9b::/96"), "IPv4/IPv6 translation"},
+	{netip.MustParsePrefix("100::/64"), "discard-only"},
+	{netip.MustParsePrefix("2001::/23"), "IETF protocol assignments"},
+	{netip.MustParsePrefix("2001:db8::/32"), "documentation"},
+}
+
+// FieldError describes why one field of a model was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every field of a model that was rejected
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Field + ": " + err.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// NormalizeIP returns ip in its canonical form: IPv4 in dotted decimal, IPv6
+// compressed and lower case, and IPv4-mapped IPv6 addresses as plain IPv4.
+// Values that aren't an address are returned untouched.
+func NormalizeIP(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil || addr.Zone() != "" {
+		return ip
+	}
+	return addr.Unmap().String()
+}
+
+// Validator checks models before they are stored
+type Validator struct {
+	validate *validator.Validate
+	// AllowPrivateIPs accepts private and reserved ranges for server IPs
+	AllowPrivateIPs bool
+}
+
+// NewValidator returns a Validator, private and reserved IPs are rejected
+// unless allowPrivateIPs is set
+func NewValidator(allowPrivateIPs bool) *Validator {
+	v := &Validator{validate: validator.New(), AllowPrivateIPs: allowPrivateIPs}
+	// report fields by the name they have in JSON bodies
+	v.validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	_ = v.validate.RegisterValidation("ip_address", func(fl validator.FieldLevel) bool {
+		return v.ipProblem(fl.Field().String()) == ""
+	})
+	_ = v.validate.RegisterValidation("rfc1123_hostname", func(fl validator.FieldLevel) bool {
+		return HostnameProblem(fl.Field().String()) == ""
+	})
+	_ = v.validate.RegisterValidation("labels", func(fl validator.FieldLevel) bool {
+		labels, _ := fl.Field().Interface().(Labels)
+		return labelsProblem(labels) == ""
+	})
+	return v
+}
+
+// ipProblem returns why ip can't be used by a server, or "" if it can
+func (v *Validator) ipProblem(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return fmt.Sprintf("%q is not a valid IPv4 or IPv6 address", ip)
+	}
+	if v.AllowPrivateIPs {
+		return ""
+	}
+	addr = addr.Unmap()
+	switch {
+	case addr.IsPrivate():
+		return fmt.Sprintf("%s is a private address", addr)
+	case addr.IsLoopback():
+		return fmt.Sprintf("%s is a loopback address", addr)
+	case addr.IsLinkLocalUnicast():
+		return fmt.Sprintf("%s is a link-local address", addr)
+	case addr.IsMulticast():
+		return fmt.Sprintf("%s is a multicast address", addr)
+	case addr.IsUnspecified():
+		return fmt.Sprintf("%s is the unspecified address", addr)
+	}
+	for _, reserved := range reservedPrefixes {
+		if reserved.prefix.Contains(addr) {
+			return fmt.Sprintf("%s is in the reserved %s range %s", addr, reserved.name, reserved.prefix)
+		}
+	}
+	return ""
+}
+
+// HostnameProblem returns why hostname isn't a valid RFC 1123 host name, or ""
+// if it is
+func HostnameProblem(hostname string) string {
+	if len(hostname) > 253 {
+		return "must be at most 253 characters long"
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" {
+			return "must not contain empty labels"
+		}
+		if len(label) > 63 {
+			return fmt.Sprintf("label %q is longer than 63 characters", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Sprintf("label %q must not start or end with a hyphen", label)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Sprintf("label %q may only contain letters, digits and hyphens", label)
+			}
+		}
+	}
+	return ""
+}
+
+// Server normalizes the IP of server and checks every field against its
+// validate tag. The returned error is a ValidationErrors when fields are rejected.
+func (v *Validator) Server(server *Server) error {
+	server.IP = NormalizeIP(server.IP)
+	server.Hostname = strings.TrimSpace(server.Hostname)
+	return v.check(server)
+}
+
+// Pool trims the text fields of pool and checks them against their validate tag.
+// The returned error is a ValidationErrors when fields are rejected.
+func (v *Validator) Pool(pool *Pool) error {
+	pool.Name = strings.TrimSpace(pool.Name)
+	pool.Purpose = strings.TrimSpace(pool.Purpose)
+	pool.Owner = strings.TrimSpace(pool.Owner)
+	return v.check(pool)
+}
+
+func (v *Validator) check(model interface{}) error {
+	err := v.validate.Struct(model)
+	fieldErrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	errs := ValidationErrors{}
+	for _, fe := range fieldErrs {
+		value := fmt.Sprint(fe.Value())
+		message := ""
+		switch fe.Tag() {
+		case "required":
+			message = "is required"
+		case "ip_address":
+			message = v.ipProblem(value)
+		case "rfc1123_hostname":
+			message = "is not a valid host name: " + HostnameProblem(value)
+		case "labels":
+			labels, _ := fe.Value().(Labels)
+			message = labelsProblem(labels)
+		case "oneof":
+			message = fmt.Sprintf("%q is not one of %s", value, strings.ReplaceAll(fe.Param(), " ", ", "))
+		case "min":
+			message = "must be at least " + fe.Param()
+		case "max":
+			message = fmt.Sprintf("must be at most %s characters long", fe.Param())
+		default:
+			message = fmt.Sprintf("failed the %s rule", fe.Tag())
+		}
+		errs = append(errs, FieldError{Field: fe.Field(), Rule: fe.Tag(), Message: message})
+	}
+	return errs
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	tests := map[string]string{
+		"93.184.216.34":           "93.184.216.34",
+		" 93.184.216.34 ":         "93.184.216.34",
+		"::ffff:93.184.216.34":    "93.184.216.34",
+		"2606:2800:0220:0001::1":  "2606:2800:220:1::1",
+		"2606:2800:220:1:0:0:0:1": "2606:2800:220:1::1",
+		"not an ip":               "not an ip",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, NormalizeIP(in), in)
+	}
+}
+
+func TestValidateServer(t *testing.T) {
+	v := NewValidator(false)
+
+	server := Server{IP: "2606:2800:0220:0001::1", Hostname: "mta-prod-1.example.com"}
+	assert.NoError(t, v.Server(&server))
+	assert.Equal(t, "2606:2800:220:1::1", server.IP)
+
+	tests := []struct {
+		name   string
+		server Server
+		want   []string
+	}{
+		{"missing fields", Server{}, []string{"IP/required", "Hostname/required"}},
+		{"not an ip", Server{IP: "300.1.1.1", Hostname: "mta"}, []string{"IP/ip_address"}},
+		{"private ip", Server{IP: "10.1.2.3", Hostname: "mta"}, []string{"IP/ip_address"}},
+		{"loopback ip", Server{IP: "::1", Hostname: "mta"}, []string{"IP/ip_address"}},
+		{"documentation ip", Server{IP: "203.0.113.7", Hostname: "mta"}, []string{"IP/ip_address"}},
+		{"mapped private ip", Server{IP: "::ffff:192.168.1.1", Hostname: "mta"}, []string{"IP/ip_address"}},
+		{"leading hyphen", Server{IP: "93.184.216.34", Hostname: "-mta"}, []string{"Hostname/rfc1123_hostname"}},
+		{"trailing hyphen", Server{IP: "93.184.216.34", Hostname: "mta-.example.com"}, []string{"Hostname/rfc1123_hostname"}},
+		{"empty label", Server{IP: "93.184.216.34", Hostname: "mta..example.com"}, []string{"Hostname/rfc1123_hostname"}},
+		{"space", Server{IP: "93.184.216.34", Hostname: "Test Server"}, []string{"Hostname/rfc1123_hostname"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Server(&tt.server)
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected ValidationErrors, got %v", err)
+			}
+			got := []string{}
+			for _, fe := range errs {
+				assert.NotEmpty(t, fe.Message)
+				got = append(got, fe.Field+"/"+fe.Rule)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// private and reserved ranges can be allowed
+	server = Server{IP: "10.1.2.3", Hostname: "mta"}
+	assert.NoError(t, NewValidator(true).Server(&server))
+}
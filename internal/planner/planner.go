@@ -0,0 +1,204 @@
+// Package planner works out the fewest server changes bringing every hostname
+// within a range of active IPs.
+package planner
+
+import (
+	"GO_APP/internal/model"
+	"fmt"
+	"sort"
+)
+
+// Actions of a plan step
+const (
+	// ActionMove gives the server another hostname, enabling it if needed
+	ActionMove = "move"
+	// ActionEnable enables an inactive server where it is
+	ActionEnable = "enable"
+	// ActionDisable disables an active server where it is
+	ActionDisable = "disable"
+)
+
+// Bounds is the range of active IPs every hostname should end up in. Max is
+// optional, hostnames are then only brought up to Min.
+type Bounds struct {
+	Min int  `json:"min"`
+	Max *int `json:"max,omitempty"`
+}
+
+// Validate checks that the bounds make a non-empty range.
+func (b Bounds) Validate() error {
+	if b.Min < 0 {
+		return fmt.Errorf("min: must not be negative, got %d", b.Min)
+	}
+	if b.Max != nil && *b.Max < b.Min {
+		return fmt.Errorf("max: must be at least min %d, got %d", b.Min, *b.Max)
+	}
+	return nil
+}
+
+// Step is one server change of a plan.
+type Step struct {
+	Action   string `json:"action"`
+	ServerID uint   `json:"server_id"`
+	IP       string `json:"ip"`
+	// Hostname is the hostname of the server before the step
+	Hostname string `json:"hostname"`
+	// To is the hostname a move gives the server
+	To string `json:"to,omitempty"`
+	// Enable is set on the move of an inactive server, which is enabled too
+	Enable bool `json:"enable,omitempty"`
+	// Version is the version of the server the step was planned against
+	Version uint `json:"version"`
+}
+
+// HostnameChange tells how many active IPs a hostname has before and after
+// the plan.
+type HostnameChange struct {
+	Hostname string `json:"hostname"`
+	Before   int    `json:"before"`
+	After    int    `json:"after"`
+}
+
+// Plan is the list of changes bringing the hostnames within Bounds.
+type Plan struct {
+	Bounds    Bounds           `json:"bounds"`
+	Steps     []Step           `json:"steps"`
+	Hostnames []HostnameChange `json:"hostnames"`
+	// Unresolved lists the hostnames still outside the bounds once the plan
+	// is applied, for lack of servers to enable or move
+	Unresolved []string `json:"unresolved"`
+}
+
+// hostname is the planning state of one hostname.
+type hostname struct {
+	name     string
+	before   int
+	active   []model.Server
+	inactive []model.Server
+}
+
+func (h *hostname) deficit(b Bounds) int {
+	if len(h.active) < b.Min {
+		return b.Min - len(h.active)
+	}
+	return 0
+}
+
+func (h *hostname) surplus(b Bounds) int {
+	if b.Max != nil && len(h.active) > *b.Max {
+		return len(h.active) - *b.Max
+	}
+	return 0
+}
+
+// slack is how many active IPs the hostname can give away and stay within bounds.
+func (h *hostname) slack(b Bounds) int {
+	if len(h.active) > b.Min {
+		return len(h.active) - b.Min
+	}
+	return 0
+}
+
+// pop removes the last of servers, the one with the highest id.
+func pop(servers *[]model.Server) model.Server {
+	last := (*servers)[len(*servers)-1]
+	*servers = (*servers)[:len(*servers)-1]
+	return last
+}
+
+// Build plans the changes bringing the hostnames of servers within bounds.
+// Soft-deleted servers are ignored.
+//
+// Each change fixes at most one missing active IP and one extra one, so the
+// plan first moves active IPs from the hostnames having too many to those
+// having too few. The remaining missing IPs are then enabled in place, moved
+// from hostnames with active IPs to spare, or moved from the inactive IPs of
+// other hostnames, in that order; the remaining extra IPs are disabled.
+// Servers are picked highest id first, so the oldest ones stay where they are.
+func Build(servers []model.Server, bounds Bounds) *Plan {
+	byName := map[string]*hostname{}
+	names := []string{}
+	sorted := append([]model.Server{}, servers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	for _, server := range sorted {
+		if server.DeletedAt.Valid {
+			continue
+		}
+		h, ok := byName[server.Hostname]
+		if !ok {
+			h = &hostname{name: server.Hostname}
+			byName[server.Hostname] = h
+			names = append(names, server.Hostname)
+		}
+		if server.Active {
+			h.active = append(h.active, server)
+		} else {
+			h.inactive = append(h.inactive, server)
+		}
+	}
+	sort.Strings(names)
+	hostnames := make([]*hostname, len(names))
+	for i, name := range names {
+		hostnames[i] = byName[name]
+		hostnames[i].before = len(hostnames[i].active)
+	}
+
+	plan := &Plan{Bounds: bounds, Steps: []Step{}, Hostnames: []HostnameChange{}, Unresolved: []string{}}
+	move := func(from, to *hostname, server model.Server) {
+		plan.Steps = append(plan.Steps, Step{Action: ActionMove, ServerID: server.ID, IP: server.IP, Hostname: from.name, To: to.name, Enable: !server.Active, Version: server.Version})
+		server.Hostname, server.Active = to.name, true
+		to.active = append(to.active, server)
+	}
+	// source returns the hostname other than h giving away IPs of the given
+	// kind, the one having the most to give
+	source := func(h *hostname, spare func(*hostname) int) *hostname {
+		var best *hostname
+		for _, other := range hostnames {
+			if other != h && spare(other) > 0 && (best == nil || spare(other) > spare(best)) {
+				best = other
+			}
+		}
+		return best
+	}
+
+	for _, h := range hostnames {
+		for h.deficit(bounds) > 0 {
+			if from := source(h, func(o *hostname) int { return o.surplus(bounds) }); from != nil {
+				move(from, h, pop(&from.active))
+			} else {
+				break
+			}
+		}
+	}
+	for _, h := range hostnames {
+		for h.deficit(bounds) > 0 {
+			if len(h.inactive) > 0 {
+				server := pop(&h.inactive)
+				plan.Steps = append(plan.Steps, Step{Action: ActionEnable, ServerID: server.ID, IP: server.IP, Hostname: h.name, Version: server.Version})
+				server.Active = true
+				h.active = append(h.active, server)
+			} else if from := source(h, func(o *hostname) int { return o.slack(bounds) }); from != nil {
+				move(from, h, pop(&from.active))
+			} else if from := source(h, func(o *hostname) int { return len(o.inactive) - o.deficit(bounds) }); from != nil {
+				move(from, h, pop(&from.inactive))
+			} else {
+				break
+			}
+		}
+	}
+	for _, h := range hostnames {
+		for h.surplus(bounds) > 0 {
+			server := pop(&h.active)
+			plan.Steps = append(plan.Steps, Step{Action: ActionDisable, ServerID: server.ID, IP: server.IP, Hostname: h.name, Version: server.Version})
+			h.inactive = append(h.inactive, server)
+		}
+	}
+
+	for _, h := range hostnames {
+		plan.Hostnames = append(plan.Hostnames, HostnameChange{Hostname: h.name, Before: h.before, After: len(h.active)})
+		if h.deficit(bounds) > 0 || h.surplus(bounds) > 0 {
+			plan.Unresolved = append(plan.Unresolved, h.name)
+		}
+	}
+	return plan
+}
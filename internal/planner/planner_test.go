@@ -0,0 +1,160 @@
+package planner_test
+
+import (
+	"GO_APP/internal/model"
+	"GO_APP/internal/planner"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// servers builds a fleet from hostname, active pairs, numbered from 1
+func servers(specs ...interface{}) []model.Server {
+	fleet := []model.Server{}
+	for i := 0; i < len(specs); i += 2 {
+		id := uint(len(fleet) + 1)
+		fleet = append(fleet, model.Server{
+			Model:    gorm.Model{ID: id},
+			IP:       "11.0.0." + string(rune('0'+id)),
+			Hostname: specs[i].(string),
+			Active:   specs[i+1].(bool),
+			Version:  1,
+		})
+	}
+	return fleet
+}
+
+func actions(plan *planner.Plan) []string {
+	got := []string{}
+	for _, step := range plan.Steps {
+		got = append(got, step.Action+" "+step.Hostname+" "+step.To)
+	}
+	return got
+}
+
+func TestBuild(t *testing.T) {
+	two := 2
+	tests := []struct {
+		name       string
+		fleet      []model.Server
+		bounds     planner.Bounds
+		want       []string
+		unresolved []string
+	}{
+		{
+			name:   "within bounds",
+			fleet:  servers("a", true, "b", true),
+			bounds: planner.Bounds{Min: 1},
+			want:   []string{},
+		},
+		{
+			name:   "surplus moves to the deficit",
+			fleet:  servers("a", true, "a", true, "a", true, "b", false),
+			bounds: planner.Bounds{Min: 1, Max: &two},
+			want:   []string{"move a b"},
+		},
+		{
+			name:   "inactive IPs are enabled in place first",
+			fleet:  servers("a", true, "a", true, "a", true, "b", false),
+			bounds: planner.Bounds{Min: 1},
+			want:   []string{"enable b "},
+		},
+		{
+			name:   "spare active IPs move",
+			fleet:  servers("a", true, "a", true, "a", true, "b", true),
+			bounds: planner.Bounds{Min: 2},
+			want:   []string{"move a b"},
+		},
+		{
+			name:   "spare inactive IPs move and are enabled",
+			fleet:  servers("a", true, "a", false, "a", false, "b", false),
+			bounds: planner.Bounds{Min: 2},
+			want:   []string{"enable a ", "enable b ", "move a b"},
+		},
+		{
+			name:   "inactive IPs needed at home stay",
+			fleet:  servers("a", false, "b", false, "c", true, "c", false),
+			bounds: planner.Bounds{Min: 1},
+			want:   []string{"enable a ", "enable b "},
+		},
+		{
+			name:   "surplus without deficit is disabled",
+			fleet:  servers("a", true, "a", true, "a", true),
+			bounds: planner.Bounds{Min: 1, Max: &two},
+			want:   []string{"disable a "},
+		},
+		{
+			name:       "not enough servers",
+			fleet:      servers("a", true, "b", false),
+			bounds:     planner.Bounds{Min: 2},
+			want:       []string{"enable b "},
+			unresolved: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := planner.Build(tt.fleet, tt.bounds)
+			assert.Equal(t, tt.want, actions(plan))
+			if tt.unresolved == nil {
+				tt.unresolved = []string{}
+			}
+			assert.Equal(t, tt.unresolved, plan.Unresolved)
+		})
+	}
+}
+
+func TestBuildMovesNewestServer(t *testing.T) {
+	fleet := servers("a", true, "a", true, "a", true, "b", false, "c", false)
+	fleet[4].Hostname, fleet[4].DeletedAt = "d", gorm.DeletedAt{Valid: true}
+	two := 2
+	plan := planner.Build(fleet, planner.Bounds{Min: 1, Max: &two})
+
+	if assert.Len(t, plan.Steps, 1) {
+		step := plan.Steps[0]
+		assert.Equal(t, uint(3), step.ServerID)
+		assert.Equal(t, "b", step.To)
+		assert.False(t, step.Enable)
+	}
+	assert.Equal(t, []planner.HostnameChange{{Hostname: "a", Before: 3, After: 2}, {Hostname: "b", Before: 0, After: 1}}, plan.Hostnames)
+}
+
+func TestBoundsValidate(t *testing.T) {
+	one := 1
+	assert.NoError(t, planner.Bounds{Min: 1, Max: &one}.Validate())
+	assert.Error(t, planner.Bounds{Min: -1}.Validate())
+	assert.Error(t, planner.Bounds{Min: 2, Max: &one}.Validate())
+}
+
+func TestApply(t *testing.T) {
+	fleet := servers("a", true, "a", false, "b", true)
+	changed, err := planner.Apply(fleet, []planner.Step{
+		{Action: planner.ActionDisable, ServerID: 1},
+		{Action: planner.ActionMove, ServerID: 2, To: "b", Enable: true},
+		{Action: planner.ActionMove, ServerID: 3, To: "c"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, changed[0].Active)
+	assert.Equal(t, "b", changed[1].Hostname)
+	assert.True(t, changed[1].Active)
+	assert.Equal(t, "c", changed[2].Hostname)
+	assert.True(t, changed[2].Active)
+	// the servers given are left untouched
+	assert.True(t, fleet[0].Active)
+	assert.Equal(t, "a", fleet[1].Hostname)
+
+	_, err = planner.Apply(fleet, []planner.Step{
+		{Action: planner.ActionEnable, ServerID: 9},
+		{Action: "drop", ServerID: 1},
+		{Action: planner.ActionMove, ServerID: 1},
+		{Action: planner.ActionMove, ServerID: 1, To: "-b"},
+	})
+	var errs model.ValidationErrors
+	if assert.ErrorAs(t, err, &errs) {
+		fields := []string{}
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		assert.Equal(t, []string{"changes[0].server_id", "changes[1].action", "changes[2].to", "changes[3].to"}, fields)
+	}
+}
@@ -0,0 +1,59 @@
+package planner
+
+import (
+	"GO_APP/internal/model"
+	"fmt"
+)
+
+// Apply returns servers as they would be once steps are carried out, in
+// order, leaving servers untouched. A step may come from a plan or be written
+// by hand; a move only enables the server when its Enable is set. Steps that
+// can't be carried out are reported as a ValidationErrors naming their index.
+func Apply(servers []model.Server, steps []Step) ([]model.Server, error) {
+	result := append([]model.Server{}, servers...)
+	index := map[uint]int{}
+	for i, server := range result {
+		if !server.DeletedAt.Valid {
+			index[server.ID] = i
+		}
+	}
+
+	errs := model.ValidationErrors{}
+	for i, step := range steps {
+		field := fmt.Sprintf("changes[%d]", i)
+		at, ok := index[step.ServerID]
+		if !ok {
+			errs = append(errs, model.FieldError{Field: field + ".server_id", Rule: "exists", Message: fmt.Sprintf("server %d doesn't exist", step.ServerID)})
+			continue
+		}
+		server := &result[at]
+		switch step.Action {
+		case ActionMove:
+			if problem := model.HostnameProblem(step.To); step.To == "" || problem != "" {
+				if step.To == "" {
+					problem = "is required"
+				}
+				errs = append(errs, model.FieldError{Field: field + ".to", Rule: "rfc1123_hostname", Message: problem})
+				continue
+			}
+			server.Hostname = step.To
+			if step.Enable {
+				server.Enable()
+			}
+		case ActionEnable:
+			server.Enable()
+		case ActionDisable:
+			server.Disable()
+		default:
+			errs = append(errs, model.FieldError{
+				Field:   field + ".action",
+				Rule:    "oneof",
+				Message: fmt.Sprintf("%q is not one of %s, %s, %s", step.Action, ActionMove, ActionEnable, ActionDisable),
+			})
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
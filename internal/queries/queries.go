@@ -1,14 +1,6 @@
 package queries
 
 const (
-	CreateDB = `
-		CREATE TABLE IF NOT EXISTS Servers (
-			id SERIAL PRIMARY KEY,
-			ip TEXT NOT NULL,
-			hostname TEXT NOT NULL,
-			active BOOLEAN NOT NULL
-		);
-	`
 	QueryInsertServerData = `
 		INSERT INTO Servers (ip, hostname, active) VALUES(:ip, :hostname, :active);
 	`
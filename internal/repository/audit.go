@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// AuditLog is the append-only history of the changes made to servers.
+type AuditLog interface {
+	// Append records entry and fills in its ID and CreatedAt.
+	Append(entry *model.AuditEntry) error
+	// List returns a page of the entries matching filter, oldest first.
+	List(filter AuditFilter) (*AuditPage, error)
+	// AsOf returns the entry deciding the state of each server at the moment
+	// at, in server_id order: its last entry up to at, or the first one after
+	// at when there is none. Servers without any entry are left out.
+	AsOf(at time.Time) ([]model.AuditEntry, error)
+}
+
+// AuditFilter narrows down the entries AuditLog.List returns. Zero values
+// match everything.
+type AuditFilter struct {
+	ServerID  uint
+	Actor     string
+	Action    string
+	RequestID string
+	// Since and Until bound CreatedAt, Since included and Until excluded.
+	Since time.Time
+	Until time.Time
+	// Limit is the page size, DefaultLimit when zero and capped at MaxLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string
+}
+
+// Matches reports whether entry passes the filter, the cursor aside.
+func (f AuditFilter) Matches(entry model.AuditEntry) bool {
+	if f.ServerID != 0 && entry.ServerID != f.ServerID {
+		return false
+	}
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if f.RequestID != "" && entry.RequestID != f.RequestID {
+		return false
+	}
+	if !f.Since.IsZero() && entry.CreatedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.CreatedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+func (f AuditFilter) limit() int {
+	return ListOptions{Limit: f.Limit}.limit()
+}
+
+// afterID decodes the cursor into the ID of the last entry of the previous page.
+func (f AuditFilter) afterID() (uint, error) {
+	if f.Cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
+
+func newAuditCursor(last model.AuditEntry) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(last.ID), 10)))
+}
+
+// AuditPage is one page of the audit log.
+type AuditPage struct {
+	Entries []model.AuditEntry
+	// NextCursor fetches the following page, empty on the last one.
+	NextCursor string
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormAuditLog struct {
+	db *gorm.DB
+}
+
+func (l *gormAuditLog) Append(entry *model.AuditEntry) error {
+	return l.db.Create(entry).Error
+}
+
+func (l *gormAuditLog) List(filter AuditFilter) (*AuditPage, error) {
+	after, err := filter.afterID()
+	if err != nil {
+		return nil, err
+	}
+
+	q := l.db.Model(&model.AuditEntry{}).Where("id > ?", after)
+	if filter.ServerID != 0 {
+		q = q.Where("server_id = ?", filter.ServerID)
+	}
+	if filter.Actor != "" {
+		q = q.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		q = q.Where("request_id = ?", filter.RequestID)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("created_at < ?", filter.Until)
+	}
+
+	// fetch one extra row to know whether there is a next page
+	limit := filter.limit()
+	entries := []model.AuditEntry{}
+	if err := q.Order("id").Limit(limit + 1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	page := &AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextCursor = newAuditCursor(entries[limit-1])
+	}
+	return page, nil
+}
+
+func (l *gormAuditLog) AsOf(at time.Time) ([]model.AuditEntry, error) {
+	// ids grow with created_at, so the last entry up to at has the greatest
+	// id below it and the first one after at the smallest above it
+	last := l.db.Model(&model.AuditEntry{}).Select("MAX(id)").
+		Where("created_at <= ?", at).Group("server_id")
+	known := l.db.Model(&model.AuditEntry{}).Select("server_id").
+		Where("created_at <= ?", at)
+	first := l.db.Model(&model.AuditEntry{}).Select("MIN(id)").
+		Where("created_at > ? AND server_id NOT IN (?)", at, known).Group("server_id")
+
+	entries := []model.AuditEntry{}
+	err := l.db.Where("id IN (?) OR id IN (?)", last, first).Order("server_id").Find(&entries).Error
+	return entries, err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormJobStore struct {
+	db *gorm.DB
+}
+
+func (s *gormJobStore) List() ([]model.SchedulerJob, error) {
+	jobs := []model.SchedulerJob{}
+	if err := s.db.Order("name").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (s *gormJobStore) Get(name string) (*model.SchedulerJob, error) {
+	job := &model.SchedulerJob{}
+	err := s.db.Where("name = ?", name).First(job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *gormJobStore) Save(job *model.SchedulerJob) error {
+	read := job.UpdatedAt
+	// the database keeps microseconds, UpdatedAt has to compare equal once read back
+	job.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	if read.IsZero() {
+		job.CreatedAt = job.UpdatedAt
+		result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+		if result.Error == nil && result.RowsAffected == 0 {
+			result.Error = ErrJobExists
+		}
+		if result.Error != nil {
+			job.UpdatedAt = read
+		}
+		return result.Error
+	}
+
+	result := s.db.Model(&model.SchedulerJob{}).
+		Where("name = ? AND updated_at = ?", job.Name, read).
+		Select("job_type", "cron_expr", "run_interval", "params", "paused", "next_run_at", "updated_at").
+		UpdateColumns(job)
+	if result.Error == nil && result.RowsAffected == 0 {
+		// tell a job that changed from one that is gone
+		if _, result.Error = s.Get(job.Name); result.Error == nil {
+			result.Error = ErrStaleJob
+		}
+	}
+	if result.Error != nil {
+		job.UpdatedAt = read
+	}
+	return result.Error
+}
+
+func (s *gormJobStore) SetRunTimes(name string, lastRun time.Time, nextRun *time.Time) error {
+	result := s.db.Model(&model.SchedulerJob{}).Where("name = ?", name).
+		UpdateColumns(map[string]interface{}{"last_run_at": lastRun, "next_run_at": nextRun})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+func (s *gormJobStore) Delete(name string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("name = ?", name).Delete(&model.SchedulerJob{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		if err := tx.Where("job_name = ?", name).Delete(&model.JobRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("job_name = ?", name).Delete(&model.JobLock{}).Error
+	})
+}
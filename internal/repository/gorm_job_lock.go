@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormJobLockTable struct {
+	db *gorm.DB
+}
+
+// at returns the time d from now by the clock of the database. On sqlite it
+// is written the way the driver writes times, so they compare as strings.
+func (t *gormJobLockTable) at(d time.Duration) clause.Expr {
+	if t.db.Dialector.Name() == "postgres" {
+		return gorm.Expr("NOW() + CAST(? AS INTERVAL)", fmt.Sprintf("%d microseconds", d.Microseconds()))
+	}
+	return gorm.Expr("strftime('%Y-%m-%d %H:%M:%f+00:00', 'now', ?)", fmt.Sprintf("%+.3f seconds", d.Seconds()))
+}
+
+// Acquire takes the lock in a single statement, the update of a lock held by
+// another replica being skipped until it expires, so two replicas can't both
+// get it.
+func (t *gormJobLockTable) Acquire(job, holder string, ttl time.Duration) (bool, error) {
+	result := t.db.Model(&model.JobLock{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "job_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"holder", "acquired_at", "expires_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "job_locks.holder = excluded.holder OR job_locks.expires_at <= excluded.acquired_at"},
+		}},
+	}).Create(map[string]interface{}{
+		"job_name":    job,
+		"holder":      holder,
+		"acquired_at": t.at(0),
+		"expires_at":  t.at(ttl),
+	})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (t *gormJobLockTable) Renew(job, holder string, ttl time.Duration) (bool, error) {
+	result := t.db.Model(&model.JobLock{}).
+		Where("job_name = ? AND holder = ?", job, holder).
+		Update("expires_at", t.at(ttl))
+	return result.RowsAffected == 1, result.Error
+}
+
+func (t *gormJobLockTable) Release(job, holder string, keep time.Duration) error {
+	_, err := t.Renew(job, holder, keep)
+	return err
+}
+
+func (t *gormJobLockTable) List() ([]model.JobLock, error) {
+	locks := []model.JobLock{}
+	err := t.db.Select("*, expires_at > ? AS held", t.at(0)).Order("job_name").Find(&locks).Error
+	if err != nil {
+		return nil, err
+	}
+	return locks, nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormJobRunLog struct {
+	db *gorm.DB
+}
+
+func (l *gormJobRunLog) Record(run *model.JobRun) error {
+	return l.db.Create(run).Error
+}
+
+func (l *gormJobRunLog) List(filter JobRunFilter) (*JobRunPage, error) {
+	before, err := filter.beforeID()
+	if err != nil {
+		return nil, err
+	}
+
+	q := l.db.Model(&model.JobRun{}).Where("job_name = ?", filter.Job)
+	if before != 0 {
+		q = q.Where("id < ?", before)
+	}
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+
+	// fetch one extra row to know whether there is a next page
+	limit := filter.limit()
+	runs := []model.JobRun{}
+	if err := q.Order("id DESC").Limit(limit + 1).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	page := &JobRunPage{Runs: runs}
+	if len(runs) > limit {
+		page.Runs = runs[:limit]
+		page.NextCursor = newJobRunCursor(runs[limit-1])
+	}
+	return page, nil
+}
+
+func (l *gormJobRunLog) Prune(job string, keep int, cutoff time.Time) (int64, error) {
+	var pruned int64
+	if !cutoff.IsZero() {
+		result := l.db.Where("job_name = ? AND started_at < ?", job, cutoff.UTC()).Delete(&model.JobRun{})
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		pruned = result.RowsAffected
+	}
+	if keep > 0 {
+		// the id of the oldest run kept, there is nothing to prune without one
+		oldest := []uint{}
+		err := l.db.Model(&model.JobRun{}).Where("job_name = ?", job).
+			Order("id DESC").Offset(keep-1).Limit(1).Pluck("id", &oldest).Error
+		if err != nil {
+			return pruned, err
+		}
+		if len(oldest) == 1 {
+			result := l.db.Where("job_name = ? AND id < ?", job, oldest[0]).Delete(&model.JobRun{})
+			if result.Error != nil {
+				return pruned, result.Error
+			}
+			pruned += result.RowsAffected
+		}
+	}
+	return pruned, nil
+}
+
+func (l *gormJobRunLog) Counts() (map[string]int, error) {
+	rows := []struct {
+		JobName string
+		Runs    int
+	}{}
+	err := l.db.Model(&model.JobRun{}).Select("job_name, COUNT(*) AS runs").Group("job_name").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.JobName] = row.Runs
+	}
+	return counts, nil
+}
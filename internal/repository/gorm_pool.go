@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormPoolRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPoolRepository) Get(id uint) (*model.Pool, error) {
+	pool := model.Pool{}
+	err := r.db.Where("id = ?", id).First(&pool).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+func (r *gormPoolRepository) List() ([]model.Pool, error) {
+	pools := []model.Pool{}
+	if err := r.db.Order("name").Find(&pools).Error; err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+// nameTaken reports whether a live pool other than pool has its name.
+func (r *gormPoolRepository) nameTaken(pool model.Pool) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Pool{}).Where("name = ? AND id <> ?", pool.Name, pool.ID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormPoolRepository) Create(pool *model.Pool) error {
+	// like servers, a conflict is a no-op rather than an aborted transaction
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(pool)
+	if isUniqueViolation(result.Error) {
+		return ErrPoolExists
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		pool.ID = 0
+		return ErrPoolExists
+	}
+	return nil
+}
+
+func (r *gormPoolRepository) Update(pool *model.Pool) error {
+	taken, err := r.nameTaken(*pool)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrPoolExists
+	}
+	err = r.db.Model(&model.Pool{}).
+		Where("id = ?", pool.ID).
+		Updates(map[string]interface{}{
+			"name":           pool.Name,
+			"purpose":        pool.Purpose,
+			"owner":          pool.Owner,
+			"min_active_ips": pool.MinActiveIPs,
+		}).Error
+	if isUniqueViolation(err) {
+		return ErrPoolExists
+	}
+	return err
+}
+
+func (r *gormPoolRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Server{}).Where("pool_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %d servers belong to pool %d", ErrPoolInUse, count, id)
+		}
+		err := tx.Unscoped().Model(&model.Server{}).
+			Where("pool_id = ? AND deleted_at IS NOT NULL", id).
+			UpdateColumn("pool_id", nil).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&model.Pool{}, id).Error
+	})
+}
+
+func (r *gormPoolRepository) Report() ([]PoolStats, error) {
+	pools, err := r.List()
+	if err != nil {
+		return nil, err
+	}
+	servers := []model.Server{}
+	err = r.db.Select("ip", "active", "pool_id").Where("pool_id IS NOT NULL").Order("id").Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	return poolReport(pools, servers), nil
+}
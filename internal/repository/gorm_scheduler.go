@@ -0,0 +1,24 @@
+package repository
+
+import "gorm.io/gorm"
+
+type gormSchedulerRepository struct {
+	db *gorm.DB
+}
+
+// NewGormSchedulerRepository returns a SchedulerRepository backed by db.
+func NewGormSchedulerRepository(db *gorm.DB) SchedulerRepository {
+	return &gormSchedulerRepository{db: db}
+}
+
+func (r *gormSchedulerRepository) Jobs() JobStore {
+	return &gormJobStore{db: r.db}
+}
+
+func (r *gormSchedulerRepository) JobRuns() JobRunLog {
+	return &gormJobRunLog{db: r.db}
+}
+
+func (r *gormSchedulerRepository) JobLocks() JobLockTable {
+	return &gormJobLockTable{db: r.db}
+}
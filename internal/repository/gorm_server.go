@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormServerRepository struct {
+	db  *gorm.DB
+	key UniqueKey
+}
+
+// NewGormServerRepository returns a ServerRepository backed by db, enforcing
+// key. The database enforces it as well through the index created by the
+// migrations, which has to be built with the same key.
+func NewGormServerRepository(db *gorm.DB, key UniqueKey) ServerRepository {
+	return &gormServerRepository{db: db, key: key}
+}
+
+func (r *gormServerRepository) Get(id uint) (*model.Server, error) {
+	server := model.Server{}
+	err := r.db.Where("id = ?", id).First(&server).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+func (r *gormServerRepository) GetForUpdate(id uint) (*model.Server, error) {
+	// sqlite has no row locks and ignores the clause, its transactions are
+	// serialized by the single connection instead
+	locked := &gormServerRepository{db: r.db.Clauses(clause.Locking{Strength: "UPDATE"}), key: r.key}
+	return locked.Get(id)
+}
+
+func (r *gormServerRepository) GetDeletedForUpdate(id uint) (*model.Server, error) {
+	server := model.Server{}
+	err := r.db.Unscoped().
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&server).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+// filter adds the conditions of f to q.
+func (r *gormServerRepository) filter(q *gorm.DB, f ServerFilter) *gorm.DB {
+	if f.IncludeDeleted {
+		q = q.Unscoped()
+	}
+	if f.IP != "" {
+		q = q.Where("ip = ?", f.IP)
+	}
+	if f.Active != nil {
+		q = q.Where("active = ?", *f.Active)
+	}
+	if f.Hostname != "" {
+		q = q.Where("hostname = ?", f.Hostname)
+	}
+	if f.PoolID != nil {
+		q = q.Where("pool_id = ?", *f.PoolID)
+	}
+	if f.HostnamePrefix != "" {
+		// unlike LIKE, SUBSTR is case sensitive on every dialect
+		q = q.Where("SUBSTR(hostname, 1, ?) = ?", utf8.RuneCountInString(f.HostnamePrefix), f.HostnamePrefix)
+	}
+	if f.CIDR != nil {
+		if r.db.Dialector.Name() == "postgres" {
+			q = q.Where("CAST(ip AS inet) <<= CAST(? AS cidr)", f.CIDR.String())
+		} else {
+			q = q.Where("ip_in_cidr(ip, ?)", f.CIDR.String())
+		}
+	}
+	if !f.CreatedUntil.IsZero() {
+		q = q.Where("created_at <= ?", f.CreatedUntil)
+	}
+	return r.selector(q, f.Selector)
+}
+
+// selector adds the requirements of s to q. The value of a label is NULL when
+// the server doesn't have it. On postgres labels is a jsonb column, equality
+// is tested by containment so its GIN index is used.
+func (r *gormServerRepository) selector(q *gorm.DB, s Selector) *gorm.DB {
+	postgres := r.db.Dialector.Name() == "postgres"
+	for _, req := range s {
+		// label keys can't hold quotes, see model.LabelKeyProblem
+		value, arg := "json_extract(labels, ?)", `$."`+req.Key+`"`
+		if postgres {
+			value, arg = "labels ->> ?", req.Key
+		}
+		switch req.Op {
+		case SelectorExists:
+			q = q.Where(value+" IS NOT NULL", arg)
+		case SelectorNotExists:
+			q = q.Where(value+" IS NULL", arg)
+		case SelectorEquals, SelectorIn:
+			if !postgres {
+				q = q.Where(value+" IN ?", arg, req.Values)
+				continue
+			}
+			contains := make([]string, len(req.Values))
+			args := make([]interface{}, len(req.Values))
+			for i, v := range req.Values {
+				data, _ := json.Marshal(map[string]string{req.Key: v})
+				contains[i], args[i] = "labels @> CAST(? AS jsonb)", string(data)
+			}
+			q = q.Where("("+strings.Join(contains, " OR ")+")", args...)
+		case SelectorNotEquals, SelectorNotIn:
+			q = q.Where("("+value+" IS NULL OR "+value+" NOT IN ?)", arg, arg, req.Values)
+		}
+	}
+	return q
+}
+
+func (r *gormServerRepository) List(opts ListOptions) (*Page, error) {
+	sort, err := opts.sortField()
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(opts.Cursor, sort, opts.Desc)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, cmp := "ASC", ">"
+	if opts.Desc {
+		dir, cmp = "DESC", "<"
+	}
+
+	q := r.filter(r.db.Model(&model.Server{}), opts.ServerFilter)
+	if after != nil {
+		switch sort {
+		case "id":
+			q = q.Where("id "+cmp+" ?", after.ID)
+		case "hostname":
+			q = q.Where("(hostname "+cmp+" ? OR (hostname = ? AND id "+cmp+" ?))", after.Hostname, after.Hostname, after.ID)
+		case "created_at":
+			q = q.Where("(created_at "+cmp+" ? OR (created_at = ? AND id "+cmp+" ?))", after.Created, after.Created, after.ID)
+		}
+	}
+	if sort != "id" {
+		q = q.Order(sort + " " + dir)
+	}
+	q = q.Order("id " + dir)
+
+	// fetch one extra row to know whether there is a next page
+	limit := opts.limit()
+	servers := []model.Server{}
+	if err := q.Limit(limit + 1).Find(&servers).Error; err != nil {
+		return nil, err
+	}
+
+	page := &Page{Servers: servers}
+	if len(servers) > limit {
+		page.Servers = servers[:limit]
+		page.NextCursor = newCursor(sort, opts.Desc, servers[limit-1])
+	}
+	return page, nil
+}
+
+// eachPageSize is how many servers Each reads at a time.
+const eachPageSize = 500
+
+// Each reads the servers a page at a time, from the id the previous page
+// ended at, so no query stays open while fn runs: with a single connection,
+// as on sqlite, a slow fn would otherwise hold up every other query.
+func (r *gormServerRepository) Each(filter ServerFilter, fn func(server model.Server) error) error {
+	var last uint
+	for {
+		servers := []model.Server{}
+		err := r.filter(r.db.Model(&model.Server{}), filter).
+			Where("id > ?", last).
+			Order("id").
+			Limit(eachPageSize).
+			Find(&servers).Error
+		if err != nil {
+			return err
+		}
+		for _, server := range servers {
+			if err := fn(server); err != nil {
+				return err
+			}
+		}
+		if len(servers) < eachPageSize {
+			return nil
+		}
+		last = servers[len(servers)-1].ID
+	}
+}
+
+// conflict returns the ConflictError for server, naming the live server other
+// than itself that holds its unique key.
+func (r *gormServerRepository) conflict(server model.Server) error {
+	q := r.db.Model(&model.Server{}).Where("ip = ?", server.IP).Where("id <> ?", server.ID)
+	if r.key == UniqueIPHostname {
+		q = q.Where("hostname = ?", server.Hostname)
+	}
+	existing := []model.Server{}
+	if err := q.Order("id").Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	return &ConflictError{ID: existing[0].ID, IP: server.IP, Hostname: server.Hostname, Key: r.key}
+}
+
+// writeError turns a unique index violation into a ConflictError.
+func (r *gormServerRepository) writeError(err error, server model.Server) error {
+	if isUniqueViolation(err) {
+		return &ConflictError{IP: server.IP, Hostname: server.Hostname, Key: r.key}
+	}
+	return err
+}
+
+func (r *gormServerRepository) Create(server *model.Server) error {
+	// a conflicting row turns the insert into a no-op rather than an error,
+	// which would abort the whole transaction on postgres
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(server)
+	if result.Error != nil {
+		return r.writeError(result.Error, *server)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	server.ID = 0
+	err := r.conflict(*server)
+	if err == nil {
+		// the conflicting row isn't visible to this transaction
+		err = &ConflictError{IP: server.IP, Hostname: server.Hostname, Key: r.key}
+	}
+	return err
+}
+
+func (r *gormServerRepository) Update(server *model.Server) error {
+	if err := r.conflict(*server); err != nil {
+		return err
+	}
+	// a map, unlike a struct, makes Updates write zero values too
+	err := r.db.Model(&model.Server{}).
+		Where("id = ?", server.ID).
+		Updates(map[string]interface{}{
+			"ip":       server.IP,
+			"hostname": server.Hostname,
+			"active":   server.Active,
+			"pool_id":  server.PoolID,
+			"labels":   server.Labels,
+			"version":  gorm.Expr("version + 1"),
+		}).Error
+	if err != nil {
+		return r.writeError(err, *server)
+	}
+	server.Version++
+	return nil
+}
+
+func (r *gormServerRepository) SetActive(server *model.Server, active bool) error {
+	err := r.db.Model(&model.Server{}).
+		Where("id = ?", server.ID).
+		Updates(map[string]interface{}{"active": active, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return err
+	}
+	server.Active = active
+	server.Version++
+	return nil
+}
+
+func (r *gormServerRepository) Delete(id uint) error {
+	return r.db.Delete(&model.Server{}, id).Error
+}
+
+func (r *gormServerRepository) Restore(server *model.Server) error {
+	if err := r.conflict(*server); err != nil {
+		return err
+	}
+	err := r.db.Unscoped().Model(&model.Server{}).
+		Where("id = ?", server.ID).
+		Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")}).Error
+	if err != nil {
+		return r.writeError(err, *server)
+	}
+	server.DeletedAt = gorm.DeletedAt{}
+	server.Version++
+	return nil
+}
+
+func (r *gormServerRepository) Purge(id uint) error {
+	return r.db.Unscoped().Delete(&model.Server{}, id).Error
+}
+
+func (r *gormServerRepository) PurgeDeleted(cutoff time.Time) (int64, error) {
+	result := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Delete(&model.Server{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *gormServerRepository) HostnamesBelowThreshold(thresh int) ([]string, error) {
+	hostnames := []string{}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		return tx.Table("servers").
+			Select("hostname as Hostnames").
+			Where("deleted_at IS NULL").
+			Group("hostname").
+			Having("COUNT(CASE WHEN active THEN 1 END) <= ?", thresh).
+			Order("hostname").
+			Scan(&hostnames).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return hostnames, nil
+}
+
+// activeCountOps maps the operators of ActiveCount to SQL.
+var activeCountOps = map[string]string{"": "<=", OpLT: "<", OpLTE: "<=", OpEQ: "=", OpGTE: ">=", OpGT: ">"}
+
+func (r *gormServerRepository) HostnameReport(selector Selector, count ActiveCount) ([]HostnameStats, error) {
+	// the hostnames are selected in SQL, only their servers are loaded
+	const active = "COUNT(CASE WHEN active THEN 1 END)"
+	selected := r.selector(r.db.Model(&model.Server{}), selector).
+		Select("hostname").
+		Group("hostname").
+		Having(active+" "+activeCountOps[count.Op]+" ?", count.Thresh)
+	if count.Min != nil {
+		selected = selected.Having(active+" >= ?", *count.Min)
+	}
+	if count.Max != nil {
+		selected = selected.Having(active+" <= ?", *count.Max)
+	}
+
+	servers := []model.Server{}
+	err := r.selector(r.db.Select("hostname", "ip", "active"), selector).
+		Where("hostname IN (?)", selected).
+		Order("id").
+		Find(&servers).Error
+	if err != nil {
+		return nil, err
+	}
+	return hostnameReport(servers, count), nil
+}
+
+func (r *gormServerRepository) ActiveIPs(selector Selector) ([]string, error) {
+	ips := []string{}
+	err := r.selector(r.db.Table("servers"), selector).
+		Select("ip as IP").
+		Where("active = ?", true).
+		Where("deleted_at IS NULL").
+		Order("id").
+		Scan(&ips).Error
+	if err != nil {
+		return nil, err
+	}
+	return ips, nil
+}
+
+func (r *gormServerRepository) Utilisation() UtilisationLog {
+	return &gormUtilisationLog{db: r.db}
+}
+
+func (r *gormServerRepository) Pools() PoolRepository {
+	return &gormPoolRepository{db: r.db}
+}
+
+func (r *gormServerRepository) Audit() AuditLog {
+	return &gormAuditLog{db: r.db}
+}
+
+func (r *gormServerRepository) UniqueKey() UniqueKey {
+	return r.key
+}
+
+// Transaction nests as a savepoint when r is already bound to a transaction.
+func (r *gormServerRepository) Transaction(fn func(repo ServerRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormServerRepository{db: tx, key: r.key})
+	})
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type gormUtilisationLog struct {
+	db *gorm.DB
+}
+
+func (l *gormUtilisationLog) Record(samples []model.UtilisationSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	return l.db.CreateInBatches(samples, 500).Error
+}
+
+func (l *gormUtilisationLog) Series(query UtilisationQuery) ([]UtilisationPoint, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	samples := []model.UtilisationSample{}
+	err := l.db.
+		Where("hostname = ? AND taken_at >= ? AND taken_at < ?", query.Hostname, query.From.UTC(), query.To.UTC()).
+		Order("taken_at").
+		Find(&samples).Error
+	if err != nil {
+		return nil, err
+	}
+	return downsample(samples, query), nil
+}
+
+func (l *gormUtilisationLog) PurgeBefore(cutoff time.Time) (int64, error) {
+	result := l.db.Where("taken_at < ?", cutoff.UTC()).Delete(&model.UtilisationSample{})
+	return result.RowsAffected, result.Error
+}
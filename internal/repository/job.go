@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrJobExists is returned when creating a job whose name is taken.
+	ErrJobExists = errors.New("job already exists")
+	// ErrStaleJob is returned when saving a job that changed since it was read.
+	ErrStaleJob = errors.New("job changed since it was read")
+)
+
+// JobStore keeps the jobs of the cron so they survive its restarts. The
+// stored jobs are the ones the replicas sharing the database follow.
+type JobStore interface {
+	// List returns every job, sorted by name.
+	List() ([]model.SchedulerJob, error)
+	// Get returns the job with the given name, or ErrNotFound.
+	Get(name string) (*model.SchedulerJob, error)
+	// Save stores job and sets its UpdatedAt. A job with a zero UpdatedAt is
+	// created, or ErrJobExists returned when the name is taken. Any other is
+	// written over the stored one only if it still has that UpdatedAt, so a
+	// stale copy can't undo a newer change: ErrStaleJob is returned when it
+	// changed since it was read, ErrNotFound when it is gone. LastRunAt is
+	// only written on creation, SetRunTimes keeps it afterwards.
+	Save(job *model.SchedulerJob) error
+	// SetRunTimes stores when the job called name last ran and is due next,
+	// nothing else, or returns ErrNotFound. Its UpdatedAt stays as it is.
+	SetRunTimes(name string, lastRun time.Time, nextRun *time.Time) error
+	// Delete removes the job with the given name, its runs and its lock, or
+	// returns ErrNotFound.
+	Delete(name string) error
+}
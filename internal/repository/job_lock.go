@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"time"
+)
+
+// JobLockTable holds the leases the replicas of the cron take on the jobs, so
+// a run happens on a single replica. Their times come from the clock of the
+// database, which the replicas share, never from their own.
+type JobLockTable interface {
+	// Acquire gives the lock of job to holder for ttl, unless another holder
+	// has it. It reports whether holder got it.
+	Acquire(job, holder string, ttl time.Duration) (bool, error)
+	// Renew makes the lock of job expire ttl from now if holder still has it,
+	// and reports whether it does.
+	Renew(job, holder string, ttl time.Duration) (bool, error)
+	// Release makes the lock of job expire keep from now if holder still has
+	// it, right away when keep is zero.
+	Release(job, holder string, keep time.Duration) error
+	// List returns every lock, sorted by job, expired ones included.
+	List() ([]model.JobLock, error)
+}
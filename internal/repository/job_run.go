@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// JobRunLog is the history of the runs of the cron jobs.
+type JobRunLog interface {
+	// Record stores run and fills in its ID.
+	Record(run *model.JobRun) error
+	// List returns a page of the runs matching filter, newest first.
+	List(filter JobRunFilter) (*JobRunPage, error)
+	// Prune removes the runs of job started before cutoff, unless it is zero,
+	// then all but its keep newest runs, unless keep is zero. It returns how
+	// many runs were removed.
+	Prune(job string, keep int, cutoff time.Time) (int64, error)
+	// Counts returns how many runs of each job are kept, by job.
+	Counts() (map[string]int, error)
+}
+
+// JobRunFilter selects the runs of a job, of one Status when it is set.
+type JobRunFilter struct {
+	Job    string
+	Status string
+	// Limit is the page size, DefaultLimit when zero and capped at MaxLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string
+}
+
+// Matches reports whether run passes the filter, the cursor aside.
+func (f JobRunFilter) Matches(run model.JobRun) bool {
+	return run.JobName == f.Job && (f.Status == "" || run.Status == f.Status)
+}
+
+func (f JobRunFilter) limit() int {
+	return ListOptions{Limit: f.Limit}.limit()
+}
+
+// beforeID decodes the cursor into the ID of the last run of the previous
+// page, 0 for the first page.
+func (f JobRunFilter) beforeID() (uint, error) {
+	if f.Cursor == "" {
+		return 0, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(data), 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return uint(id), nil
+}
+
+func newJobRunCursor(last model.JobRun) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(last.ID), 10)))
+}
+
+// JobRunPage is one page of the runs of a job.
+type JobRunPage struct {
+	Runs []model.JobRun
+	// NextCursor fetches the following page, empty on the last one.
+	NextCursor string
+}
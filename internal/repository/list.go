@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLimit is the page size used when ListOptions.Limit is not set.
+	DefaultLimit = 100
+	// MaxLimit is the largest page size List hands out.
+	MaxLimit = 1000
+)
+
+var (
+	// ErrInvalidCursor is returned when a cursor can't be decoded or was
+	// issued for a different sort order.
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrInvalidSort is returned for a sort field List doesn't support.
+	ErrInvalidSort = errors.New("invalid sort field")
+)
+
+// SortFields lists the fields List can sort on.
+var SortFields = []string{"id", "hostname", "created_at"}
+
+// ServerFilter narrows down the servers a query looks at. Zero values match
+// everything.
+type ServerFilter struct {
+	IP             string
+	Active         *bool
+	Hostname       string
+	HostnamePrefix string
+	CIDR           *net.IPNet
+	PoolID         *uint
+	// Selector picks servers by their labels.
+	Selector Selector
+	// IncludeDeleted also looks at soft-deleted servers.
+	IncludeDeleted bool
+	// CreatedUntil, when set, leaves out the servers created after it.
+	CreatedUntil time.Time
+}
+
+// Matches reports whether server passes the filter.
+func (f ServerFilter) Matches(server model.Server) bool {
+	if server.DeletedAt.Valid && !f.IncludeDeleted {
+		return false
+	}
+	if f.IP != "" && server.IP != f.IP {
+		return false
+	}
+	if f.Active != nil && server.Active != *f.Active {
+		return false
+	}
+	if f.Hostname != "" && server.Hostname != f.Hostname {
+		return false
+	}
+	if f.PoolID != nil && (server.PoolID == nil || *server.PoolID != *f.PoolID) {
+		return false
+	}
+	if f.HostnamePrefix != "" && !strings.HasPrefix(server.Hostname, f.HostnamePrefix) {
+		return false
+	}
+	if f.CIDR != nil && !ipInCIDR(server.IP, f.CIDR) {
+		return false
+	}
+	if !f.CreatedUntil.IsZero() && server.CreatedAt.After(f.CreatedUntil) {
+		return false
+	}
+	if !f.Selector.Matches(server.Labels) {
+		return false
+	}
+	return true
+}
+
+func ipInCIDR(ip string, cidr *net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	return parsed != nil && cidr.Contains(parsed)
+}
+
+// ListOptions selects a page of servers.
+type ListOptions struct {
+	ServerFilter
+	// Sort is one of SortFields, id when empty. Ties are broken by id.
+	Sort string
+	Desc bool
+	// Limit is the page size, DefaultLimit when zero and capped at MaxLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first one.
+	Cursor string
+}
+
+func (o ListOptions) sortField() (string, error) {
+	if o.Sort == "" {
+		return "id", nil
+	}
+	for _, field := range SortFields {
+		if o.Sort == field {
+			return field, nil
+		}
+	}
+	return "", fmt.Errorf("%w %q", ErrInvalidSort, o.Sort)
+}
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return DefaultLimit
+	}
+	if o.Limit > MaxLimit {
+		return MaxLimit
+	}
+	return o.Limit
+}
+
+// Page is one page of a server listing.
+type Page struct {
+	Servers []model.Server
+	// NextCursor fetches the following page, empty on the last one.
+	NextCursor string
+}
+
+// cursor is the position after the last server of a page. It is handed out
+// base64 encoded so clients treat it as opaque.
+type cursor struct {
+	Sort     string    `json:"s"`
+	Desc     bool      `json:"d,omitempty"`
+	ID       uint      `json:"id"`
+	Hostname string    `json:"h,omitempty"`
+	Created  time.Time `json:"c,omitempty"`
+}
+
+func newCursor(sort string, desc bool, last model.Server) string {
+	c := cursor{Sort: sort, Desc: desc, ID: last.ID}
+	switch sort {
+	case "hostname":
+		c.Hostname = last.Hostname
+	case "created_at":
+		c.Created = last.CreatedAt
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw, sort string, desc bool) (*cursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := cursor{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, fmt.Errorf("%w: it was issued for another sort order", ErrInvalidCursor)
+	}
+	return &c, nil
+}
+
+// after reports whether server comes after the cursor in its sort order.
+func (c *cursor) after(server model.Server) bool {
+	if c == nil {
+		return true
+	}
+	cmp := 0
+	switch c.Sort {
+	case "hostname":
+		cmp = strings.Compare(server.Hostname, c.Hostname)
+	case "created_at":
+		switch {
+		case server.CreatedAt.Before(c.Created):
+			cmp = -1
+		case server.CreatedAt.After(c.Created):
+			cmp = 1
+		}
+	}
+	if cmp == 0 {
+		switch {
+		case server.ID < c.ID:
+			cmp = -1
+		case server.ID > c.ID:
+			cmp = 1
+		}
+	}
+	if c.Desc {
+		return cmp < 0
+	}
+	return cmp > 0
+}
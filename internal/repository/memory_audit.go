@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sort"
+	"time"
+)
+
+// memoryAuditLog keeps the entries next to the servers of the repository, so
+// they are rolled back along with them.
+type memoryAuditLog struct {
+	repo *memoryServerRepository
+}
+
+func (l *memoryAuditLog) Append(entry *model.AuditEntry) error {
+	defer l.repo.lock()()
+
+	data := l.repo.data
+	entry.ID = uint(len(data.audit)) + 1
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	data.audit = append(data.audit, *entry)
+	return nil
+}
+
+func (l *memoryAuditLog) List(filter AuditFilter) (*AuditPage, error) {
+	after, err := filter.afterID()
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.repo.lock()()
+
+	page := &AuditPage{Entries: []model.AuditEntry{}}
+	limit := filter.limit()
+	for _, entry := range l.repo.data.audit {
+		if entry.ID <= after || !filter.Matches(entry) {
+			continue
+		}
+		if len(page.Entries) == limit {
+			page.NextCursor = newAuditCursor(page.Entries[limit-1])
+			break
+		}
+		page.Entries = append(page.Entries, entry)
+	}
+	return page, nil
+}
+
+func (l *memoryAuditLog) AsOf(at time.Time) ([]model.AuditEntry, error) {
+	defer l.repo.lock()()
+
+	decided := map[uint]model.AuditEntry{}
+	for _, entry := range l.repo.data.audit {
+		// entries are in id order, a later one up to at replaces the previous
+		// but only the first one after at is kept
+		if _, ok := decided[entry.ServerID]; !ok || !entry.CreatedAt.After(at) {
+			decided[entry.ServerID] = entry
+		}
+	}
+
+	entries := make([]model.AuditEntry, 0, len(decided))
+	for _, entry := range decided {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ServerID < entries[j].ServerID })
+	return entries, nil
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sort"
+	"time"
+)
+
+// memoryJobStore keeps the jobs of a memory scheduler repository.
+type memoryJobStore struct {
+	repo *memorySchedulerRepository
+}
+
+func (s *memoryJobStore) List() ([]model.SchedulerJob, error) {
+	defer s.repo.lock()()
+
+	jobs := []model.SchedulerJob{}
+	for _, job := range s.repo.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs, nil
+}
+
+func (s *memoryJobStore) Get(name string) (*model.SchedulerJob, error) {
+	defer s.repo.lock()()
+
+	job, ok := s.repo.jobs[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	job.Params = copyParams(job.Params)
+	return &job, nil
+}
+
+func (s *memoryJobStore) Save(job *model.SchedulerJob) error {
+	defer s.repo.lock()()
+
+	stored, ok := s.repo.jobs[job.Name]
+	switch {
+	case job.UpdatedAt.IsZero() && ok:
+		return ErrJobExists
+	case job.UpdatedAt.IsZero():
+		job.CreatedAt = time.Now()
+	case !ok:
+		return ErrNotFound
+	case !stored.UpdatedAt.Equal(job.UpdatedAt):
+		return ErrStaleJob
+	default:
+		job.CreatedAt, job.LastRunAt = stored.CreatedAt, stored.LastRunAt
+	}
+	job.UpdatedAt = time.Now()
+	if !job.UpdatedAt.After(stored.UpdatedAt) {
+		// two saves in the same tick of the clock still tell apart
+		job.UpdatedAt = stored.UpdatedAt.Add(time.Nanosecond)
+	}
+	saved := *job
+	saved.Params = copyParams(job.Params)
+	s.repo.jobs[job.Name] = saved
+	return nil
+}
+
+func (s *memoryJobStore) SetRunTimes(name string, lastRun time.Time, nextRun *time.Time) error {
+	defer s.repo.lock()()
+
+	job, ok := s.repo.jobs[name]
+	if !ok {
+		return ErrNotFound
+	}
+	job.LastRunAt, job.NextRunAt = &lastRun, nextRun
+	s.repo.jobs[name] = job
+	return nil
+}
+
+func (s *memoryJobStore) Delete(name string) error {
+	defer s.repo.lock()()
+
+	if _, ok := s.repo.jobs[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.repo.jobs, name)
+	runs := []model.JobRun{}
+	for _, run := range s.repo.runs {
+		if run.JobName != name {
+			runs = append(runs, run)
+		}
+	}
+	s.repo.runs = runs
+	delete(s.repo.locks, name)
+	return nil
+}
+
+// copyParams keeps the stored params from being changed through the caller's map
+func copyParams(params map[string]string) map[string]string {
+	if params == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(params))
+	for k, v := range params {
+		copied[k] = v
+	}
+	return copied
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sort"
+	"time"
+)
+
+// memoryJobLockTable keeps the locks of a memory scheduler repository. Its
+// replicas share a process, and a clock.
+type memoryJobLockTable struct {
+	repo *memorySchedulerRepository
+}
+
+func (t *memoryJobLockTable) Acquire(job, holder string, ttl time.Duration) (bool, error) {
+	defer t.repo.lock()()
+
+	now := time.Now()
+	if lock, ok := t.repo.locks[job]; ok && lock.Holder != holder && lock.ExpiresAt.After(now) {
+		return false, nil
+	}
+	t.repo.locks[job] = model.JobLock{JobName: job, Holder: holder, AcquiredAt: now, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (t *memoryJobLockTable) Renew(job, holder string, ttl time.Duration) (bool, error) {
+	defer t.repo.lock()()
+
+	lock, ok := t.repo.locks[job]
+	if !ok || lock.Holder != holder {
+		return false, nil
+	}
+	lock.ExpiresAt = time.Now().Add(ttl)
+	t.repo.locks[job] = lock
+	return true, nil
+}
+
+func (t *memoryJobLockTable) Release(job, holder string, keep time.Duration) error {
+	_, err := t.Renew(job, holder, keep)
+	return err
+}
+
+func (t *memoryJobLockTable) List() ([]model.JobLock, error) {
+	defer t.repo.lock()()
+
+	now := time.Now()
+	locks := []model.JobLock{}
+	for _, lock := range t.repo.locks {
+		lock.Held = lock.ExpiresAt.After(now)
+		locks = append(locks, lock)
+	}
+	sort.Slice(locks, func(i, j int) bool { return locks[i].JobName < locks[j].JobName })
+	return locks, nil
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"time"
+)
+
+// memoryJobRunLog keeps the runs of a memory scheduler repository.
+type memoryJobRunLog struct {
+	repo *memorySchedulerRepository
+}
+
+func (l *memoryJobRunLog) Record(run *model.JobRun) error {
+	defer l.repo.lock()()
+
+	l.repo.nextRunID++
+	run.ID = l.repo.nextRunID
+	l.repo.runs = append(l.repo.runs, *run)
+	return nil
+}
+
+func (l *memoryJobRunLog) List(filter JobRunFilter) (*JobRunPage, error) {
+	before, err := filter.beforeID()
+	if err != nil {
+		return nil, err
+	}
+
+	defer l.repo.lock()()
+
+	page := &JobRunPage{Runs: []model.JobRun{}}
+	limit := filter.limit()
+	runs := l.repo.runs
+	// runs are recorded in id order, walk them newest first
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if (before != 0 && run.ID >= before) || !filter.Matches(run) {
+			continue
+		}
+		if len(page.Runs) == limit {
+			page.NextCursor = newJobRunCursor(page.Runs[limit-1])
+			break
+		}
+		page.Runs = append(page.Runs, run)
+	}
+	return page, nil
+}
+
+func (l *memoryJobRunLog) Prune(job string, keep int, cutoff time.Time) (int64, error) {
+	defer l.repo.lock()()
+
+	kept := []model.JobRun{}
+	newer := 0
+	for i := len(l.repo.runs) - 1; i >= 0; i-- {
+		run := l.repo.runs[i]
+		if run.JobName == job {
+			if !cutoff.IsZero() && run.StartedAt.Before(cutoff) {
+				continue
+			}
+			if newer++; keep > 0 && newer > keep {
+				continue
+			}
+		}
+		kept = append(kept, run)
+	}
+	// kept was filled newest first
+	for i, j := 0, len(kept)-1; i < j; i, j = i+1, j-1 {
+		kept[i], kept[j] = kept[j], kept[i]
+	}
+	pruned := int64(len(l.repo.runs) - len(kept))
+	l.repo.runs = kept
+	return pruned, nil
+}
+
+func (l *memoryJobRunLog) Counts() (map[string]int, error) {
+	defer l.repo.lock()()
+
+	counts := map[string]int{}
+	for _, run := range l.repo.runs {
+		counts[run.JobName]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryPoolRepository keeps the pools next to the servers of the repository,
+// so they share its transactions.
+type memoryPoolRepository struct {
+	repo *memoryServerRepository
+}
+
+// live returns the pool with the given id if it exists and isn't deleted.
+func (r *memoryPoolRepository) live(id uint) (model.Pool, bool) {
+	pool, ok := r.repo.data.pools[id]
+	if !ok || pool.DeletedAt.Valid {
+		return model.Pool{}, false
+	}
+	return pool, true
+}
+
+func (r *memoryPoolRepository) nameTaken(pool model.Pool) bool {
+	for id, existing := range r.repo.data.pools {
+		if id != pool.ID && !existing.DeletedAt.Valid && existing.Name == pool.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryPoolRepository) Get(id uint) (*model.Pool, error) {
+	defer r.repo.lock()()
+
+	pool, ok := r.live(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &pool, nil
+}
+
+// list returns the live pools sorted by name, the lock held.
+func (r *memoryPoolRepository) list() []model.Pool {
+	pools := []model.Pool{}
+	for id := range r.repo.data.pools {
+		if pool, ok := r.live(id); ok {
+			pools = append(pools, pool)
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
+
+func (r *memoryPoolRepository) List() ([]model.Pool, error) {
+	defer r.repo.lock()()
+
+	return r.list(), nil
+}
+
+func (r *memoryPoolRepository) Create(pool *model.Pool) error {
+	defer r.repo.lock()()
+
+	pool.ID = 0
+	if r.nameTaken(*pool) {
+		return ErrPoolExists
+	}
+	data := r.repo.data
+	now := time.Now()
+	pool.ID = data.nextPoolID
+	pool.CreatedAt = now
+	pool.UpdatedAt = now
+	data.nextPoolID++
+	data.pools[pool.ID] = *pool
+	return nil
+}
+
+func (r *memoryPoolRepository) Update(pool *model.Pool) error {
+	defer r.repo.lock()()
+
+	stored, ok := r.live(pool.ID)
+	if !ok {
+		return nil
+	}
+	if r.nameTaken(*pool) {
+		return ErrPoolExists
+	}
+	stored.Name = pool.Name
+	stored.Purpose = pool.Purpose
+	stored.Owner = pool.Owner
+	stored.MinActiveIPs = pool.MinActiveIPs
+	stored.UpdatedAt = time.Now()
+	r.repo.data.pools[stored.ID] = stored
+	return nil
+}
+
+func (r *memoryPoolRepository) Delete(id uint) error {
+	defer r.repo.lock()()
+
+	stored, ok := r.live(id)
+	if !ok {
+		return nil
+	}
+	count := 0
+	for _, server := range r.repo.data.servers {
+		if !server.DeletedAt.Valid && server.PoolID != nil && *server.PoolID == id {
+			count++
+		}
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %d servers belong to pool %d", ErrPoolInUse, count, id)
+	}
+	for serverID, server := range r.repo.data.servers {
+		if server.PoolID != nil && *server.PoolID == id {
+			server.PoolID = nil
+			r.repo.data.servers[serverID] = server
+		}
+	}
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.repo.data.pools[id] = stored
+	return nil
+}
+
+func (r *memoryPoolRepository) Report() ([]PoolStats, error) {
+	defer r.repo.lock()()
+
+	servers := []model.Server{}
+	for _, id := range r.repo.sortedIDs() {
+		if server, ok := r.repo.live(id); ok {
+			servers = append(servers, server)
+		}
+	}
+	return poolReport(r.list(), servers), nil
+}
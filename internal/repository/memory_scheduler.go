@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sync"
+)
+
+// memorySchedulerRepository keeps the jobs, their runs and their locks in
+// memory, behind a single lock.
+type memorySchedulerRepository struct {
+	mu        sync.Mutex
+	jobs      map[string]model.SchedulerJob
+	runs      []model.JobRun
+	nextRunID uint
+	locks     map[string]model.JobLock
+}
+
+// NewMemorySchedulerRepository returns a SchedulerRepository keeping
+// everything in memory. It behaves like the GORM repository and is meant for
+// tests and local runs without a database.
+func NewMemorySchedulerRepository() SchedulerRepository {
+	return &memorySchedulerRepository{jobs: map[string]model.SchedulerJob{}, locks: map[string]model.JobLock{}}
+}
+
+// lock takes the repository lock, the returned func releases it.
+func (r *memorySchedulerRepository) lock() func() {
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+func (r *memorySchedulerRepository) Jobs() JobStore {
+	return &memoryJobStore{repo: r}
+}
+
+func (r *memorySchedulerRepository) JobRuns() JobRunLog {
+	return &memoryJobRunLog{repo: r}
+}
+
+func (r *memorySchedulerRepository) JobLocks() JobLockTable {
+	return &memoryJobLockTable{repo: r}
+}
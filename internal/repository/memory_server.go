@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// memoryData is the state shared by a memory repository and its transactions.
+type memoryData struct {
+	servers    map[uint]model.Server
+	nextID     uint
+	audit      []model.AuditEntry
+	pools      map[uint]model.Pool
+	nextPoolID uint
+	// utilisation is appended to or replaced, never modified in place
+	utilisation  []model.UtilisationSample
+	nextSampleID uint
+}
+
+func (d *memoryData) clone() *memoryData {
+	servers := make(map[uint]model.Server, len(d.servers))
+	for id, server := range d.servers {
+		servers[id] = server
+	}
+	// entries are never modified, sharing the backing array is fine as long
+	// as the clone can't append over entries added after it was taken
+	audit := d.audit[:len(d.audit):len(d.audit)]
+	pools := make(map[uint]model.Pool, len(d.pools))
+	for id, pool := range d.pools {
+		pools[id] = pool
+	}
+	utilisation := d.utilisation[:len(d.utilisation):len(d.utilisation)]
+	return &memoryData{
+		servers: servers, nextID: d.nextID, audit: audit, pools: pools, nextPoolID: d.nextPoolID,
+		utilisation: utilisation, nextSampleID: d.nextSampleID,
+	}
+}
+
+type memoryServerRepository struct {
+	mu   *sync.Mutex
+	data *memoryData
+	key  UniqueKey
+	inTx bool
+}
+
+// NewMemoryServerRepository returns a ServerRepository keeping everything in
+// memory, enforcing key. It behaves like the GORM repository and is meant for
+// tests and local runs without a database.
+func NewMemoryServerRepository(key UniqueKey) ServerRepository {
+	return &memoryServerRepository{
+		mu:  &sync.Mutex{},
+		key: key,
+		data: &memoryData{
+			servers: map[uint]model.Server{}, nextID: 1, pools: map[uint]model.Pool{}, nextPoolID: 1,
+		},
+	}
+}
+
+// NewMemoryServerRepositoryFrom returns a memory ServerRepository enforcing
+// key and holding servers as they are, IDs, timestamps and deletions included.
+func NewMemoryServerRepositoryFrom(key UniqueKey, servers []model.Server) ServerRepository {
+	repo := NewMemoryServerRepository(key).(*memoryServerRepository)
+	for _, server := range servers {
+		repo.data.servers[server.ID] = server
+		if server.ID >= repo.data.nextID {
+			repo.data.nextID = server.ID + 1
+		}
+	}
+	return repo
+}
+
+// lock takes the repository lock unless it is already held by the
+// transaction r belongs to. The returned func releases it.
+func (r *memoryServerRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// live returns the server with the given id if it exists and isn't deleted.
+func (r *memoryServerRepository) live(id uint) (model.Server, bool) {
+	server, ok := r.data.servers[id]
+	if !ok || server.DeletedAt.Valid {
+		return model.Server{}, false
+	}
+	return server, true
+}
+
+// sortedIDs returns the ids of all stored servers in ascending order.
+func (r *memoryServerRepository) sortedIDs() []uint {
+	ids := make([]uint, 0, len(r.data.servers))
+	for id := range r.data.servers {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (r *memoryServerRepository) Get(id uint) (*model.Server, error) {
+	defer r.lock()()
+
+	server, ok := r.live(id)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &server, nil
+}
+
+// GetForUpdate needs no lock of its own, a transaction holds the repository
+// lock until it ends.
+func (r *memoryServerRepository) GetForUpdate(id uint) (*model.Server, error) {
+	return r.Get(id)
+}
+
+func (r *memoryServerRepository) GetDeletedForUpdate(id uint) (*model.Server, error) {
+	defer r.lock()()
+
+	server, ok := r.data.servers[id]
+	if !ok || !server.DeletedAt.Valid {
+		return nil, ErrNotFound
+	}
+	return &server, nil
+}
+
+func (r *memoryServerRepository) List(opts ListOptions) (*Page, error) {
+	sortField, err := opts.sortField()
+	if err != nil {
+		return nil, err
+	}
+	after, err := decodeCursor(opts.Cursor, sortField, opts.Desc)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.lock()()
+
+	servers := []model.Server{}
+	for _, id := range r.sortedIDs() {
+		if server := r.data.servers[id]; opts.Matches(server) {
+			servers = append(servers, server)
+		}
+	}
+	sort.SliceStable(servers, func(i, j int) bool {
+		a, b := servers[i], servers[j]
+		less := a.ID < b.ID
+		switch {
+		case sortField == "hostname" && a.Hostname != b.Hostname:
+			less = a.Hostname < b.Hostname
+		case sortField == "created_at" && !a.CreatedAt.Equal(b.CreatedAt):
+			less = a.CreatedAt.Before(b.CreatedAt)
+		}
+		if opts.Desc {
+			return !less
+		}
+		return less
+	})
+
+	page := &Page{Servers: []model.Server{}}
+	limit := opts.limit()
+	for _, server := range servers {
+		if !after.after(server) {
+			continue
+		}
+		if len(page.Servers) == limit {
+			page.NextCursor = newCursor(sortField, opts.Desc, page.Servers[limit-1])
+			break
+		}
+		page.Servers = append(page.Servers, server)
+	}
+	return page, nil
+}
+
+func (r *memoryServerRepository) Each(filter ServerFilter, fn func(server model.Server) error) error {
+	// copy the matching servers so fn runs without holding the lock
+	servers := []model.Server{}
+	func() {
+		defer r.lock()()
+		for _, id := range r.sortedIDs() {
+			if server := r.data.servers[id]; filter.Matches(server) {
+				servers = append(servers, server)
+			}
+		}
+	}()
+
+	for _, server := range servers {
+		if err := fn(server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// conflict returns the ConflictError for server, naming the live server other
+// than itself that holds its unique key, like the unique index would.
+func (r *memoryServerRepository) conflict(server model.Server) error {
+	for _, id := range r.sortedIDs() {
+		if existing, ok := r.live(id); ok && id != server.ID && r.key.conflicts(existing, server) {
+			return &ConflictError{ID: id, IP: server.IP, Hostname: server.Hostname, Key: r.key}
+		}
+	}
+	return nil
+}
+
+func (r *memoryServerRepository) Create(server *model.Server) error {
+	defer r.lock()()
+
+	server.ID = 0
+	if err := r.conflict(*server); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	server.ID = r.data.nextID
+	server.CreatedAt = now
+	server.UpdatedAt = now
+	server.Version = 1
+	server.Labels = server.Labels.Clone()
+	r.data.nextID++
+	r.data.servers[server.ID] = *server
+	return nil
+}
+
+func (r *memoryServerRepository) Update(server *model.Server) error {
+	defer r.lock()()
+
+	stored, ok := r.live(server.ID)
+	if !ok {
+		return nil
+	}
+	stored.IP = server.IP
+	stored.Hostname = server.Hostname
+	stored.Active = server.Active
+	stored.PoolID = server.PoolID
+	stored.Labels = server.Labels.Clone()
+	if err := r.conflict(stored); err != nil {
+		return err
+	}
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	r.data.servers[stored.ID] = stored
+	server.Version = stored.Version
+	return nil
+}
+
+func (r *memoryServerRepository) SetActive(server *model.Server, active bool) error {
+	defer r.lock()()
+
+	stored, ok := r.live(server.ID)
+	if !ok {
+		return nil
+	}
+	stored.Active = active
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	r.data.servers[stored.ID] = stored
+	server.Active = stored.Active
+	server.Version = stored.Version
+	return nil
+}
+
+func (r *memoryServerRepository) Delete(id uint) error {
+	defer r.lock()()
+
+	stored, ok := r.live(id)
+	if !ok {
+		return nil
+	}
+	stored.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	r.data.servers[id] = stored
+	return nil
+}
+
+func (r *memoryServerRepository) Restore(server *model.Server) error {
+	defer r.lock()()
+
+	stored, ok := r.data.servers[server.ID]
+	if !ok || !stored.DeletedAt.Valid {
+		return nil
+	}
+	if err := r.conflict(stored); err != nil {
+		return err
+	}
+	stored.DeletedAt = gorm.DeletedAt{}
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	r.data.servers[stored.ID] = stored
+	server.DeletedAt = stored.DeletedAt
+	server.Version = stored.Version
+	return nil
+}
+
+func (r *memoryServerRepository) Purge(id uint) error {
+	defer r.lock()()
+
+	delete(r.data.servers, id)
+	return nil
+}
+
+func (r *memoryServerRepository) PurgeDeleted(cutoff time.Time) (int64, error) {
+	defer r.lock()()
+
+	purged := int64(0)
+	for id, server := range r.data.servers {
+		if server.DeletedAt.Valid && server.DeletedAt.Time.Before(cutoff) {
+			delete(r.data.servers, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryServerRepository) HostnamesBelowThreshold(thresh int) ([]string, error) {
+	defer r.lock()()
+
+	active := map[string]int{}
+	for _, server := range r.data.servers {
+		if server.DeletedAt.Valid {
+			continue
+		}
+		if _, ok := active[server.Hostname]; !ok {
+			active[server.Hostname] = 0
+		}
+		if server.Active {
+			active[server.Hostname]++
+		}
+	}
+
+	hostnames := []string{}
+	for hostname, count := range active {
+		if count <= thresh {
+			hostnames = append(hostnames, hostname)
+		}
+	}
+	sort.Strings(hostnames)
+	return hostnames, nil
+}
+
+func (r *memoryServerRepository) HostnameReport(selector Selector, count ActiveCount) ([]HostnameStats, error) {
+	defer r.lock()()
+
+	servers := []model.Server{}
+	for _, id := range r.sortedIDs() {
+		if server, ok := r.live(id); ok && selector.Matches(server.Labels) {
+			servers = append(servers, server)
+		}
+	}
+	return hostnameReport(servers, count), nil
+}
+
+func (r *memoryServerRepository) ActiveIPs(selector Selector) ([]string, error) {
+	defer r.lock()()
+
+	ips := []string{}
+	for _, id := range r.sortedIDs() {
+		if server, ok := r.live(id); ok && server.Active && selector.Matches(server.Labels) {
+			ips = append(ips, server.IP)
+		}
+	}
+	return ips, nil
+}
+
+func (r *memoryServerRepository) Utilisation() UtilisationLog {
+	return &memoryUtilisationLog{repo: r}
+}
+
+func (r *memoryServerRepository) Pools() PoolRepository {
+	return &memoryPoolRepository{repo: r}
+}
+
+func (r *memoryServerRepository) Audit() AuditLog {
+	return &memoryAuditLog{repo: r}
+}
+
+func (r *memoryServerRepository) UniqueKey() UniqueKey {
+	return r.key
+}
+
+func (r *memoryServerRepository) Transaction(fn func(repo ServerRepository) error) error {
+	// a nested transaction only needs to restore its own changes, like a savepoint
+	if !r.inTx {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+
+	snapshot := r.data.clone()
+	committed := false
+	defer func() {
+		// roll back on error as well as on panic
+		if !committed {
+			*r.data = *snapshot
+		}
+	}()
+
+	tx := &memoryServerRepository{mu: r.mu, data: r.data, key: r.key, inTx: true}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
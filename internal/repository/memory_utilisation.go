@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"sort"
+	"time"
+)
+
+// memoryUtilisationLog keeps the samples next to the servers of the
+// repository, so they share its transactions.
+type memoryUtilisationLog struct {
+	repo *memoryServerRepository
+}
+
+func (l *memoryUtilisationLog) Record(samples []model.UtilisationSample) error {
+	defer l.repo.lock()()
+
+	data := l.repo.data
+	for _, sample := range samples {
+		data.nextSampleID++
+		sample.ID = data.nextSampleID
+		data.utilisation = append(data.utilisation, sample)
+	}
+	return nil
+}
+
+func (l *memoryUtilisationLog) Series(query UtilisationQuery) ([]UtilisationPoint, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	defer l.repo.lock()()
+
+	samples := []model.UtilisationSample{}
+	for _, sample := range l.repo.data.utilisation {
+		if sample.Hostname == query.Hostname && !sample.TakenAt.Before(query.From) && sample.TakenAt.Before(query.To) {
+			samples = append(samples, sample)
+		}
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].TakenAt.Before(samples[j].TakenAt) })
+	return downsample(samples, query), nil
+}
+
+func (l *memoryUtilisationLog) PurgeBefore(cutoff time.Time) (int64, error) {
+	defer l.repo.lock()()
+
+	data := l.repo.data
+	// a new slice, a transaction snapshot may still share the old one
+	kept := []model.UtilisationSample{}
+	for _, sample := range data.utilisation {
+		if !sample.TakenAt.Before(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	purged := int64(len(data.utilisation) - len(kept))
+	data.utilisation = kept
+	return purged, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"sort"
+)
+
+var (
+	// ErrPoolExists is returned when a live pool already has the name.
+	ErrPoolExists = errors.New("pool name already in use")
+	// ErrPoolInUse is returned when deleting a pool live servers belong to.
+	ErrPoolInUse = errors.New("pool still has servers")
+)
+
+// PoolRepository is the storage of the server pools.
+type PoolRepository interface {
+	// Get returns the live pool with the given id, or ErrNotFound.
+	Get(id uint) (*model.Pool, error)
+	// List returns every live pool, sorted by name.
+	List() ([]model.Pool, error)
+	// Create stores a new pool, or returns ErrPoolExists.
+	Create(pool *model.Pool) error
+	// Update writes every field of an existing pool, or returns ErrPoolExists.
+	Update(pool *model.Pool) error
+	// Delete soft-deletes the pool with the given id. It returns ErrPoolInUse
+	// while live servers belong to it, and takes the soft-deleted ones out of
+	// it so none is restored into a deleted pool.
+	Delete(id uint) error
+	// Report returns the IP counts of every live pool, sorted by name.
+	Report() ([]PoolStats, error)
+}
+
+// PoolStats counts the IPs of the live servers of a pool by state.
+type PoolStats struct {
+	Pool        model.Pool `json:"pool"`
+	Active      int        `json:"active"`
+	Inactive    int        `json:"inactive"`
+	Total       int        `json:"total"`
+	ActiveIPs   []string   `json:"active_ips"`
+	InactiveIPs []string   `json:"inactive_ips"`
+	// BelowMin flags a pool with fewer active IPs than its MinActiveIPs
+	BelowMin bool `json:"below_min"`
+}
+
+// poolReport counts the servers of every pool. Servers of other pools, or of
+// none, are left out.
+func poolReport(pools []model.Pool, servers []model.Server) []PoolStats {
+	byID := map[uint]*PoolStats{}
+	report := make([]PoolStats, len(pools))
+	for i, pool := range pools {
+		report[i] = PoolStats{Pool: pool, ActiveIPs: []string{}, InactiveIPs: []string{}}
+		byID[pool.ID] = &report[i]
+	}
+	for _, server := range servers {
+		if server.PoolID == nil {
+			continue
+		}
+		stats, ok := byID[*server.PoolID]
+		if !ok {
+			continue
+		}
+		if server.Active {
+			stats.Active++
+			stats.ActiveIPs = append(stats.ActiveIPs, server.IP)
+		} else {
+			stats.Inactive++
+			stats.InactiveIPs = append(stats.InactiveIPs, server.IP)
+		}
+		stats.Total++
+	}
+	for i := range report {
+		report[i].BelowMin = report[i].Active < report[i].Pool.MinActiveIPs
+	}
+	sort.SliceStable(report, func(i, j int) bool { return report[i].Pool.Name < report[j].Pool.Name })
+	return report
+}
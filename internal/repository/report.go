@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"fmt"
+	"sort"
+)
+
+// Comparison operators an ActiveCount compares with.
+const (
+	OpLT  = "lt"
+	OpLTE = "lte"
+	OpEQ  = "eq"
+	OpGTE = "gte"
+	OpGT  = "gt"
+)
+
+// Ops lists the comparison operators, in the order they are documented.
+var Ops = []string{OpLT, OpLTE, OpEQ, OpGTE, OpGT}
+
+// ActiveCount selects hostnames by how many active IPs they have: compared
+// with Thresh by Op, and within Min and Max when they are set.
+type ActiveCount struct {
+	// Op is one of Ops, lte when empty.
+	Op     string
+	Thresh int
+	Min    *int
+	Max    *int
+}
+
+// Validate checks the operator and the range.
+func (a ActiveCount) Validate() error {
+	switch a.Op {
+	case "", OpLT, OpLTE, OpEQ, OpGTE, OpGT:
+	default:
+		return fmt.Errorf("unknown comparison operator %q", a.Op)
+	}
+	if a.Min != nil && a.Max != nil && *a.Min > *a.Max {
+		return fmt.Errorf("min %d is greater than max %d", *a.Min, *a.Max)
+	}
+	return nil
+}
+
+// Matches reports whether a hostname with active active IPs is selected.
+func (a ActiveCount) Matches(active int) bool {
+	if a.Min != nil && active < *a.Min {
+		return false
+	}
+	if a.Max != nil && active > *a.Max {
+		return false
+	}
+	switch a.Op {
+	case OpLT:
+		return active < a.Thresh
+	case OpEQ:
+		return active == a.Thresh
+	case OpGTE:
+		return active >= a.Thresh
+	case OpGT:
+		return active > a.Thresh
+	}
+	return active <= a.Thresh
+}
+
+// HostnameStats counts the IPs of a hostname by state.
+type HostnameStats struct {
+	Hostname    string   `json:"hostname"`
+	Active      int      `json:"active"`
+	Inactive    int      `json:"inactive"`
+	Total       int      `json:"total"`
+	ActiveIPs   []string `json:"active_ips"`
+	InactiveIPs []string `json:"inactive_ips"`
+}
+
+// hostnameReport groups servers by hostname and keeps the hostnames selected
+// by count, sorted by hostname. IPs keep the order of servers.
+func hostnameReport(servers []model.Server, count ActiveCount) []HostnameStats {
+	byHostname := map[string]*HostnameStats{}
+	for _, server := range servers {
+		stats, ok := byHostname[server.Hostname]
+		if !ok {
+			stats = &HostnameStats{Hostname: server.Hostname, ActiveIPs: []string{}, InactiveIPs: []string{}}
+			byHostname[server.Hostname] = stats
+		}
+		if server.Active {
+			stats.Active++
+			stats.ActiveIPs = append(stats.ActiveIPs, server.IP)
+		} else {
+			stats.Inactive++
+			stats.InactiveIPs = append(stats.InactiveIPs, server.IP)
+		}
+		stats.Total++
+	}
+
+	report := []HostnameStats{}
+	for _, stats := range byHostname {
+		if count.Matches(stats.Active) {
+			report = append(report, *stats)
+		}
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Hostname < report[j].Hostname })
+	return report
+}
@@ -0,0 +1,13 @@
+package repository
+
+// SchedulerRepository is the storage of the cron, apart from the servers its
+// jobs work on: the jobs, the history of their runs and the locks its replicas
+// take on them.
+type SchedulerRepository interface {
+	// Jobs returns the jobs of the cron.
+	Jobs() JobStore
+	// JobRuns returns the history of the runs of the jobs.
+	JobRuns() JobRunLog
+	// JobLocks returns the locks the replicas of the cron take on the jobs.
+	JobLocks() JobLockTable
+}
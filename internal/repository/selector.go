@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Operators of a label selector requirement.
+const (
+	SelectorEquals    = "="
+	SelectorNotEquals = "!="
+	SelectorIn        = "in"
+	SelectorNotIn     = "notin"
+	SelectorExists    = "exists"
+	SelectorNotExists = "!"
+)
+
+// ErrInvalidSelector is returned for a label selector that can't be parsed.
+var ErrInvalidSelector = errors.New("invalid label selector")
+
+// Requirement is one comma separated term of a label selector.
+type Requirement struct {
+	Key string
+	Op  string
+	// Values holds the value of = and !=, and the set of in and notin.
+	Values []string
+}
+
+// Matches reports whether labels satisfy the requirement. Like Kubernetes,
+// != and notin match servers that don't have the key at all.
+func (r Requirement) Matches(labels model.Labels) bool {
+	value, ok := labels[r.Key]
+	switch r.Op {
+	case SelectorExists:
+		return ok
+	case SelectorNotExists:
+		return !ok
+	case SelectorEquals, SelectorIn:
+		return ok && contains(r.Values, value)
+	case SelectorNotEquals, SelectorNotIn:
+		return !ok || !contains(r.Values, value)
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Selector selects servers by their labels, every requirement must hold. The
+// zero Selector selects everything.
+type Selector []Requirement
+
+// Matches reports whether labels satisfy every requirement of s.
+func (s Selector) Matches(labels model.Labels) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseSelector parses a Kubernetes style label selector, comma separated
+// requirements of the forms key=value, key==value, key!=value,
+// key in (v1,v2), key notin (v1,v2), key and !key. An empty string parses to
+// the empty Selector.
+func ParseSelector(raw string) (Selector, error) {
+	terms, err := splitTerms(raw)
+	if err != nil {
+		return nil, err
+	}
+	selector := Selector{}
+	for _, term := range terms {
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidSelector, term, err)
+		}
+		selector = append(selector, r)
+	}
+	return selector, nil
+}
+
+// splitTerms splits raw at the commas that aren't within parentheses.
+func splitTerms(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	terms := []string{}
+	depth, start := 0, 0
+	for i, c := range raw {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, strings.TrimSpace(raw[start:i]))
+				start = i + 1
+			}
+		}
+		if depth < 0 || depth > 1 {
+			return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidSelector)
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidSelector)
+	}
+	return append(terms, strings.TrimSpace(raw[start:])), nil
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	r := Requirement{}
+	switch {
+	case term == "":
+		return r, errors.New("empty requirement")
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		r.Key, r.Op = strings.TrimSpace(term[1:]), SelectorNotExists
+	case strings.Contains(term, "!="):
+		parts := strings.SplitN(term, "!=", 2)
+		r.Key, r.Op, r.Values = strings.TrimSpace(parts[0]), SelectorNotEquals, []string{strings.TrimSpace(parts[1])}
+	case strings.Contains(term, "="):
+		parts := strings.SplitN(term, "=", 2)
+		value := strings.TrimPrefix(parts[1], "=")
+		r.Key, r.Op, r.Values = strings.TrimSpace(parts[0]), SelectorEquals, []string{strings.TrimSpace(value)}
+	case strings.Contains(term, "("):
+		fields := strings.Fields(term[:strings.IndexByte(term, '(')])
+		if len(fields) != 2 || (fields[1] != SelectorIn && fields[1] != SelectorNotIn) {
+			return r, errors.New("expected key in (...) or key notin (...)")
+		}
+		set := strings.TrimSpace(term[strings.IndexByte(term, '('):])
+		if !strings.HasSuffix(set, ")") {
+			return r, errors.New("expected the set of values to end with ')'")
+		}
+		r.Key, r.Op = fields[0], fields[1]
+		for _, value := range strings.Split(set[1:len(set)-1], ",") {
+			r.Values = append(r.Values, strings.TrimSpace(value))
+		}
+	default:
+		r.Key, r.Op = term, SelectorExists
+	}
+
+	if problem := model.LabelKeyProblem(r.Key); problem != "" {
+		return r, errors.New(problem)
+	}
+	for _, value := range r.Values {
+		if problem := model.LabelValueProblem(value); problem != "" {
+			return r, errors.New(problem)
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when the requested server doesn't exist or has been deleted.
+var ErrNotFound = errors.New("record not found")
+
+// ServerRepository is the storage used by the server handlers.
+type ServerRepository interface {
+	// Get returns the server with the given id.
+	Get(id uint) (*model.Server, error)
+	// GetForUpdate is Get locking the server until the end of the transaction
+	// the repository is bound to, so it can be read, checked and written
+	// without another transaction writing it in between.
+	GetForUpdate(id uint) (*model.Server, error)
+	// GetDeletedForUpdate is GetForUpdate for a server that has been
+	// soft-deleted, it returns ErrNotFound for a live one.
+	GetDeletedForUpdate(id uint) (*model.Server, error)
+	// List returns a page of the servers that haven't been deleted, or of
+	// every server with ServerFilter.IncludeDeleted.
+	List(opts ListOptions) (*Page, error)
+	// Each calls fn for every server matching filter, in id order, without
+	// loading them all at once nor keeping a query open while fn runs, so fn
+	// may use the repository. It stops at the first error fn returns.
+	Each(filter ServerFilter, fn func(server model.Server) error) error
+	// Create stores a new server and fills in its ID and timestamps. It
+	// returns a *ConflictError if a live server already holds its UniqueKey.
+	Create(server *model.Server) error
+	// Update writes the IP, Hostname, Active, PoolID and Labels fields of an existing server,
+	// zero values included, and bumps its Version.
+	// It returns a *ConflictError if another live server holds the new key.
+	Update(server *model.Server) error
+	// SetActive enables or disables an existing server and bumps its Version,
+	// updating the Active and Version fields of server along.
+	SetActive(server *model.Server, active bool) error
+	// Delete soft-deletes the server with the given id: it is kept, with its
+	// DeletedAt set, until it is restored or purged.
+	Delete(id uint) error
+	// Restore undeletes a soft-deleted server and bumps its Version. It
+	// returns a *ConflictError if a live server took its key in the meantime.
+	Restore(server *model.Server) error
+	// Purge removes the server with the given id for good, deleted or not.
+	Purge(id uint) error
+	// PurgeDeleted removes for good the servers soft-deleted before cutoff
+	// and returns how many there were.
+	PurgeDeleted(cutoff time.Time) (int64, error)
+	// HostnamesBelowThreshold returns the hostnames having at most thresh active IPs.
+	HostnamesBelowThreshold(thresh int) ([]string, error)
+	// HostnameReport returns the IP counts of the hostnames of the live servers
+	// matching selector, the hostnames selected by count, sorted by hostname.
+	HostnameReport(selector Selector, count ActiveCount) ([]HostnameStats, error)
+	// ActiveIPs returns the IP of every active server matching selector.
+	ActiveIPs(selector Selector) ([]string, error)
+	// Utilisation returns the history of the active IP counts of the
+	// hostnames, bound to the same transaction as the repository.
+	Utilisation() UtilisationLog
+	// Pools returns the pool storage, bound to the same transaction as the
+	// repository.
+	Pools() PoolRepository
+	// Audit returns the audit log, bound to the same transaction as the
+	// repository so a change and its entry are committed or rolled back together.
+	Audit() AuditLog
+	// UniqueKey returns the key no two live servers may share.
+	UniqueKey() UniqueKey
+	// Transaction runs fn against a repository bound to a single transaction.
+	// The transaction is committed if fn returns nil and rolled back otherwise.
+	// Called on a repository already bound to a transaction it behaves like a
+	// savepoint, only rolling back the changes made by fn.
+	Transaction(fn func(repo ServerRepository) error) error
+}
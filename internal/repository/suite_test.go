@@ -0,0 +1,1083 @@
+package repository_test
+
+import (
+	"GO_APP/config"
+	"GO_APP/internal/database"
+	"GO_APP/internal/migrations"
+	"GO_APP/internal/model"
+	"GO_APP/internal/repository"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// EnvPostgresDSN names the environment variable holding the DSN of a scratch
+// postgres database. The postgres backend is skipped when it isn't set. Every
+// test truncates the tables it uses, so never point it at real data.
+const EnvPostgresDSN = "MTA_TEST_POSTGRES_DSN"
+
+var gormConfig = &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+
+// backend opens a fresh, empty repository for a single test.
+type backend struct {
+	name string
+	open func(t *testing.T) repository.ServerRepository
+}
+
+// openSqlite returns a fresh in-memory sqlite database, its unique index
+// built on key.
+func openSqlite(t *testing.T, key repository.UniqueKey) *gorm.DB {
+	db, err := database.Open(&config.DBConfig{Dialect: "sqlite", Path: database.MemoryPath}, gormConfig)
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return prepare(t, db, key)
+}
+
+// openPostgres returns the scratch postgres database emptied, its unique
+// index built on key. The test is skipped when EnvPostgresDSN isn't set.
+func openPostgres(t *testing.T, key repository.UniqueKey) *gorm.DB {
+	dsn := os.Getenv(EnvPostgresDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", EnvPostgresDSN)
+	}
+	db, err := gorm.Open(postgres.Open(dsn), gormConfig)
+	if err != nil {
+		t.Fatalf("Error opening postgres: %v", err)
+	}
+	db = prepare(t, db, key)
+	if err := db.Exec("TRUNCATE servers, pools, hostname_utilisation, scheduler_jobs, job_runs, job_locks RESTART IDENTITY").Error; err != nil {
+		t.Fatalf("Error truncating the tables: %v", err)
+	}
+	return db
+}
+
+// backends returns the backends enforcing key.
+func backends(key repository.UniqueKey) []backend {
+	return []backend{
+		{
+			name: "memory",
+			open: func(t *testing.T) repository.ServerRepository {
+				return repository.NewMemoryServerRepository(key)
+			},
+		},
+		{
+			name: "sqlite",
+			open: func(t *testing.T) repository.ServerRepository {
+				return repository.NewGormServerRepository(openSqlite(t, key), key)
+			},
+		},
+		{
+			name: "postgres",
+			open: func(t *testing.T) repository.ServerRepository {
+				return repository.NewGormServerRepository(openPostgres(t, key), key)
+			},
+		},
+	}
+}
+
+// prepare brings the schema of db up to date, its unique index built on key.
+func prepare(t *testing.T, db *gorm.DB, key repository.UniqueKey) *gorm.DB {
+	if _, err := migrations.Up(db, migrations.Placeholders{ServerUniqueColumns: key.Columns()}); err != nil {
+		t.Fatalf("Error migrating: %v", err)
+	}
+	return db
+}
+
+// forEachBackend runs fn once per backend, so every implementation is held to
+// the same expectations.
+func forEachBackend(t *testing.T, fn func(t *testing.T, repo repository.ServerRepository)) {
+	for _, b := range backends(repository.UniqueIP) {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, b.open(t))
+		})
+	}
+}
+
+// forEachSchedulerBackend is forEachBackend for the scheduler repositories.
+func forEachSchedulerBackend(t *testing.T, fn func(t *testing.T, repo repository.SchedulerRepository)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) repository.SchedulerRepository
+	}{
+		{"memory", func(t *testing.T) repository.SchedulerRepository {
+			return repository.NewMemorySchedulerRepository()
+		}},
+		{"sqlite", func(t *testing.T) repository.SchedulerRepository {
+			return repository.NewGormSchedulerRepository(openSqlite(t, repository.UniqueIP))
+		}},
+		{"postgres", func(t *testing.T) repository.SchedulerRepository {
+			return repository.NewGormSchedulerRepository(openPostgres(t, repository.UniqueIP))
+		}},
+	}
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			fn(t, b.open(t))
+		})
+	}
+}
+
+// seed stores servers and returns them with their IDs filled in.
+func seed(t *testing.T, repo repository.ServerRepository, servers ...model.Server) []model.Server {
+	for i := range servers {
+		if err := repo.Create(&servers[i]); err != nil {
+			t.Fatalf("Error creating server %+v: %v", servers[i], err)
+		}
+	}
+	return servers
+}
+
+var fixture = []model.Server{
+	{IP: "127.0.0.1", Hostname: "mta-prod-1", Active: true},
+	{IP: "127.0.0.2", Hostname: "mta-prod-1", Active: false},
+	{IP: "127.0.0.3", Hostname: "mta-prod-2", Active: true},
+	{IP: "127.0.0.4", Hostname: "mta-prod-2", Active: true},
+	{IP: "127.0.0.5", Hostname: "mta-prod-2", Active: false},
+	{IP: "127.0.0.6", Hostname: "mta-prod-3", Active: false},
+}
+
+func fixtureCopy() []model.Server {
+	return append([]model.Server{}, fixture...)
+}
+
+func TestServerRepositoryCRUD(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+		assert.NotZero(t, servers[0].ID)
+		assert.NotEqual(t, servers[0].ID, servers[1].ID)
+
+		got, err := repo.Get(servers[2].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "127.0.0.3", got.IP)
+		assert.Equal(t, "mta-prod-2", got.Hostname)
+		assert.True(t, got.Active)
+
+		got.Hostname = "mta-prod-9"
+		assert.NoError(t, repo.Update(got))
+		got, err = repo.Get(servers[2].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "mta-prod-9", got.Hostname)
+
+		// zero values are written as well
+		updatedAt := got.UpdatedAt
+		got.Active = false
+		assert.NoError(t, repo.Update(got))
+		got, err = repo.Get(servers[2].ID)
+		assert.NoError(t, err)
+		assert.False(t, got.Active)
+		assert.False(t, got.UpdatedAt.Before(updatedAt))
+
+		got.Active = true
+		assert.NoError(t, repo.Update(got))
+		assert.Equal(t, uint(4), got.Version)
+
+		assert.NoError(t, repo.SetActive(got, false))
+		assert.False(t, got.Active)
+		assert.Equal(t, uint(5), got.Version)
+		got, err = repo.Get(servers[2].ID)
+		assert.NoError(t, err)
+		assert.False(t, got.Active)
+		assert.Equal(t, uint(5), got.Version)
+
+		assert.NoError(t, repo.Delete(servers[0].ID))
+		_, err = repo.Get(servers[0].ID)
+		assert.True(t, errors.Is(err, repository.ErrNotFound), "expected ErrNotFound, got %v", err)
+
+		page, err := repo.List(repository.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Servers, len(servers)-1)
+	})
+}
+
+// ips returns the IP of each server, in order.
+func ips(servers []model.Server) []string {
+	ips := []string{}
+	for _, server := range servers {
+		ips = append(ips, server.IP)
+	}
+	return ips
+}
+
+func TestListFilters(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		seed(t, repo, fixtureCopy()...)
+		seed(t, repo,
+			model.Server{IP: "2001:db8::1", Hostname: "MTA-prod-1", Active: true},
+			model.Server{IP: "10.1.2.3", Hostname: "mta-dev-1", Active: true},
+		)
+		active, inactive := true, false
+		_, loopback, _ := net.ParseCIDR("127.0.0.0/30")
+		_, v6, _ := net.ParseCIDR("2001:db8::/32")
+
+		tests := []struct {
+			name   string
+			filter repository.ServerFilter
+			want   []string
+		}{
+			{name: "active", filter: repository.ServerFilter{Active: &active}, want: []string{"127.0.0.1", "127.0.0.3", "127.0.0.4", "2001:db8::1", "10.1.2.3"}},
+			{name: "inactive", filter: repository.ServerFilter{Active: &inactive}, want: []string{"127.0.0.2", "127.0.0.5", "127.0.0.6"}},
+			{name: "hostname", filter: repository.ServerFilter{Hostname: "mta-prod-1"}, want: []string{"127.0.0.1", "127.0.0.2"}},
+			{name: "case sensitive prefix", filter: repository.ServerFilter{HostnamePrefix: "mta-prod"}, want: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5", "127.0.0.6"}},
+			{name: "ipv4 cidr", filter: repository.ServerFilter{CIDR: loopback}, want: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}},
+			{name: "ipv6 cidr", filter: repository.ServerFilter{CIDR: v6}, want: []string{"2001:db8::1"}},
+			{name: "combined", filter: repository.ServerFilter{Active: &active, HostnamePrefix: "mta-", CIDR: loopback}, want: []string{"127.0.0.1", "127.0.0.3"}},
+		}
+		for _, tt := range tests {
+			page, err := repo.List(repository.ListOptions{ServerFilter: tt.filter})
+			assert.NoError(t, err, tt.name)
+			assert.Equal(t, tt.want, ips(page.Servers), tt.name)
+		}
+	})
+}
+
+func TestListPagination(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		seed(t, repo, fixtureCopy()...)
+
+		tests := []struct {
+			sort string
+			desc bool
+			want []string
+		}{
+			{sort: "id", want: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5", "127.0.0.6"}},
+			{sort: "id", desc: true, want: []string{"127.0.0.6", "127.0.0.5", "127.0.0.4", "127.0.0.3", "127.0.0.2", "127.0.0.1"}},
+			{sort: "hostname", desc: true, want: []string{"127.0.0.6", "127.0.0.5", "127.0.0.4", "127.0.0.3", "127.0.0.2", "127.0.0.1"}},
+			{sort: "hostname", want: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5", "127.0.0.6"}},
+			{sort: "created_at", want: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5", "127.0.0.6"}},
+		}
+		for _, tt := range tests {
+			got := []string{}
+			opts := repository.ListOptions{Sort: tt.sort, Desc: tt.desc, Limit: 4}
+			for pages := 0; ; pages++ {
+				page, err := repo.List(opts)
+				if !assert.NoError(t, err) || pages > len(fixture) {
+					break
+				}
+				got = append(got, ips(page.Servers)...)
+				if page.NextCursor == "" {
+					break
+				}
+				opts.Cursor = page.NextCursor
+			}
+			assert.Equal(t, tt.want, got, "sort %s desc %v", tt.sort, tt.desc)
+		}
+
+		page, err := repo.List(repository.ListOptions{Sort: "id", Limit: 2})
+		assert.NoError(t, err)
+		_, err = repo.List(repository.ListOptions{Sort: "hostname", Limit: 2, Cursor: page.NextCursor})
+		assert.True(t, errors.Is(err, repository.ErrInvalidCursor), "expected ErrInvalidCursor, got %v", err)
+		_, err = repo.List(repository.ListOptions{Sort: "ip"})
+		assert.True(t, errors.Is(err, repository.ErrInvalidSort), "expected ErrInvalidSort, got %v", err)
+	})
+}
+
+func TestHostnamesBelowThreshold(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+
+		tests := []struct {
+			thresh int
+			want   []string
+		}{
+			{thresh: 0, want: []string{"mta-prod-3"}},
+			{thresh: 1, want: []string{"mta-prod-1", "mta-prod-3"}},
+			{thresh: 2, want: []string{"mta-prod-1", "mta-prod-2", "mta-prod-3"}},
+		}
+		for _, tt := range tests {
+			got, err := repo.HostnamesBelowThreshold(tt.thresh)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got, "thresh %d", tt.thresh)
+		}
+
+		// deleted servers no longer count towards their hostname
+		assert.NoError(t, repo.Delete(servers[5].ID))
+		got, err := repo.HostnamesBelowThreshold(0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, got)
+	})
+}
+
+func TestActiveIPs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+		assert.NoError(t, repo.Delete(servers[2].ID))
+
+		got, err := repo.ActiveIPs(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.4"}, got)
+	})
+}
+
+func TestTransactionRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()[:1]...)
+
+		errBoom := errors.New("boom")
+		err := repo.Transaction(func(tx repository.ServerRepository) error {
+			if err := tx.SetActive(&servers[0], false); err != nil {
+				return err
+			}
+			extra := model.Server{IP: "127.0.0.9", Hostname: "mta-prod-9"}
+			if err := tx.Create(&extra); err != nil {
+				return err
+			}
+			return errBoom
+		})
+		assert.Equal(t, errBoom, err)
+
+		got, err := repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.True(t, got.Active)
+		page, err := repo.List(repository.ListOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, page.Servers, 1)
+	})
+}
+
+func TestNestedTransactionRollback(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		errBoom := errors.New("boom")
+		err := repo.Transaction(func(tx repository.ServerRepository) error {
+			kept := model.Server{IP: "127.0.0.1", Hostname: "mta-prod-1"}
+			if err := tx.Create(&kept); err != nil {
+				return err
+			}
+			// only the inner transaction is rolled back
+			err := tx.Transaction(func(inner repository.ServerRepository) error {
+				dropped := model.Server{IP: "127.0.0.2", Hostname: "mta-prod-2"}
+				if err := inner.Create(&dropped); err != nil {
+					return err
+				}
+				return errBoom
+			})
+			assert.Equal(t, errBoom, err)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		page, err := repo.List(repository.ListOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1"}, ips(page.Servers))
+
+		page, err = repo.List(repository.ListOptions{ServerFilter: repository.ServerFilter{IP: "127.0.0.2"}})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Servers)
+	})
+}
+
+func TestEach(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+		assert.NoError(t, repo.Delete(servers[2].ID))
+
+		collect := func(filter repository.ServerFilter) []string {
+			got := []model.Server{}
+			err := repo.Each(filter, func(server model.Server) error {
+				got = append(got, server)
+				return nil
+			})
+			assert.NoError(t, err)
+			return ips(got)
+		}
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.4", "127.0.0.5", "127.0.0.6"}, collect(repository.ServerFilter{}))
+		active := true
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.4"}, collect(repository.ServerFilter{Active: &active}))
+		assert.Empty(t, collect(repository.ServerFilter{CreatedUntil: time.Now().Add(-time.Hour)}))
+		assert.Len(t, collect(repository.ServerFilter{IncludeDeleted: true, CreatedUntil: time.Now()}), 6)
+
+		// fn can query the repository, nothing is left open in between
+		err := repo.Each(repository.ServerFilter{}, func(server model.Server) error {
+			_, err := repo.Get(server.ID)
+			return err
+		})
+		assert.NoError(t, err)
+
+		errStop := errors.New("stop")
+		calls := 0
+		err = repo.Each(repository.ServerFilter{}, func(server model.Server) error {
+			calls++
+			return errStop
+		})
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestUniqueIP(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()[:2]...)
+
+		duplicate := model.Server{IP: "127.0.0.1", Hostname: "mta-prod-9"}
+		err := repo.Create(&duplicate)
+		var conflict *repository.ConflictError
+		if assert.True(t, errors.As(err, &conflict), "expected ConflictError, got %v", err) {
+			assert.Equal(t, servers[0].ID, conflict.ID)
+		}
+
+		// a conflict inside a transaction leaves it usable
+		err = repo.Transaction(func(tx repository.ServerRepository) error {
+			if err := tx.Create(&model.Server{IP: "127.0.0.2", Hostname: "mta-prod-9"}); !errors.As(err, &conflict) {
+				t.Errorf("expected ConflictError, got %v", err)
+			}
+			return tx.Create(&model.Server{IP: "127.0.0.3", Hostname: "mta-prod-9"})
+		})
+		assert.NoError(t, err)
+
+		moved := servers[1]
+		moved.IP = "127.0.0.1"
+		err = repo.Update(&moved)
+		if assert.True(t, errors.As(err, &conflict), "expected ConflictError, got %v", err) {
+			assert.Equal(t, servers[0].ID, conflict.ID)
+		}
+		// updating a server with its own key is fine
+		assert.NoError(t, repo.Update(&servers[0]))
+
+		// deleted servers give their IP back
+		assert.NoError(t, repo.Delete(servers[0].ID))
+		assert.NoError(t, repo.Create(&duplicate))
+	})
+}
+
+func TestUniqueIPHostname(t *testing.T) {
+	for _, b := range backends(repository.UniqueIPHostname) {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			if b.name == "postgres" {
+				// its index was built by an earlier run with the default key
+				t.Skip("the scratch database keeps its unique key")
+			}
+			repo := b.open(t)
+			servers := seed(t, repo, model.Server{IP: "127.0.0.1", Hostname: "mta-prod-1"}, model.Server{IP: "127.0.0.1", Hostname: "mta-prod-2"})
+
+			err := repo.Create(&model.Server{IP: "127.0.0.1", Hostname: "mta-prod-2"})
+			var conflict *repository.ConflictError
+			if assert.True(t, errors.As(err, &conflict), "expected ConflictError, got %v", err) {
+				assert.Equal(t, servers[1].ID, conflict.ID)
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()[:1]...)
+		got, err := repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(1), got.Version)
+
+		err = repo.Transaction(func(tx repository.ServerRepository) error {
+			locked, err := tx.GetForUpdate(servers[0].ID)
+			if err != nil {
+				return err
+			}
+			locked.Hostname = "mta-prod-9"
+			if err := tx.Update(locked); err != nil {
+				return err
+			}
+			assert.Equal(t, uint(2), locked.Version)
+			if err := tx.SetActive(locked, false); err != nil {
+				return err
+			}
+			assert.Equal(t, uint(3), locked.Version)
+			return nil
+		})
+		assert.NoError(t, err)
+
+		got, err = repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(3), got.Version)
+
+		_, err = repo.GetForUpdate(99)
+		assert.True(t, errors.Is(err, repository.ErrNotFound), "expected ErrNotFound, got %v", err)
+	})
+}
+
+func TestSoftDeleteLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()[:3]...)
+		assert.NoError(t, repo.Delete(servers[0].ID))
+		assert.NoError(t, repo.Delete(servers[1].ID))
+
+		page, err := repo.List(repository.ListOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.3"}, ips(page.Servers))
+		page, err = repo.List(repository.ListOptions{ServerFilter: repository.ServerFilter{IncludeDeleted: true}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}, ips(page.Servers))
+		assert.True(t, page.Servers[0].DeletedAt.Valid)
+
+		_, err = repo.GetDeletedForUpdate(servers[2].ID)
+		assert.True(t, errors.Is(err, repository.ErrNotFound), "expected ErrNotFound, got %v", err)
+
+		// a live server took the IP of the deleted one
+		seed(t, repo, model.Server{IP: "127.0.0.2", Hostname: "mta-prod-4"})
+		deleted, err := repo.GetDeletedForUpdate(servers[1].ID)
+		assert.NoError(t, err)
+		var conflict *repository.ConflictError
+		assert.True(t, errors.As(repo.Restore(deleted), &conflict))
+
+		deleted, err = repo.GetDeletedForUpdate(servers[0].ID)
+		assert.NoError(t, err)
+		assert.NoError(t, repo.Restore(deleted))
+		assert.False(t, deleted.DeletedAt.Valid)
+		assert.Equal(t, uint(2), deleted.Version)
+		got, err := repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, uint(2), got.Version)
+
+		assert.NoError(t, repo.Purge(servers[0].ID))
+		_, err = repo.Get(servers[0].ID)
+		assert.True(t, errors.Is(err, repository.ErrNotFound), "expected ErrNotFound, got %v", err)
+
+		// only servers deleted before the cutoff are purged
+		purged, err := repo.PurgeDeleted(time.Now().Add(-time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), purged)
+		purged, err = repo.PurgeDeleted(time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+		page, err = repo.List(repository.ListOptions{ServerFilter: repository.ServerFilter{IncludeDeleted: true}})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.3", "127.0.0.2"}, ips(page.Servers))
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		server := seed(t, repo, fixtureCopy()[:1]...)[0]
+		entries := []model.AuditEntry{
+			{ServerID: server.ID, Action: model.AuditCreate, Actor: "kriti", RequestID: "req-1", After: &server},
+			{ServerID: server.ID, Action: model.AuditDisable, Actor: "ops", RequestID: "req-2", Before: &server, After: &server},
+			{ServerID: server.ID + 1, Action: model.AuditCreate, Actor: "kriti", RequestID: "req-3"},
+		}
+		for i := range entries {
+			assert.NoError(t, repo.Audit().Append(&entries[i]))
+			assert.NotZero(t, entries[i].ID)
+			assert.False(t, entries[i].CreatedAt.IsZero())
+		}
+
+		// an entry appended in a rolled back transaction is gone with it
+		err := repo.Transaction(func(tx repository.ServerRepository) error {
+			assert.NoError(t, tx.Audit().Append(&model.AuditEntry{ServerID: server.ID, Action: model.AuditDelete, Actor: "ops"}))
+			return errors.New("rollback")
+		})
+		assert.Error(t, err)
+
+		page, err := repo.Audit().List(repository.AuditFilter{ServerID: server.ID})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Entries, 2) {
+			assert.Equal(t, model.AuditCreate, page.Entries[0].Action)
+			assert.Nil(t, page.Entries[0].Before)
+			assert.Equal(t, server.IP, page.Entries[0].After.IP)
+			assert.Equal(t, "req-2", page.Entries[1].RequestID)
+			assert.Equal(t, server.Hostname, page.Entries[1].Before.Hostname)
+		}
+
+		page, err = repo.Audit().List(repository.AuditFilter{Actor: "kriti", Action: model.AuditCreate})
+		assert.NoError(t, err)
+		assert.Len(t, page.Entries, 2)
+		page, err = repo.Audit().List(repository.AuditFilter{Until: time.Now().Add(-time.Hour)})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Entries)
+
+		page, err = repo.Audit().List(repository.AuditFilter{Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.Entries, 2)
+		assert.NotEmpty(t, page.NextCursor)
+		page, err = repo.Audit().List(repository.AuditFilter{Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Equal(t, "req-3", page.Entries[0].RequestID)
+		assert.Empty(t, page.NextCursor)
+
+		_, err = repo.Audit().List(repository.AuditFilter{Cursor: "!"})
+		assert.True(t, errors.Is(err, repository.ErrInvalidCursor), "expected ErrInvalidCursor, got %v", err)
+	})
+}
+
+func TestAuditLogAsOf(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		at := func(hours int) time.Time { return t0.Add(time.Duration(hours) * time.Hour) }
+		entries := []model.AuditEntry{
+			{ServerID: 1, Action: model.AuditCreate, RequestID: "1-create", CreatedAt: at(1)},
+			{ServerID: 3, Action: model.AuditCreate, RequestID: "3-create", CreatedAt: at(1)},
+			{ServerID: 1, Action: model.AuditUpdate, RequestID: "1-update", CreatedAt: at(2)},
+			{ServerID: 2, Action: model.AuditCreate, RequestID: "2-create", CreatedAt: at(3)},
+			{ServerID: 1, Action: model.AuditDisable, RequestID: "1-disable", CreatedAt: at(4)},
+			{ServerID: 2, Action: model.AuditDelete, RequestID: "2-delete", CreatedAt: at(5)},
+		}
+		for i := range entries {
+			assert.NoError(t, repo.Audit().Append(&entries[i]))
+		}
+
+		requests := func(entries []model.AuditEntry) []string {
+			ids := []string{}
+			for _, entry := range entries {
+				ids = append(ids, entry.RequestID)
+			}
+			return ids
+		}
+		tests := []struct {
+			at   time.Time
+			want []string
+		}{
+			{t0, []string{"1-create", "2-create", "3-create"}},
+			{at(2), []string{"1-update", "2-create", "3-create"}},
+			{at(4), []string{"1-disable", "2-create", "3-create"}},
+			{at(9), []string{"1-disable", "2-delete", "3-create"}},
+		}
+		for _, tt := range tests {
+			got, err := repo.Audit().AsOf(tt.at)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, requests(got), tt.at.String())
+		}
+	})
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	db, err := database.Open(&config.DBConfig{Dialect: "sqlite", Path: database.MemoryPath}, gormConfig)
+	if err != nil {
+		t.Fatalf("Error opening sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	db = prepare(t, db, repository.UniqueIP)
+	entry := model.AuditEntry{ServerID: 1, Action: model.AuditCreate, Actor: "kriti"}
+	assert.NoError(t, repository.NewGormServerRepository(db, repository.UniqueIP).Audit().Append(&entry))
+
+	assert.Error(t, db.Model(&entry).Update("actor", "someone else").Error)
+	assert.Error(t, db.Delete(&entry).Error)
+}
+
+func TestHostnameReport(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+		assert.NoError(t, repo.Delete(servers[5].ID))
+
+		report, err := repo.HostnameReport(nil, repository.ActiveCount{Op: repository.OpGTE, Thresh: 0})
+		assert.NoError(t, err)
+		assert.Equal(t, []repository.HostnameStats{
+			{Hostname: "mta-prod-1", Active: 1, Inactive: 1, Total: 2, ActiveIPs: []string{"127.0.0.1"}, InactiveIPs: []string{"127.0.0.2"}},
+			{Hostname: "mta-prod-2", Active: 2, Inactive: 1, Total: 3, ActiveIPs: []string{"127.0.0.3", "127.0.0.4"}, InactiveIPs: []string{"127.0.0.5"}},
+		}, report)
+
+		one, two := 1, 2
+		tests := []struct {
+			count repository.ActiveCount
+			want  []string
+		}{
+			{repository.ActiveCount{Thresh: 1}, []string{"mta-prod-1"}},
+			{repository.ActiveCount{Op: repository.OpLT, Thresh: 1}, []string{}},
+			{repository.ActiveCount{Op: repository.OpEQ, Thresh: 2}, []string{"mta-prod-2"}},
+			{repository.ActiveCount{Op: repository.OpGT, Thresh: 1}, []string{"mta-prod-2"}},
+			{repository.ActiveCount{Op: repository.OpGTE, Min: &two}, []string{"mta-prod-2"}},
+			{repository.ActiveCount{Op: repository.OpGTE, Min: &one, Max: &one}, []string{"mta-prod-1"}},
+		}
+		for _, tt := range tests {
+			report, err := repo.HostnameReport(nil, tt.count)
+			assert.NoError(t, err)
+			hostnames := []string{}
+			for _, stats := range report {
+				hostnames = append(hostnames, stats.Hostname)
+			}
+			assert.Equal(t, tt.want, hostnames, "%+v", tt.count)
+		}
+	})
+}
+
+func TestPools(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		pools := repo.Pools()
+		transactional := model.Pool{Name: "transactional-eu", Purpose: model.PurposeTransactional, MinActiveIPs: 2}
+		marketing := model.Pool{Name: "marketing-eu", Purpose: model.PurposeMarketing, Owner: "growth", MinActiveIPs: 1}
+		assert.NoError(t, pools.Create(&transactional))
+		assert.NoError(t, pools.Create(&marketing))
+
+		duplicate := model.Pool{Name: "marketing-eu", Purpose: model.PurposeMarketing}
+		assert.ErrorIs(t, pools.Create(&duplicate), repository.ErrPoolExists)
+		marketing.Name = "transactional-eu"
+		assert.ErrorIs(t, pools.Update(&marketing), repository.ErrPoolExists)
+		marketing.Name = "marketing-eu"
+
+		list, err := pools.List()
+		assert.NoError(t, err)
+		if assert.Len(t, list, 2) {
+			assert.Equal(t, "marketing-eu", list[0].Name)
+			assert.Equal(t, "growth", list[0].Owner)
+		}
+
+		servers := fixtureCopy()
+		for i := range servers[:3] {
+			servers[i].PoolID = &transactional.ID
+		}
+		servers[3].PoolID = &marketing.ID
+		seed(t, repo, servers...)
+
+		report, err := pools.Report()
+		assert.NoError(t, err)
+		if assert.Len(t, report, 2) {
+			assert.Equal(t, "marketing-eu", report[0].Pool.Name)
+			assert.Equal(t, []string{"127.0.0.4"}, report[0].ActiveIPs)
+			assert.False(t, report[0].BelowMin)
+			assert.Equal(t, "transactional-eu", report[1].Pool.Name)
+			assert.Equal(t, []string{"127.0.0.1", "127.0.0.3"}, report[1].ActiveIPs)
+			assert.Equal(t, []string{"127.0.0.2"}, report[1].InactiveIPs)
+			assert.Equal(t, 3, report[1].Total)
+			assert.False(t, report[1].BelowMin)
+		}
+
+		page, err := repo.List(repository.ListOptions{ServerFilter: repository.ServerFilter{PoolID: &transactional.ID}})
+		assert.NoError(t, err)
+		assert.Len(t, page.Servers, 3)
+
+		// losing an active IP takes the pool below its minimum
+		servers[0].Active = false
+		assert.NoError(t, repo.Update(&servers[0]))
+		report, err = pools.Report()
+		assert.NoError(t, err)
+		assert.True(t, report[1].BelowMin)
+
+		assert.ErrorIs(t, pools.Delete(marketing.ID), repository.ErrPoolInUse)
+		assert.NoError(t, repo.Delete(servers[3].ID))
+		assert.NoError(t, pools.Delete(marketing.ID))
+		_, err = pools.Get(marketing.ID)
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		// its deleted servers left it, none can be restored into it
+		deleted, err := repo.GetDeletedForUpdate(servers[3].ID)
+		assert.NoError(t, err)
+		assert.Nil(t, deleted.PoolID)
+
+		// the name of a deleted pool can be used again
+		reused := model.Pool{Name: "marketing-eu", Purpose: model.PurposeMarketing}
+		assert.NoError(t, pools.Create(&reused))
+	})
+}
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want repository.Selector
+	}{
+		{"", repository.Selector{}},
+		{"region=eu", repository.Selector{{Key: "region", Op: repository.SelectorEquals, Values: []string{"eu"}}}},
+		{"region == eu , warmup!=true", repository.Selector{
+			{Key: "region", Op: repository.SelectorEquals, Values: []string{"eu"}},
+			{Key: "warmup", Op: repository.SelectorNotEquals, Values: []string{"true"}},
+		}},
+		{"provider in (ovh, hetzner),tier notin (bulk)", repository.Selector{
+			{Key: "provider", Op: repository.SelectorIn, Values: []string{"ovh", "hetzner"}},
+			{Key: "tier", Op: repository.SelectorNotIn, Values: []string{"bulk"}},
+		}},
+		{"example.com/warmup,!retired", repository.Selector{
+			{Key: "example.com/warmup", Op: repository.SelectorExists},
+			{Key: "retired", Op: repository.SelectorNotExists},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := repository.ParseSelector(tt.raw)
+		assert.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.want, got, tt.raw)
+	}
+
+	for _, raw := range []string{"region=eu,", "provider in (ovh", "provider in ovh", "region=e u", "-region=eu", "region='eu'", "a=(b)"} {
+		_, err := repository.ParseSelector(raw)
+		assert.ErrorIs(t, err, repository.ErrInvalidSelector, raw)
+	}
+}
+
+func TestLabelSelectors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := fixtureCopy()
+		servers[0].Labels = model.Labels{"region": "eu", "provider": "ovh"}
+		servers[1].Labels = model.Labels{"region": "eu", "warmup": "true"}
+		servers[2].Labels = model.Labels{"region": "us", "provider": "ovh"}
+		servers[3].Labels = model.Labels{"region": "eu", "provider": "hetzner", "warmup": "false"}
+		seed(t, repo, servers...)
+
+		got, err := repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.Labels{"region": "eu", "provider": "ovh"}, got.Labels)
+		got, err = repo.Get(servers[4].ID)
+		assert.NoError(t, err)
+		assert.Empty(t, got.Labels)
+
+		tests := []struct {
+			selector string
+			want     []string
+		}{
+			{"region=eu", []string{"127.0.0.1", "127.0.0.2", "127.0.0.4"}},
+			{"region=eu,warmup!=true", []string{"127.0.0.1", "127.0.0.4"}},
+			{"provider in (ovh,hetzner),region notin (us)", []string{"127.0.0.1", "127.0.0.4"}},
+			{"warmup", []string{"127.0.0.2", "127.0.0.4"}},
+			{"!region", []string{"127.0.0.5", "127.0.0.6"}},
+		}
+		for _, tt := range tests {
+			selector, err := repository.ParseSelector(tt.selector)
+			assert.NoError(t, err)
+			page, err := repo.List(repository.ListOptions{ServerFilter: repository.ServerFilter{Selector: selector}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ips(page.Servers), tt.selector)
+		}
+
+		selector, _ := repository.ParseSelector("region=eu")
+		active, err := repo.ActiveIPs(selector)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"127.0.0.1", "127.0.0.4"}, active)
+		report, err := repo.HostnameReport(selector, repository.ActiveCount{Op: repository.OpGTE})
+		assert.NoError(t, err)
+		if assert.Len(t, report, 2) {
+			assert.Equal(t, []string{"127.0.0.2"}, report[0].InactiveIPs)
+			assert.Equal(t, []string{"127.0.0.4"}, report[1].ActiveIPs)
+		}
+
+		// Update replaces the labels
+		servers[0].Labels = model.Labels{"region": "us"}
+		assert.NoError(t, repo.Update(&servers[0]))
+		got, err = repo.Get(servers[0].ID)
+		assert.NoError(t, err)
+		assert.Equal(t, model.Labels{"region": "us"}, got.Labels)
+	})
+}
+
+func TestUtilisation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, repo repository.ServerRepository) {
+		servers := seed(t, repo, fixtureCopy()...)
+		start := time.Date(2024, 5, 7, 12, 0, 0, 0, time.UTC)
+
+		recorded, err := repository.RecordUtilisation(repo, start)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, recorded)
+		assert.NoError(t, repo.SetActive(&servers[1], true))
+		_, err = repository.RecordUtilisation(repo, start.Add(20*time.Minute))
+		assert.NoError(t, err)
+		assert.NoError(t, repo.SetActive(&servers[0], false))
+		_, err = repository.RecordUtilisation(repo, start.Add(70*time.Minute))
+		assert.NoError(t, err)
+
+		query := repository.UtilisationQuery{Hostname: "mta-prod-1", From: start, To: start.Add(2 * time.Hour), Step: time.Hour}
+		points, err := repo.Utilisation().Series(query)
+		assert.NoError(t, err)
+		if assert.Len(t, points, 2) {
+			assert.True(t, start.Equal(points[0].Time))
+			assert.Equal(t, repository.UtilisationPoint{Time: points[0].Time, Samples: 2, Active: 1.5, ActiveMin: 1, ActiveMax: 2, Inactive: 0.5}, points[0])
+			assert.True(t, start.Add(time.Hour).Equal(points[1].Time))
+			assert.Equal(t, 1, points[1].ActiveMax)
+		}
+
+		query.Hostname = "mta-prod-9"
+		points, err = repo.Utilisation().Series(query)
+		assert.NoError(t, err)
+		assert.Empty(t, points)
+
+		query.Step = time.Second
+		_, err = repo.Utilisation().Series(query)
+		assert.ErrorIs(t, err, repository.ErrInvalidSeries)
+
+		purged, err := repo.Utilisation().PurgeBefore(start.Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6), purged)
+		query = repository.UtilisationQuery{Hostname: "mta-prod-1", From: start, To: start.Add(2 * time.Hour), Step: 2 * time.Hour}
+		points, err = repo.Utilisation().Series(query)
+		assert.NoError(t, err)
+		if assert.Len(t, points, 1) {
+			assert.Equal(t, 1, points[0].Samples)
+		}
+	})
+}
+
+func TestJobs(t *testing.T) {
+	forEachSchedulerBackend(t, func(t *testing.T, repo repository.SchedulerRepository) {
+		ran := time.Date(2024, 5, 7, 3, 0, 0, 0, time.UTC)
+		next := ran.Add(24 * time.Hour)
+		nightly := model.SchedulerJob{Name: "nightly", Type: "purge_deleted", Cron: "0 3 * * *", Params: map[string]string{"after_days": "7"}}
+		assert.NoError(t, repo.Jobs().Save(&nightly))
+		assert.NoError(t, repo.Jobs().Save(&model.SchedulerJob{Name: "eu-ips", Type: "active_ips", Interval: "30s", Paused: true}))
+
+		// creating it again fails
+		assert.ErrorIs(t, repo.Jobs().Save(&model.SchedulerJob{Name: "nightly", Type: "purge_deleted", Cron: "0 4 * * *"}), repository.ErrJobExists)
+
+		// the run times are set alone, the job stays as read
+		assert.NoError(t, repo.Jobs().SetRunTimes("nightly", ran, &next))
+		assert.ErrorIs(t, repo.Jobs().SetRunTimes("missing", ran, nil), repository.ErrNotFound)
+		read, err := repo.Jobs().Get("nightly")
+		assert.NoError(t, err)
+		assert.True(t, nightly.UpdatedAt.Equal(read.UpdatedAt))
+
+		// saving the job as read overwrites it, but for when it last ran
+		read.Params["after_days"] = "14"
+		read.LastRunAt = nil
+		assert.NoError(t, repo.Jobs().Save(read))
+
+		// but a stale copy no longer does
+		nightly.Paused = true
+		assert.ErrorIs(t, repo.Jobs().Save(&nightly), repository.ErrStaleJob)
+		_, err = repo.Jobs().Get("missing")
+		assert.ErrorIs(t, err, repository.ErrNotFound)
+
+		jobs, err := repo.Jobs().List()
+		assert.NoError(t, err)
+		if assert.Len(t, jobs, 2) {
+			assert.Equal(t, "eu-ips", jobs[0].Name)
+			assert.True(t, jobs[0].Paused)
+			assert.Nil(t, jobs[0].LastRunAt)
+			got := jobs[1]
+			assert.Equal(t, "0 3 * * *", got.Cron)
+			assert.False(t, got.Paused)
+			assert.Equal(t, map[string]string{"after_days": "14"}, got.Params)
+			if assert.NotNil(t, got.LastRunAt) && assert.NotNil(t, got.NextRunAt) {
+				assert.True(t, ran.Equal(*got.LastRunAt))
+				assert.True(t, next.Equal(*got.NextRunAt))
+			}
+		}
+
+		assert.NoError(t, repo.Jobs().Delete("nightly"))
+		assert.ErrorIs(t, repo.Jobs().Delete("nightly"), repository.ErrNotFound)
+		assert.ErrorIs(t, repo.Jobs().Save(read), repository.ErrNotFound)
+		jobs, err = repo.Jobs().List()
+		assert.NoError(t, err)
+		assert.Len(t, jobs, 1)
+	})
+}
+
+func TestJobRuns(t *testing.T) {
+	forEachSchedulerBackend(t, func(t *testing.T, repo repository.SchedulerRepository) {
+		start := time.Date(2024, 5, 7, 12, 0, 0, 0, time.UTC)
+		for i := 0; i < 5; i++ {
+			run := model.JobRun{
+				JobName:    "eu-ips",
+				StartedAt:  start.Add(time.Duration(i) * time.Hour),
+				FinishedAt: start.Add(time.Duration(i)*time.Hour + time.Second),
+				DurationMS: 1000,
+				Status:     model.JobRunSucceeded,
+				Result:     json.RawMessage(`{"ips":["11.0.0.1"]}`),
+			}
+			if i == 3 {
+				run.Status, run.Error, run.Result = model.JobRunFailed, "database is locked", nil
+			}
+			assert.NoError(t, repo.JobRuns().Record(&run))
+			assert.NotZero(t, run.ID)
+		}
+		assert.NoError(t, repo.JobRuns().Record(&model.JobRun{JobName: "other", StartedAt: start, FinishedAt: start, Status: model.JobRunSucceeded}))
+
+		counts, err := repo.JobRuns().Counts()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]int{"eu-ips": 5, "other": 1}, counts)
+
+		// newest first, two at a time
+		page, err := repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips", Limit: 2})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Runs, 2) {
+			assert.True(t, start.Add(4*time.Hour).Equal(page.Runs[0].StartedAt))
+			assert.Equal(t, model.JobRunFailed, page.Runs[1].Status)
+			assert.Equal(t, "database is locked", page.Runs[1].Error)
+			assert.JSONEq(t, `{"ips":["11.0.0.1"]}`, string(page.Runs[0].Result))
+		}
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips", Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Len(t, page.Runs, 2)
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips", Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Len(t, page.Runs, 1)
+		assert.Empty(t, page.NextCursor)
+
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips", Status: model.JobRunFailed})
+		assert.NoError(t, err)
+		assert.Len(t, page.Runs, 1)
+		_, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips", Cursor: "!"})
+		assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+
+		// the run of 12:00 is too old, then only the 2 newest are kept
+		pruned, err := repo.JobRuns().Prune("eu-ips", 2, start.Add(30*time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), pruned)
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips"})
+		assert.NoError(t, err)
+		if assert.Len(t, page.Runs, 2) {
+			assert.True(t, start.Add(4*time.Hour).Equal(page.Runs[0].StartedAt))
+			assert.True(t, start.Add(3*time.Hour).Equal(page.Runs[1].StartedAt))
+		}
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "other"})
+		assert.NoError(t, err)
+		assert.Len(t, page.Runs, 1)
+
+		// deleting a job deletes its runs
+		assert.NoError(t, repo.Jobs().Save(&model.SchedulerJob{Name: "eu-ips", Type: "active_ips", Interval: "1h"}))
+		assert.NoError(t, repo.Jobs().Delete("eu-ips"))
+		page, err = repo.JobRuns().List(repository.JobRunFilter{Job: "eu-ips"})
+		assert.NoError(t, err)
+		assert.Empty(t, page.Runs)
+	})
+}
+
+func TestJobLocks(t *testing.T) {
+	forEachSchedulerBackend(t, func(t *testing.T, repo repository.SchedulerRepository) {
+		locks := repo.JobLocks()
+
+		acquired, err := locks.Acquire("eu-ips", "cron-a", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+
+		// held by cron-a for a minute, its holder can take it again
+		acquired, err = locks.Acquire("eu-ips", "cron-b", time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, acquired)
+		acquired, err = locks.Acquire("eu-ips", "cron-a", time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+
+		// only its holder renews or releases it
+		renewed, err := locks.Renew("eu-ips", "cron-b", time.Hour)
+		assert.NoError(t, err)
+		assert.False(t, renewed)
+		assert.NoError(t, locks.Release("eu-ips", "cron-b", 0))
+		acquired, err = locks.Acquire("eu-ips", "cron-b", 2*time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, acquired)
+		renewed, err = locks.Renew("eu-ips", "cron-a", time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, renewed)
+		assert.NoError(t, locks.Release("eu-ips", "cron-a", 0))
+		acquired, err = locks.Acquire("eu-ips", "cron-b", 2*time.Minute)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+
+		acquired, err = locks.Acquire("nightly", "cron-a", time.Hour)
+		assert.NoError(t, err)
+		assert.True(t, acquired)
+		assert.NoError(t, locks.Release("nightly", "cron-a", -time.Second))
+		all, err := locks.List()
+		assert.NoError(t, err)
+		if assert.Len(t, all, 2) {
+			assert.Equal(t, "eu-ips", all[0].JobName)
+			assert.Equal(t, "cron-b", all[0].Holder)
+			assert.True(t, all[0].Held)
+			assert.WithinDuration(t, all[0].AcquiredAt.Add(2*time.Minute), all[0].ExpiresAt, time.Second)
+			assert.Equal(t, "nightly", all[1].JobName)
+			assert.False(t, all[1].Held)
+		}
+
+		// deleting a job deletes its lock
+		assert.NoError(t, repo.Jobs().Save(&model.SchedulerJob{Name: "nightly", Type: "purge_deleted", Cron: "0 3 * * *"}))
+		assert.NoError(t, repo.Jobs().Delete("nightly"))
+		all, err = locks.List()
+		assert.NoError(t, err)
+		assert.Len(t, all, 1)
+	})
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// UniqueKey names the fields no two servers that haven't been deleted may share.
+type UniqueKey string
+
+const (
+	// UniqueIP gives every server its own IP.
+	UniqueIP UniqueKey = "ip"
+	// UniqueIPHostname lets an IP be shared by servers of different hostnames.
+	UniqueIPHostname UniqueKey = "ip_hostname"
+)
+
+// ParseUniqueKey returns the UniqueKey named by s.
+func ParseUniqueKey(s string) (UniqueKey, error) {
+	switch key := UniqueKey(s); key {
+	case UniqueIP, UniqueIPHostname:
+		return key, nil
+	}
+	return "", fmt.Errorf("unique key %q is neither %s nor %s", s, UniqueIP, UniqueIPHostname)
+}
+
+// Columns returns the columns of the key, as listed in an index definition.
+func (k UniqueKey) Columns() string {
+	if k == UniqueIPHostname {
+		return "ip, hostname"
+	}
+	return "ip"
+}
+
+// conflicts reports whether a and b can't both be live servers.
+func (k UniqueKey) conflicts(a, b model.Server) bool {
+	if a.IP != b.IP {
+		return false
+	}
+	return k != UniqueIPHostname || a.Hostname == b.Hostname
+}
+
+// ConflictError is returned when a server would share its unique key with
+// another server that hasn't been deleted.
+type ConflictError struct {
+	// ID of the conflicting server, zero when it couldn't be told
+	ID       uint
+	IP       string
+	Hostname string
+	Key      UniqueKey
+}
+
+func (e *ConflictError) Error() string {
+	what := "IP " + e.IP
+	if e.Key == UniqueIPHostname {
+		what = fmt.Sprintf("IP %s with hostname %s", e.IP, e.Hostname)
+	}
+	if e.ID == 0 {
+		return what + " is already in use"
+	}
+	return fmt.Sprintf("%s is already used by server %d", what, e.ID)
+}
+
+// isUniqueViolation reports whether err comes from a unique index refusing a write.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
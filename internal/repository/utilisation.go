@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"GO_APP/internal/model"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MaxUtilisationPoints bounds the number of points a utilisation series may have.
+const MaxUtilisationPoints = 5000
+
+// ErrInvalidSeries is returned for a utilisation query that doesn't make a series.
+var ErrInvalidSeries = errors.New("invalid utilisation series")
+
+// UtilisationLog is the history of the active IP counts of the hostnames.
+type UtilisationLog interface {
+	// Record stores samples.
+	Record(samples []model.UtilisationSample) error
+	// Series returns the samples of a hostname downsampled by query.
+	Series(query UtilisationQuery) ([]UtilisationPoint, error)
+	// PurgeBefore removes the samples taken before cutoff and returns how
+	// many there were.
+	PurgeBefore(cutoff time.Time) (int64, error)
+}
+
+// UtilisationQuery selects the samples of Hostname taken from From, included,
+// to To, excluded, and groups them in buckets of Step starting at From.
+type UtilisationQuery struct {
+	Hostname string
+	From     time.Time
+	To       time.Time
+	Step     time.Duration
+}
+
+// Validate checks that the query makes at most MaxUtilisationPoints buckets.
+func (q UtilisationQuery) Validate() error {
+	if !q.To.After(q.From) {
+		return fmt.Errorf("%w: to must be after from", ErrInvalidSeries)
+	}
+	if q.Step <= 0 {
+		return fmt.Errorf("%w: step must be positive", ErrInvalidSeries)
+	}
+	if points := q.To.Sub(q.From) / q.Step; points > MaxUtilisationPoints {
+		return fmt.Errorf("%w: %d points, at most %d are returned, use a larger step", ErrInvalidSeries, points, MaxUtilisationPoints)
+	}
+	return nil
+}
+
+// UtilisationPoint sums up the samples of one bucket of a series. Buckets
+// without samples are left out of the series.
+type UtilisationPoint struct {
+	// Time is the start of the bucket
+	Time    time.Time `json:"time"`
+	Samples int       `json:"samples"`
+	// Active and Inactive are averages over the samples of the bucket
+	Active    float64 `json:"active"`
+	ActiveMin int     `json:"active_min"`
+	ActiveMax int     `json:"active_max"`
+	Inactive  float64 `json:"inactive"`
+}
+
+// downsample groups samples, sorted by TakenAt, in the buckets of query.
+func downsample(samples []model.UtilisationSample, query UtilisationQuery) []UtilisationPoint {
+	points := []UtilisationPoint{}
+	var point *UtilisationPoint
+	for _, sample := range samples {
+		bucket := query.From.Add(sample.TakenAt.Sub(query.From) / query.Step * query.Step)
+		if point == nil || !point.Time.Equal(bucket) {
+			points = append(points, UtilisationPoint{Time: bucket, ActiveMin: sample.Active, ActiveMax: sample.Active})
+			point = &points[len(points)-1]
+		}
+		point.Samples++
+		point.Active += float64(sample.Active)
+		point.Inactive += float64(sample.Inactive)
+		if sample.Active < point.ActiveMin {
+			point.ActiveMin = sample.Active
+		}
+		if sample.Active > point.ActiveMax {
+			point.ActiveMax = sample.Active
+		}
+	}
+	for i := range points {
+		points[i].Active /= float64(points[i].Samples)
+		points[i].Inactive /= float64(points[i].Samples)
+	}
+	return points
+}
+
+// RecordUtilisation stores a sample of every hostname of the live servers of
+// repo, as counted by the hostname report, and returns how many there were.
+func RecordUtilisation(repo ServerRepository, at time.Time) (int, error) {
+	report, err := repo.HostnameReport(nil, ActiveCount{Op: OpGTE})
+	if err != nil {
+		return 0, err
+	}
+	samples := make([]model.UtilisationSample, len(report))
+	for i, stats := range report {
+		samples[i] = model.UtilisationSample{Hostname: stats.Hostname, Active: stats.Active, Inactive: stats.Inactive, TakenAt: at.UTC()}
+	}
+	return len(samples), repo.Utilisation().Record(samples)
+}